@@ -13,17 +13,42 @@ END$$;
 CREATE TABLE IF NOT EXISTS categories (
     category_id SERIAL PRIMARY KEY,
     name VARCHAR(60) NOT NULL UNIQUE,
-    description TEXT
+    description TEXT,
+    parent_id INT,                    -- หมวดหมู่แม่ (NULL คือหมวดหมู่ระดับบนสุด)
+    min_age_months INT CHECK (min_age_months >= 0), -- ช่วงอายุ (เดือน) ของหมวดหมู่ที่เป็นช่วงอายุ
+    max_age_months INT,                             -- NULL คือไม่จำกัดอายุสูงสุด
+    CHECK (parent_id <> category_id),
+    CONSTRAINT categories_age_range CHECK (max_age_months IS NULL OR (min_age_months IS NOT NULL AND max_age_months >= min_age_months)),
+    FOREIGN KEY (parent_id) REFERENCES categories(category_id) ON DELETE RESTRICT
 );
 
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+
+-- ช่วงอายุของแต่ละหมวดหมู่ หมวดหมู่ที่ไม่ได้ระบุช่วงอายุใช้ช่วงอายุของหมวดหมู่แม่ที่ใกล้ที่สุด
+CREATE OR REPLACE VIEW category_age_bands AS
+WITH RECURSIVE ancestors AS (
+    SELECT category_id AS leaf_id, parent_id, min_age_months, max_age_months, 0 AS depth
+    FROM categories
+    UNION ALL
+    SELECT a.leaf_id, c.parent_id, c.min_age_months, c.max_age_months, a.depth + 1
+    FROM categories c JOIN ancestors a ON c.category_id = a.parent_id
+    WHERE a.min_age_months IS NULL
+)
+SELECT DISTINCT ON (leaf_id) leaf_id AS category_id, min_age_months, max_age_months
+FROM ancestors
+WHERE min_age_months IS NOT NULL
+ORDER BY leaf_id, depth;
+
 -- สร้างตาราง sellers
 CREATE TABLE IF NOT EXISTS sellers (
     seller_id SERIAL PRIMARY KEY,  -- ใช้ SERIAL เพื่อให้มีการสร้าง ID อัตโนมัติ
     name VARCHAR(255) NOT NULL UNIQUE,
     address VARCHAR(255),
-    phone_number CHAR(10),
+    phone_number CHAR(10) CHECK (phone_number ~ '^0[0-9]{9}$'), -- เบอร์โทรไทย 10 หลักขึ้นต้นด้วย 0
     email VARCHAR(50),
     description TEXT,
+    user_id UUID UNIQUE,              -- บัญชีผู้ใช้ (role seller) ที่เป็นเจ้าของร้าน
+    deactivated_at TIMESTAMPTZ,       -- ปิดร้าน สินค้าของร้านจะไม่แสดงและสั่งซื้อไม่ได้
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
@@ -43,30 +68,93 @@ CREATE TABLE IF NOT EXISTS products (
         END
     ) STORED,
     product_recommend product_recommend NOT NULL,
-    discount INTEGER DEFAULT 0,
+    discount INTEGER DEFAULT 0 CHECK (discount BETWEEN 0 AND 100),
     image_url VARCHAR(255),
+    -- ข้อมูลจำเพาะของของเล่น (NULL คือไม่ได้ระบุ)
+    min_age_months INTEGER CHECK (min_age_months >= 0),
+    max_age_months INTEGER CHECK (max_age_months >= 0),
+    battery_type VARCHAR(20),             -- AA, AAA, C, D, 9V, CR2032, LR44, rechargeable หรือ none (ไม่ใช้ถ่าน)
+    battery_count INTEGER CHECK (battery_count > 0),
+    length_cm NUMERIC(7, 1) CHECK (length_cm > 0),
+    width_cm NUMERIC(7, 1) CHECK (width_cm > 0),
+    height_cm NUMERIC(7, 1) CHECK (height_cm > 0),
+    weight_g INTEGER CHECK (weight_g > 0),
+    material VARCHAR(100),
+    -- ฉลากความปลอดภัย
+    hazards TEXT[] NOT NULL DEFAULT '{}',          -- ความเสี่ยงที่ต้องเตือน เช่น small_parts (ชิ้นส่วนเล็ก)
+    tis_number VARCHAR(100),                       -- เลขที่ มอก.
+    certifications TEXT[] NOT NULL DEFAULT '{}',   -- มาตรฐานอื่น เช่น EN 71, ASTM F963
     seller_id INTEGER NOT NULL,
     category_id INTEGER NOT NULL,
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,           -- ลบแบบ soft delete (ยังเก็บไว้เพื่อประวัติคำสั่งซื้อ)
+    CONSTRAINT products_age_range CHECK (max_age_months >= min_age_months),
     FOREIGN KEY (seller_id) REFERENCES sellers(seller_id) ON DELETE CASCADE,
     FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE
 );
 
+-- ตัวเลือกของสินค้า (สี ขนาด รุ่น) แต่ละตัวเลือกคือหนึ่ง SKU ที่มีสต็อกของตัวเอง
+-- สินค้าทุกชิ้นมีตัวเลือกหลัก (is_default) หนึ่งตัวเสมอ ซึ่ง trigger สร้างให้ตอนเพิ่มสินค้า
+CREATE TABLE IF NOT EXISTS product_variants (
+    variant_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL DEFAULT '',   -- ชื่อที่แสดง เช่น "สีแดง / แบบ A"
+    color VARCHAR(50),
+    size VARCHAR(50),
+    model VARCHAR(50),
+    price NUMERIC(10, 2) CHECK (price >= 0), -- ราคาเฉพาะตัวเลือก (NULL คือใช้ราคาของสินค้า)
+    image_urls TEXT[] NOT NULL DEFAULT '{}',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,                  -- ลบแบบ soft delete (ยังเก็บไว้เพื่อประวัติคำสั่งซื้อ)
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_variants_default ON product_variants(product_id) WHERE is_default;
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants(product_id) WHERE deleted_at IS NULL;
+
+-- แกลเลอรีรูปของสินค้า เรียงตาม position (0 คือรูปปก ซึ่งถูกคัดลอกไปที่ products.image_url)
+-- storage_keys คือไฟล์ทั้งหมดของรูปใน storage (ต้นฉบับและ thumbnail) รูปที่เป็นลิงก์ภายนอกจะว่าง
+CREATE TABLE IF NOT EXISTS product_images (
+    image_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    alt_text VARCHAR(255) NOT NULL DEFAULT '',
+    position INT NOT NULL DEFAULT 0 CHECK (position >= 0),
+    thumbnails JSONB NOT NULL DEFAULT '{}',  -- ขนาด -> URL เช่น {"small": "...", "medium": "..."}
+    storage_keys TEXT[] NOT NULL DEFAULT '{}',
+    content_type VARCHAR(50),
+    width INT,
+    height INT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id ON product_images(product_id, position);
+
 -- สร้างตาราง cart_items ใหม่
 CREATE TABLE IF NOT EXISTS cart_items (
     cart_item_id SERIAL PRIMARY KEY, -- รหัสไอเท็มในตะกร้าเป็น UUID
+    user_id UUID,                                            -- เจ้าของตะกร้า (users.user_id) NULL ได้เฉพาะรายการที่สั่งซื้อไปก่อนมีเจ้าของ
     product_id INT NOT NULL,                                 -- รหัสสินค้า
+    variant_id INT NOT NULL,                                 -- ตัวเลือกของสินค้า (สี ขนาด รุ่น)
     quantity INT NOT NULL CHECK (quantity > 0), 
     total_price NUMERIC(10, 2) NOT NULL,              -- จำนวนสินค้าที่เลือก
     added_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,          -- วันที่เพิ่มสินค้าลงตะกร้า
     added_to_cart BOOLEAN DEFAULT FALSE,
     status VARCHAR(50) DEFAULT 'processing',              -- สถานะของคำสั่งซื้อ 
-    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE -- เชื่อมโยงกับตาราง products
+    safety_acknowledged_at TIMESTAMPTZ,                   -- เวลาที่ผู้ใช้ยืนยันคำเตือนความปลอดภัย (ลูกอายุต่ำกว่าอายุขั้นต่ำ)
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE, -- เชื่อมโยงกับตาราง products
+    FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE
 );
 
 CREATE TABLE IF NOT EXISTS orders (
     order_id SERIAL PRIMARY KEY,                          -- รหัสคำสั่งซื้อ
+    user_id UUID,                                         -- ผู้ซื้อ (NULL เมื่อบัญชีถูกลบ)
     total_amount NUMERIC(10, 2) NOT NULL,                 -- ยอดรวมของคำสั่งซื้อ
     order_date TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP    -- วันที่ทำการสั่งซื้อ
 );
@@ -76,11 +164,57 @@ CREATE TABLE IF NOT EXISTS order_items (
     order_id INT NOT NULL,                                -- รหัสคำสั่งซื้อ
     cart_item_id INT NOT NULL,                            -- รหัสไอเท็มในตะกร้า
     seller_id INT NOT NULL,
+    product_id INT NOT NULL,                              -- สินค้าที่สั่งซื้อ
+    variant_id INT NOT NULL,                              -- ตัวเลือกของสินค้าที่สั่งซื้อ
+    quantity INT NOT NULL CHECK (quantity > 0),           -- จำนวนที่สั่งซื้อ
+    unit_price NUMERIC(10, 2) NOT NULL,                   -- ราคาต่อชิ้น ณ เวลาสั่งซื้อ
+    discount INTEGER NOT NULL DEFAULT 0,                  -- ส่วนลด (%) ณ เวลาสั่งซื้อ
+    line_total NUMERIC(10, 2) NOT NULL,                   -- ยอดรวมของรายการหลังหักส่วนลด
     FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
     FOREIGN KEY (cart_item_id) REFERENCES cart_items(cart_item_id) ON DELETE CASCADE,
+    FOREIGN KEY (seller_id) REFERENCES sellers(seller_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE
+);
+
+-- การจัดส่งของแต่ละร้านในคำสั่งซื้อ สถานะเปลี่ยนได้ตามลำดับที่กำหนด (internal/fulfillment)
+-- และเก็บเวลาที่เข้าสู่แต่ละสถานะไว้ cart_items.status ของทุกรายการในการจัดส่งถูกอัปเดตตามไปด้วย
+CREATE TABLE IF NOT EXISTS shipments (
+    shipment_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    seller_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing'
+        CHECK (status IN ('processing', 'packed', 'shipping', 'delivered', 'received', 'cancelled', 'returned')),
+    carrier VARCHAR(100),
+    tracking_number VARCHAR(100),
+    processing_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    packed_at TIMESTAMPTZ,
+    shipping_at TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    returned_at TIMESTAMPTZ,
+    cancelled_by UUID,                                    -- ผู้ยกเลิก (users.user_id)
+    cancellation_reason TEXT,                             -- เหตุผลที่ยกเลิก
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (order_id, seller_id),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
     FOREIGN KEY (seller_id) REFERENCES sellers(seller_id) ON DELETE CASCADE
 );
 
+-- ประวัติการเปลี่ยนสถานะของการจัดส่ง (from_status เป็น NULL คือตอนสร้าง)
+CREATE TABLE IF NOT EXISTS shipment_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    shipment_id INT NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    note TEXT,
+    created_by UUID,                                      -- ผู้เปลี่ยนสถานะ (users.user_id)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (shipment_id) REFERENCES shipments(shipment_id) ON DELETE CASCADE
+);
+
 -- สร้างฟังก์ชันสำหรับอัปเดตฟิลด์ updated_at อัตโนมัติ
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
@@ -97,12 +231,25 @@ FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
 CREATE TRIGGER update_sellers_updated_at BEFORE UPDATE ON sellers
 FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
 
+CREATE TRIGGER update_product_variants_updated_at BEFORE UPDATE ON product_variants
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TRIGGER update_product_images_updated_at BEFORE UPDATE ON product_images
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TRIGGER update_shipments_updated_at BEFORE UPDATE ON shipments
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
 -- แทรกข้อมูลตัวอย่างลงใน categories
-INSERT INTO categories (name, description) VALUES
-('แรกเกิด - 6 เดือน', 'ของเล่นสำหรับเด็กแรกเกิดจนถึง 6 เดือน'),
-('7 - 11 เดือน', 'ของเล่นสำหรับเด็กช่วงอายุ 7 ถึง 11 เดือน'),
-('1 - 2 ขวบ', 'ของเล่นสำหรับเด็กช่วงอายุ 1 ถึง 2 ขวบ'),
-('3 ขวบขึ้นไป', 'ของเล่นสำหรับเด็กอายุ 3 ปีขึ้นไป');
+INSERT INTO categories (name, description, min_age_months, max_age_months) VALUES
+('แรกเกิด - 6 เดือน', 'ของเล่นสำหรับเด็กแรกเกิดจนถึง 6 เดือน', 0, 6),
+('7 - 11 เดือน', 'ของเล่นสำหรับเด็กช่วงอายุ 7 ถึง 11 เดือน', 7, 11),
+('1 - 2 ขวบ', 'ของเล่นสำหรับเด็กช่วงอายุ 1 ถึง 2 ขวบ', 12, 35),
+('3 ขวบขึ้นไป', 'ของเล่นสำหรับเด็กอายุ 3 ปีขึ้นไป', 36, NULL);
+
+-- หมวดหมู่ย่อย
+INSERT INTO categories (name, description, parent_id) VALUES
+('ตัวต่อ', 'ตัวต่อและบล็อกสำหรับเด็กอายุ 3 ปีขึ้นไป', 4);
 
 -- แทรกข้อมูลตัวอย่างลงใน sellers
 INSERT INTO sellers (name, address, phone_number, email, description)
@@ -135,18 +282,192 @@ INSERT INTO products (
     (16, 'ตัวเลขไม้', '0-9 บวก ลบ คูณ หาร', 2, 199, 'notrecommend', 0, 4, 4, 'BrandP', 'https://aws.cmzimg.com/upload/10267/product-images/FW-1551/f4109157.jpg'),
     (17, 'เขาวงกตไดโนเสาร์ฝึกสมาธิ', 'เขาวงกตไดโนเสาร์ฝึกสมาธิ มีไฟ-เสียง', 7, 199, 'notrecommend', 17, 5, 4, 'BrandQ', 'https://aws.cmzimg.com/upload/10267/product-images/BB957158BC/87f90fd8.jpg'),
     (18, 'รถบังคับวิทยุตีลังกา', 'รถบังคับวิทยุตีลังกา 360 องศา', 8, 379, 'notrecommend', 0, 5, 4, 'BrandR', 'https://aws.cmzimg.com/upload/10267/product-images/BB964341W/bd7f721b.jpg'),
-    (19, 'เลโก้พิพิธภัณฑ์ไดโนเสาร์', 'เลโก้พิพิธภัณฑ์ไดโนเสาร์ 122 ชิ้น', 19, 159, 'notrecommend', 0, 5, 4, 'BrandS', 'https://aws.cmzimg.com/upload/10267/product-images/BB853766/ea3cfd36.jpg'),
-    (20, 'ตัวต่อเลโก้รถแข่ง', 'ตัวต่อเลโก้รถแข่ง มีให้เลือกสะสม 4 สี / 4 แบบ.', 12, 249, 'notrecommend', 15, 5, 4, 'BrandT', 'https://aws.cmzimg.com/upload/10267/product-images/BB333787/0d26a77d.jpg');
+    (19, 'เลโก้พิพิธภัณฑ์ไดโนเสาร์', 'เลโก้พิพิธภัณฑ์ไดโนเสาร์ 122 ชิ้น', 19, 159, 'notrecommend', 0, 5, 5, 'BrandS', 'https://aws.cmzimg.com/upload/10267/product-images/BB853766/ea3cfd36.jpg'),
+    (20, 'ตัวต่อเลโก้รถแข่ง', 'ตัวต่อเลโก้รถแข่ง มีให้เลือกสะสม 4 สี / 4 แบบ.', 12, 249, 'notrecommend', 15, 5, 5, 'BrandT', 'https://aws.cmzimg.com/upload/10267/product-images/BB333787/0d26a77d.jpg');
+
+
+-- ข้อมูลจำเพาะของสินค้าตัวอย่างที่ระบุไว้ในคำอธิบาย
+UPDATE products SET battery_type = 'AA', battery_count = 2 WHERE product_id = 2;
+UPDATE products SET battery_type = 'AAA', battery_count = 2 WHERE product_id = 3;
+UPDATE products SET battery_type = 'none', material = 'ผ้า' WHERE product_id = 5;
+UPDATE products SET battery_type = 'AA', battery_count = 3 WHERE product_id IN (8, 9, 11);
+UPDATE products SET length_cm = 12, width_cm = 11.5 WHERE product_id = 10;
+UPDATE products SET material = 'พลาสติก' WHERE product_id = 12;
+UPDATE products SET material = 'ไม้' WHERE product_id = 16;
+UPDATE products SET min_age_months = 0, max_age_months = 36 WHERE product_id IN (1, 2, 3, 5);
+UPDATE products SET min_age_months = 12 WHERE product_id IN (6, 10, 12);
+UPDATE products SET min_age_months = 36 WHERE product_id IN (16, 17, 18);
+UPDATE products SET min_age_months = 72 WHERE product_id IN (19, 20);
+
+-- ฉลากความปลอดภัยของสินค้าตัวอย่างที่มีชิ้นส่วนเล็ก
+UPDATE products SET hazards = '{small_parts}', tis_number = 'มอก. 685-2540' WHERE product_id IN (16, 19, 20);
+UPDATE products SET certifications = '{EN 71}' WHERE product_id IN (19, 20);
+
+-- ตัวเลือกหลักของสินค้าตัวอย่าง และตัวเลือกสีของตัวต่อเลโก้รถแข่ง
+INSERT INTO product_variants (product_id, sku, is_default)
+SELECT product_id, 'P' || product_id || '-DEFAULT', TRUE
+FROM products;
+
+UPDATE product_variants SET name = 'สีแดง', color = 'แดง' WHERE product_id = 20 AND is_default;
+INSERT INTO product_variants (product_id, sku, name, color) VALUES
+    (20, 'BB333787-BLU', 'สีน้ำเงิน', 'น้ำเงิน'),
+    (20, 'BB333787-YEL', 'สีเหลือง', 'เหลือง'),
+    (20, 'BB333787-GRN', 'สีเขียว', 'เขียว');
 
+-- รูปปกของสินค้าตัวอย่างเป็นรูปแรกในแกลเลอรี
+INSERT INTO product_images (product_id, url)
+SELECT product_id, image_url
+FROM products
+WHERE image_url IS NOT NULL AND image_url <> '';
 
+-- inventory.quantity (หนึ่งแถวต่อหนึ่งตัวเลือก) คือจำนวนสต็อกที่ถูกต้องเพียงแหล่งเดียว
+-- products.product_stock เป็นผลรวมของทุกตัวเลือกที่ trigger ซิงก์ให้ เพื่อให้คอลัมน์ product_status ถูกต้อง
 CREATE TABLE inventory (
-    product_id INT PRIMARY KEY,
-    quantity INT,
-    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    variant_id INT PRIMARY KEY,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
 );
-INSERT INTO inventory (product_id, quantity)
-SELECT product_id, product_stock
-FROM products;
+
+CREATE INDEX idx_inventory_product_id ON inventory(product_id);
+
+-- ตัวต่อเลโก้รถแข่งแบ่งสต็อกเท่าๆ กันทั้ง 4 สี สินค้าอื่นใช้ product_stock กับตัวเลือกหลัก
+INSERT INTO inventory (variant_id, product_id, quantity)
+SELECT v.variant_id, v.product_id,
+       CASE WHEN v.product_id = 20 THEN p.product_stock / 4
+            WHEN v.is_default THEN p.product_stock
+            ELSE 0 END
+FROM product_variants v
+JOIN products p ON p.product_id = v.product_id;
+
+-- สต็อกรวมของสินค้าทั้งชิ้น ใช้ JOIN แทนตาราง inventory เมื่อต้องการจำนวนระดับสินค้า
+CREATE VIEW product_inventory AS
+SELECT product_id, SUM(quantity)::INT AS quantity, MAX(updated_at) AS updated_at
+FROM inventory
+GROUP BY product_id;
+
+-- ข้อมูลตัวอย่างกำหนด product_id เอง จึงต้องเลื่อน sequence ให้ต่อจากค่าสูงสุด
+SELECT setval(pg_get_serial_sequence('products', 'product_id'), (SELECT MAX(product_id) FROM products));
+
+-- สมุดบัญชีสต็อก: ทุกการเปลี่ยนแปลงของ inventory.quantity ต้องมีรายการที่นี่ (เพิ่มได้อย่างเดียว)
+-- ผลรวม change ของแต่ละตัวเลือกต้องเท่ากับ inventory.quantity
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'stock_movement_reason') THEN
+        CREATE TYPE stock_movement_reason AS ENUM ('sale', 'return', 'restock', 'adjustment', 'reservation_release', 'cancellation', 'write_off');
+    END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    movement_id BIGSERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    variant_id INT NOT NULL,
+    change INT NOT NULL,                                  -- จำนวนที่เปลี่ยน (ติดลบคือออกจากคลัง)
+    quantity_after INT NOT NULL CHECK (quantity_after >= 0), -- inventory.quantity ของตัวเลือกหลังการเปลี่ยนแปลง
+    reason stock_movement_reason NOT NULL,
+    reference VARCHAR(100),                               -- อ้างอิงเอกสารต้นทาง เช่น order:12
+    note TEXT,
+    created_by UUID,                                      -- ผู้ทำรายการ (NULL = ระบบ)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_stock_movements_product_id ON stock_movements(product_id, movement_id);
+CREATE INDEX idx_stock_movements_variant_id ON stock_movements(variant_id, movement_id);
+
+-- ห้ามแก้หรือลบประวัติ ยกเว้นการเปลี่ยนที่มาจาก foreign key
+-- (ลบตามสินค้าที่ถูกลบ หรือล้าง created_by เมื่อผู้ใช้ถูกลบ)
+CREATE OR REPLACE FUNCTION prevent_stock_movement_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER stock_movements_append_only BEFORE UPDATE OR DELETE ON stock_movements
+FOR EACH ROW EXECUTE PROCEDURE prevent_stock_movement_changes();
+
+-- ยอดยกมาของสินค้าตัวอย่าง
+INSERT INTO stock_movements (product_id, variant_id, change, quantity_after, reason, note)
+SELECT product_id, variant_id, quantity, quantity, 'adjustment', 'opening balance'
+FROM inventory;
+
+-- สร้างตัวเลือกหลักให้สินค้าใหม่ โดยใช้ product_stock เป็นสต็อกเริ่มต้นของตัวเลือกหลัก พร้อมบันทึกลงสมุดบัญชีสต็อก
+CREATE OR REPLACE FUNCTION create_default_variant_for_product()
+RETURNS TRIGGER AS $$
+DECLARE
+    default_variant_id INT;
+BEGIN
+    INSERT INTO product_variants (product_id, sku, is_default)
+    VALUES (NEW.product_id, 'P' || NEW.product_id || '-DEFAULT', TRUE)
+    RETURNING variant_id INTO default_variant_id;
+
+    IF COALESCE(NEW.product_stock, 0) > 0 THEN
+        UPDATE inventory SET quantity = NEW.product_stock, updated_at = CURRENT_TIMESTAMP
+        WHERE variant_id = default_variant_id;
+
+        INSERT INTO stock_movements (product_id, variant_id, change, quantity_after, reason, note)
+        VALUES (NEW.product_id, default_variant_id, NEW.product_stock, NEW.product_stock, 'restock', 'initial stock');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+-- สร้างแถว inventory (เริ่มที่ 0) ให้ตัวเลือกใหม่ทุกตัว
+CREATE OR REPLACE FUNCTION create_inventory_for_variant()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO inventory (variant_id, product_id, quantity)
+    VALUES (NEW.variant_id, NEW.product_id, 0)
+    ON CONFLICT (variant_id) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+-- ซิงก์ผลรวม inventory.quantity ของทุกตัวเลือกไปยัง products.product_stock ทุกครั้งที่สต็อกเปลี่ยน
+CREATE OR REPLACE FUNCTION sync_product_stock_from_inventory()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_product_id INT := COALESCE(NEW.product_id, OLD.product_id);
+BEGIN
+    UPDATE products
+    SET product_stock = (SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE product_id = changed_product_id)
+    WHERE product_id = changed_product_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE 'plpgsql';
+
+-- ไม่ให้แก้ products.product_stock ตรงๆ ให้ใช้ผลรวมจาก inventory เสมอ
+CREATE OR REPLACE FUNCTION enforce_product_stock_from_inventory()
+RETURNS TRIGGER AS $$
+DECLARE
+    inventory_quantity INT;
+BEGIN
+    SELECT SUM(quantity) INTO inventory_quantity FROM inventory WHERE product_id = NEW.product_id;
+    IF inventory_quantity IS NOT NULL THEN
+        NEW.product_stock := inventory_quantity;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER create_products_default_variant AFTER INSERT ON products
+FOR EACH ROW EXECUTE PROCEDURE create_default_variant_for_product();
+
+CREATE TRIGGER create_variants_inventory AFTER INSERT ON product_variants
+FOR EACH ROW EXECUTE PROCEDURE create_inventory_for_variant();
+
+CREATE TRIGGER enforce_products_stock BEFORE UPDATE OF product_stock ON products
+FOR EACH ROW EXECUTE PROCEDURE enforce_product_stock_from_inventory();
+
+CREATE TRIGGER sync_inventory_product_stock AFTER INSERT OR UPDATE OF quantity OR DELETE ON inventory
+FOR EACH ROW EXECUTE PROCEDURE sync_product_stock_from_inventory();
 
 COMMIT;
 
@@ -212,6 +533,18 @@ CREATE TABLE IF NOT EXISTS api_keys (
     updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
+-- สร้างตาราง child_profiles (โปรไฟล์ลูกของผู้ใช้ ใช้แนะนำของเล่นตามอายุ)
+CREATE TABLE IF NOT EXISTS child_profiles (
+    child_id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    birth_month DATE NOT NULL CHECK (EXTRACT(DAY FROM birth_month) = 1), -- วันที่ 1 ของเดือนเกิด
+    interests TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
 -- สร้าง Trigger สำหรับตาราง users
 CREATE TRIGGER update_users_updated_at
 BEFORE UPDATE ON users
@@ -227,9 +560,187 @@ CREATE TRIGGER update_api_keys_updated_at
 BEFORE UPDATE ON api_keys
 FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+-- สร้าง Trigger สำหรับตาราง child_profiles
+CREATE TRIGGER update_child_profiles_updated_at
+BEFORE UPDATE ON child_profiles
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- ผูกตะกร้าสินค้ากับผู้ใช้ (ตาราง users ถูกสร้างหลัง cart_items)
+ALTER TABLE cart_items
+    ADD CONSTRAINT fk_cart_items_user
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
+
+-- ตะกร้าที่ยังไม่ได้สั่งซื้อต้องมีเจ้าของเสมอ
+ALTER TABLE cart_items
+    ADD CONSTRAINT cart_items_owner_check
+    CHECK (user_id IS NOT NULL OR added_to_cart);
+
+ALTER TABLE sellers
+    ADD CONSTRAINT fk_sellers_user
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL;
+
+ALTER TABLE stock_movements
+    ADD CONSTRAINT fk_stock_movements_created_by
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL;
+
+ALTER TABLE shipment_events
+    ADD CONSTRAINT fk_shipment_events_created_by
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL;
+
+ALTER TABLE shipments
+    ADD CONSTRAINT fk_shipments_cancelled_by
+    FOREIGN KEY (cancelled_by) REFERENCES users(user_id) ON DELETE SET NULL;
+
+ALTER TABLE orders
+    ADD CONSTRAINT fk_orders_user
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL;
+
+-- การจองสินค้าในตะกร้า: หนึ่งแถวต่อหนึ่งรายการในตะกร้า ไม่ได้ตัด inventory.quantity
+-- แต่ถูกหักออกจากจำนวนที่ผู้ใช้อื่นซื้อได้จนกว่าจะหมดอายุ (expires_at) หรือถูกสั่งซื้อ
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    reservation_id BIGSERIAL PRIMARY KEY,
+    cart_item_id INT NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    product_id INT NOT NULL,
+    variant_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (cart_item_id) REFERENCES cart_items(cart_item_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE
+);
+
+-- การเรียกคืนสินค้า: variant_ids ว่างคือทุกตัวเลือกของสินค้า (สินค้าถูกซ่อนจากหน้ารายการและการค้นหา)
+-- sold_from / sold_until จำกัดล็อตที่ถูกเรียกคืนตามช่วงวันที่สั่งซื้อ ใช้เลือกลูกค้าที่ต้องแจ้งเตือน
+CREATE TABLE IF NOT EXISTS product_recalls (
+    recall_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    variant_ids INT[] NOT NULL DEFAULT '{}',
+    sold_from TIMESTAMPTZ,
+    sold_until TIMESTAMPTZ,
+    reason TEXT NOT NULL,
+    instructions TEXT,
+    status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'closed')),
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMPTZ,
+    CHECK (sold_until IS NULL OR sold_from IS NULL OR sold_until >= sold_from),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- การแจ้งเตือนถึงผู้ใช้ (ตอนนี้มีเฉพาะการเรียกคืนสินค้า) ผู้ใช้หนึ่งคนได้รับหนึ่งรายการต่อการเรียกคืน
+CREATE TABLE IF NOT EXISTS notifications (
+    notification_id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    recall_id INT,
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, recall_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (recall_id) REFERENCES product_recalls(recall_id) ON DELETE CASCADE
+);
+
+-- คำขอคืนสินค้า (RMA): ผู้ซื้อขอคืนรายการในการจัดส่งที่ได้รับแล้ว ผู้ขายของร้านนั้นอนุมัติหรือปฏิเสธ
+-- คำขอหนึ่งรายการครอบคลุมการจัดส่งเดียว (ร้านเดียว)
+CREATE TABLE IF NOT EXISTS return_requests (
+    return_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    shipment_id INT NOT NULL,
+    seller_id INT NOT NULL,
+    user_id UUID NOT NULL,                                -- ผู้ซื้อที่ขอคืน
+    status VARCHAR(20) NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'approved', 'rejected')),
+    reason TEXT NOT NULL,
+    decision_note TEXT,
+    decided_by UUID,
+    decided_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY (shipment_id) REFERENCES shipments(shipment_id) ON DELETE CASCADE,
+    FOREIGN KEY (seller_id) REFERENCES sellers(seller_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (decided_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- รายการที่ขอคืน disposition ถูกกำหนดตอนอนุมัติ: restock คืนเข้าคลัง write_off รับคืนแล้วตัดทิ้ง
+CREATE TABLE IF NOT EXISTS return_items (
+    return_item_id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    disposition VARCHAR(20) CHECK (disposition IN ('restock', 'write_off')),
+    UNIQUE (return_id, order_item_id),
+    FOREIGN KEY (return_id) REFERENCES return_requests(return_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id) ON DELETE CASCADE
+);
+
+-- รูปประกอบคำขอคืนสินค้า (เก็บใน media storage เหมือนรูปสินค้า)
+CREATE TABLE IF NOT EXISTS return_photos (
+    photo_id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    thumbnails JSONB NOT NULL DEFAULT '{}',
+    storage_keys TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (return_id) REFERENCES return_requests(return_id) ON DELETE CASCADE
+);
+
+-- การคืนเงินของคำขอคืนสินค้าที่อนุมัติแล้ว อ้างอิงคำสั่งซื้อซึ่งเป็นการชำระเงินต้นทาง (ยังไม่มีตารางการชำระเงินแยก)
+CREATE TABLE IF NOT EXISTS refunds (
+    refund_id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL UNIQUE,
+    order_id INT NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount >= 0),
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (return_id) REFERENCES return_requests(return_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- สร้าง Trigger สำหรับตาราง product_recalls
+CREATE TRIGGER update_product_recalls_updated_at
+BEFORE UPDATE ON product_recalls
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- สร้าง Trigger สำหรับตาราง return_requests
+CREATE TRIGGER update_return_requests_updated_at
+BEFORE UPDATE ON return_requests
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
 -- สร้าง Indexes
+CREATE INDEX idx_cart_items_user_id ON cart_items(user_id, added_to_cart);
+CREATE INDEX idx_stock_reservations_variant_id ON stock_reservations(variant_id, expires_at);
+CREATE INDEX idx_stock_reservations_user_id ON stock_reservations(user_id);
+CREATE INDEX idx_products_listing_created_at ON products(created_at, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_listing_price ON products(price, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_listing_discount ON products(discount, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_listing_name ON products(name, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_category_id ON products(category_id) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_sellers_name_lower ON sellers(LOWER(name));
+CREATE INDEX idx_products_seller_id ON products(seller_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_brand ON products(LOWER(brand)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_min_age_months ON products(min_age_months) WHERE deleted_at IS NULL;
 CREATE INDEX idx_users_email ON users(email);
 CREATE INDEX idx_users_google_id ON users(google_id);
 CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
 CREATE INDEX idx_user_login_history_user_id ON user_login_history(user_id);
 CREATE INDEX idx_api_keys_api_key ON api_keys(api_key);
+CREATE INDEX idx_child_profiles_user_id ON child_profiles(user_id);
+CREATE INDEX idx_product_recalls_active ON product_recalls(product_id) WHERE status = 'active';
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created_at);
+CREATE INDEX idx_order_items_product_id ON order_items(product_id);
+CREATE INDEX idx_shipments_status ON shipments(seller_id, status);
+CREATE INDEX idx_shipment_events_shipment_id ON shipment_events(shipment_id, created_at);
+CREATE INDEX idx_return_requests_user_id ON return_requests(user_id, created_at);
+CREATE INDEX idx_return_requests_seller_id ON return_requests(seller_id, status);
+CREATE INDEX idx_return_items_order_item_id ON return_items(order_item_id);
+CREATE INDEX idx_orders_user_id ON orders(user_id, order_date);
@@ -0,0 +1,42 @@
+-- 0001_cart_owner.sql
+-- ผูกตะกร้าสินค้ากับผู้ใช้ (cart_items.user_id) สำหรับฐานข้อมูลที่สร้างจาก init.sql เวอร์ชันก่อนหน้า
+-- ตะกร้าเดิมไม่มีข้อมูลเจ้าของ จึงลบตะกร้าที่ยังไม่ได้สั่งซื้อทิ้ง (ผู้ใช้เพิ่มสินค้าใหม่ได้)
+-- ส่วนรายการที่สั่งซื้อไปแล้วเก็บไว้โดย user_id เป็น NULL ถ้ารู้เจ้าของจริงให้ผูกเองภายหลัง เช่น
+--   UPDATE cart_items SET user_id = '<users.user_id>' WHERE cart_item_id IN (...);
+-- ตะกร้าที่ยังไม่ได้สั่งซื้อต้องมีเจ้าของเสมอ (cart_items_owner_check)
+--   psql -h <host> -p <port> -U <user> -d ecommerce -f 0001_cart_owner.sql
+
+BEGIN;
+
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS user_id UUID;
+
+DO $$
+DECLARE
+    removed INT;
+BEGIN
+    DELETE FROM cart_items ci
+    WHERE ci.user_id IS NULL
+      AND ci.added_to_cart IS DISTINCT FROM TRUE
+      AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.cart_item_id = ci.cart_item_id);
+    GET DIAGNOSTICS removed = ROW_COUNT;
+    RAISE NOTICE 'open cart items without owner removed: %', removed;
+END$$;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_cart_items_user') THEN
+        ALTER TABLE cart_items
+            ADD CONSTRAINT fk_cart_items_user
+            FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'cart_items_owner_check') THEN
+        ALTER TABLE cart_items
+            ADD CONSTRAINT cart_items_owner_check
+            CHECK (user_id IS NOT NULL OR added_to_cart);
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_cart_items_user_id ON cart_items(user_id, added_to_cart);
+
+COMMIT;
@@ -0,0 +1,65 @@
+-- 0002_order_item_prices.sql
+-- เก็บสินค้า จำนวน ราคา และส่วนลด ณ เวลาสั่งซื้อไว้ใน order_items
+-- init.sql เวอร์ชันก่อนหน้าไม่ได้เก็บราคาตอนสั่งซื้อ รายการเดิมจึงใช้สินค้าและจำนวนจาก cart_items
+-- แล้วแบ่ง orders.total_amount ที่ลูกค้าจ่ายจริงให้แต่ละรายการตามสัดส่วนของ cart_items.total_price
+-- (ถ้ารวมเป็น 0 ใช้สัดส่วนของจำนวนแทน) เศษจากการปัดทศนิยมไปอยู่ที่รายการสุดท้าย
+-- ผลรวม line_total ของแต่ละคำสั่งซื้อจึงเท่ากับ total_amount เสมอ ส่วนลดของรายการเดิมเป็น 0 (รวมอยู่ในราคาแล้ว)
+-- ต้องรันหลัง 0001_cart_owner.sql:
+--   psql -h <host> -p <port> -U <user> -d ecommerce -f 0002_order_item_prices.sql
+
+BEGIN;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_id INT;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS quantity INT;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_price NUMERIC(10, 2);
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS line_total NUMERIC(10, 2);
+
+WITH lines AS (
+    SELECT oi.order_item_id, oi.order_id, ci.product_id, ci.quantity, o.total_amount,
+           CASE WHEN SUM(ci.total_price) OVER w > 0 THEN ci.total_price ELSE ci.quantity END AS weight,
+           CASE WHEN SUM(ci.total_price) OVER w > 0 THEN SUM(ci.total_price) OVER w ELSE SUM(ci.quantity) OVER w END AS weight_sum,
+           ROW_NUMBER() OVER (PARTITION BY oi.order_id ORDER BY oi.order_item_id DESC) AS from_last
+    FROM order_items oi
+    JOIN cart_items ci ON ci.cart_item_id = oi.cart_item_id
+    JOIN orders o ON o.order_id = oi.order_id
+    WHERE oi.line_total IS NULL
+    WINDOW w AS (PARTITION BY oi.order_id)
+), shares AS (
+    SELECT lines.*, ROUND(total_amount * weight / weight_sum, 2) AS share
+    FROM lines
+), split AS (
+    SELECT shares.*, SUM(share) OVER (PARTITION BY order_id) AS share_sum
+    FROM shares
+)
+UPDATE order_items oi
+SET product_id = s.product_id,
+    quantity = s.quantity,
+    discount = 0,
+    line_total = CASE WHEN s.from_last = 1 THEN s.share + (s.total_amount - s.share_sum) ELSE s.share END
+FROM split s
+WHERE s.order_item_id = oi.order_item_id;
+
+UPDATE order_items
+SET unit_price = ROUND(line_total / quantity, 2)
+WHERE unit_price IS NULL AND line_total IS NOT NULL;
+
+ALTER TABLE order_items ALTER COLUMN product_id SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN quantity SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN unit_price SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN line_total SET NOT NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'order_items_quantity_check') THEN
+        ALTER TABLE order_items ADD CONSTRAINT order_items_quantity_check CHECK (quantity > 0);
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'order_items_product_id_fkey') THEN
+        ALTER TABLE order_items
+            ADD CONSTRAINT order_items_product_id_fkey
+            FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE;
+    END IF;
+END$$;
+
+COMMIT;
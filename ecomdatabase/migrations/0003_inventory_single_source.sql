@@ -0,0 +1,98 @@
+-- 0003_inventory_single_source.sql
+-- ทำให้ inventory.quantity เป็นแหล่งข้อมูลสต็อกเพียงแหล่งเดียว แล้วแก้ข้อมูลที่ไม่ตรงกับ products.product_stock
+-- ใช้กับฐานข้อมูลที่สร้างจาก init.sql เวอร์ชันก่อนหน้า ต้องรันหลัง 0002_order_item_prices.sql:
+--   psql -h <host> -p <port> -U <user> -d ecommerce -f 0003_inventory_single_source.sql
+
+BEGIN;
+
+-- รายงานจำนวนสินค้าที่สต็อกสองแหล่งไม่ตรงกันก่อนแก้
+DO $$
+DECLARE
+    drift_count INT;
+BEGIN
+    SELECT COUNT(*) INTO drift_count
+    FROM products p
+    LEFT JOIN inventory i ON i.product_id = p.product_id
+    WHERE i.product_id IS NULL OR i.quantity IS DISTINCT FROM p.product_stock;
+    RAISE NOTICE 'products with stock drift before migration: %', drift_count;
+END$$;
+
+-- ลบแถว inventory ของสินค้าที่ไม่มีอยู่แล้ว
+DELETE FROM inventory i
+WHERE NOT EXISTS (SELECT 1 FROM products p WHERE p.product_id = i.product_id);
+
+-- สินค้าที่ยังไม่มีแถว inventory ให้เริ่มจาก product_stock
+INSERT INTO inventory (product_id, quantity)
+SELECT p.product_id, COALESCE(p.product_stock, 0)
+FROM products p
+ON CONFLICT (product_id) DO NOTHING;
+
+-- inventory ที่ไม่มีค่าให้ใช้ product_stock ส่วนค่าติดลบปัดเป็น 0
+UPDATE inventory i
+SET quantity = COALESCE(p.product_stock, 0), updated_at = CURRENT_TIMESTAMP
+FROM products p
+WHERE p.product_id = i.product_id AND i.quantity IS NULL;
+
+UPDATE inventory SET quantity = 0, updated_at = CURRENT_TIMESTAMP WHERE quantity < 0;
+
+ALTER TABLE inventory
+    ALTER COLUMN quantity SET DEFAULT 0,
+    ALTER COLUMN quantity SET NOT NULL,
+    ADD CONSTRAINT inventory_quantity_check CHECK (quantity >= 0),
+    ADD CONSTRAINT inventory_product_id_fkey
+        FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE;
+
+-- ให้สำเนาใน products ตรงกับ inventory (inventory เป็นฝั่งที่ถูกต้อง)
+UPDATE products p
+SET product_stock = i.quantity
+FROM inventory i
+WHERE i.product_id = p.product_id AND p.product_stock IS DISTINCT FROM i.quantity;
+
+-- ข้อมูลตัวอย่างกำหนด product_id เอง จึงต้องเลื่อน sequence ให้ต่อจากค่าสูงสุด
+SELECT setval(pg_get_serial_sequence('products', 'product_id'), (SELECT MAX(product_id) FROM products));
+
+CREATE OR REPLACE FUNCTION create_inventory_for_product()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO inventory (product_id, quantity)
+    VALUES (NEW.product_id, COALESCE(NEW.product_stock, 0))
+    ON CONFLICT (product_id) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION sync_product_stock_from_inventory()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE products SET product_stock = NEW.quantity
+    WHERE product_id = NEW.product_id AND product_stock IS DISTINCT FROM NEW.quantity;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION enforce_product_stock_from_inventory()
+RETURNS TRIGGER AS $$
+DECLARE
+    inventory_quantity INT;
+BEGIN
+    SELECT quantity INTO inventory_quantity FROM inventory WHERE product_id = NEW.product_id;
+    IF FOUND THEN
+        NEW.product_stock := inventory_quantity;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+DROP TRIGGER IF EXISTS create_products_inventory ON products;
+CREATE TRIGGER create_products_inventory AFTER INSERT ON products
+FOR EACH ROW EXECUTE PROCEDURE create_inventory_for_product();
+
+DROP TRIGGER IF EXISTS enforce_products_stock ON products;
+CREATE TRIGGER enforce_products_stock BEFORE UPDATE OF product_stock ON products
+FOR EACH ROW EXECUTE PROCEDURE enforce_product_stock_from_inventory();
+
+DROP TRIGGER IF EXISTS sync_inventory_product_stock ON inventory;
+CREATE TRIGGER sync_inventory_product_stock AFTER INSERT OR UPDATE OF quantity ON inventory
+FOR EACH ROW EXECUTE PROCEDURE sync_product_stock_from_inventory();
+
+COMMIT;
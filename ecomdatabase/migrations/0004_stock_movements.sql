@@ -0,0 +1,65 @@
+-- 0004_stock_movements.sql
+-- สมุดบัญชีสต็อก (stock_movements) ที่เพิ่มได้อย่างเดียว พร้อมยอดยกมาจาก inventory ปัจจุบัน
+-- ต้องรันหลัง 0003_inventory_single_source.sql
+
+BEGIN;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'stock_movement_reason') THEN
+        CREATE TYPE stock_movement_reason AS ENUM ('sale', 'return', 'restock', 'adjustment', 'reservation_release');
+    END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    movement_id BIGSERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    change INT NOT NULL,
+    quantity_after INT NOT NULL CHECK (quantity_after >= 0),
+    reason stock_movement_reason NOT NULL,
+    reference VARCHAR(100),
+    note TEXT,
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements(product_id, movement_id);
+
+CREATE OR REPLACE FUNCTION prevent_stock_movement_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE 'plpgsql';
+
+DROP TRIGGER IF EXISTS stock_movements_append_only ON stock_movements;
+CREATE TRIGGER stock_movements_append_only BEFORE UPDATE OR DELETE ON stock_movements
+FOR EACH ROW EXECUTE PROCEDURE prevent_stock_movement_changes();
+
+-- ยอดยกมา: สต็อกปัจจุบันของสินค้าที่ยังไม่มีประวัติ
+INSERT INTO stock_movements (product_id, change, quantity_after, reason, note)
+SELECT i.product_id, i.quantity, i.quantity, 'adjustment', 'opening balance'
+FROM inventory i
+WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = i.product_id);
+
+CREATE OR REPLACE FUNCTION create_inventory_for_product()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO inventory (product_id, quantity)
+    VALUES (NEW.product_id, COALESCE(NEW.product_stock, 0))
+    ON CONFLICT (product_id) DO NOTHING;
+
+    IF FOUND AND COALESCE(NEW.product_stock, 0) > 0 THEN
+        INSERT INTO stock_movements (product_id, change, quantity_after, reason, note)
+        VALUES (NEW.product_id, NEW.product_stock, NEW.product_stock, 'restock', 'initial stock');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+COMMIT;
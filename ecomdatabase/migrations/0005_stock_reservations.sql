@@ -0,0 +1,25 @@
+-- 0005_stock_reservations.sql
+-- การจองสินค้าในตะกร้าแบบมีวันหมดอายุ ตะกร้าที่มีอยู่เดิมไม่มีการจอง
+-- (จะถูกจองเมื่อผู้ใช้เพิ่มหรือแก้จำนวนสินค้าในตะกร้าครั้งถัดไป)
+-- ต้องรันหลัง 0004_stock_movements.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    reservation_id BIGSERIAL PRIMARY KEY,
+    cart_item_id INT NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (cart_item_id) REFERENCES cart_items(cart_item_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_product_id ON stock_reservations(product_id, expires_at);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_user_id ON stock_reservations(user_id);
+
+COMMIT;
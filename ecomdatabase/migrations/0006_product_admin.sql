@@ -0,0 +1,41 @@
+-- 0006_product_admin.sql
+-- รองรับการจัดการสินค้าโดยผู้ขายและผู้ดูแลระบบ
+--   - sellers.user_id ผูกร้านค้ากับบัญชีผู้ใช้ (role seller) เพื่อจำกัดให้แก้ได้เฉพาะสินค้าของร้านตัวเอง
+--   - products.deleted_at สำหรับ soft delete เพื่อไม่ให้ประวัติคำสั่งซื้อและสต็อกหายไป
+--   - จำกัด products.discount ให้อยู่ระหว่าง 0 ถึง 100
+-- ต้องรันหลัง 0005_stock_reservations.sql
+
+BEGIN;
+
+ALTER TABLE sellers ADD COLUMN IF NOT EXISTS user_id UUID UNIQUE;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_sellers_user') THEN
+        ALTER TABLE sellers
+            ADD CONSTRAINT fk_sellers_user
+            FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL;
+    END IF;
+END$$;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+DO $$
+DECLARE
+    out_of_range INT;
+BEGIN
+    SELECT COUNT(*) INTO out_of_range FROM products WHERE discount IS NOT NULL AND discount NOT BETWEEN 0 AND 100;
+    IF out_of_range > 0 THEN
+        RAISE NOTICE 'clamping discount of % product(s) into 0-100', out_of_range;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_discount_check') THEN
+        UPDATE products SET discount = LEAST(GREATEST(discount, 0), 100)
+        WHERE discount NOT BETWEEN 0 AND 100;
+
+        ALTER TABLE products
+            ADD CONSTRAINT products_discount_check CHECK (discount BETWEEN 0 AND 100);
+    END IF;
+END$$;
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- 0007_seller_management.sql
+-- รองรับการลงทะเบียน แก้ไข และปิดร้านค้าผ่าน API
+--   - sellers.deactivated_at สำหรับปิดร้าน (สินค้าของร้านจะไม่แสดงและสั่งซื้อไม่ได้)
+--   - ตรวจรูปแบบเบอร์โทรไทย 10 หลักขึ้นต้นด้วย 0 สำหรับข้อมูลใหม่
+--     (ข้อมูลเดิมที่ไม่ตรงรูปแบบจะถูกรายงานแต่ไม่ถูกแก้ เพราะ constraint เป็น NOT VALID)
+-- ต้องรันหลัง 0006_product_admin.sql
+
+BEGIN;
+
+ALTER TABLE sellers ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+DO $$
+DECLARE
+    bad_phones INT;
+BEGIN
+    SELECT COUNT(*) INTO bad_phones FROM sellers
+    WHERE phone_number IS NOT NULL AND phone_number !~ '^0[0-9]{9}$';
+    IF bad_phones > 0 THEN
+        RAISE NOTICE '% seller(s) have a phone number that is not a 10-digit Thai number', bad_phones;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'sellers_phone_number_check') THEN
+        ALTER TABLE sellers
+            ADD CONSTRAINT sellers_phone_number_check CHECK (phone_number ~ '^0[0-9]{9}$') NOT VALID;
+    END IF;
+END$$;
+
+COMMIT;
@@ -0,0 +1,26 @@
+-- 0008_category_tree.sql
+-- หมวดหมู่ซ้อนกันได้ (parent/child) เช่น "3 ขวบขึ้นไป" → "ตัวต่อ"
+-- การแสดงสินค้าตามหมวดหมู่แม่จะรวมสินค้าในหมวดหมู่ย่อยทุกระดับ
+-- ต้องรันหลัง 0007_seller_management.sql
+
+BEGIN;
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id INT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_categories_parent') THEN
+        ALTER TABLE categories
+            ADD CONSTRAINT fk_categories_parent
+            FOREIGN KEY (parent_id) REFERENCES categories(category_id) ON DELETE RESTRICT;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'categories_parent_not_self') THEN
+        ALTER TABLE categories
+            ADD CONSTRAINT categories_parent_not_self CHECK (parent_id <> category_id);
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- 0009_product_listing.sql
+-- index สำหรับการแสดงรายการสินค้าแบบแบ่งหน้าด้วย cursor
+-- แต่ละ index ตรงกับการเรียงหนึ่งแบบ (คอลัมน์ที่เรียง + product_id) และข้ามสินค้าที่ถูกลบ
+-- ต้องรันหลัง 0008_category_tree.sql
+
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS idx_products_listing_created_at ON products(created_at, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_listing_price ON products(price, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_listing_discount ON products(discount, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_listing_name ON products(name, product_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_seller_id ON products(seller_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_brand ON products(LOWER(brand)) WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,210 @@
+-- 0010_product_variants.sql
+-- ตัวเลือกของสินค้า (สี ขนาด รุ่น) ที่มี SKU ราคา สต็อก และรูปของตัวเองได้
+-- สินค้าเดิมทุกชิ้นได้ตัวเลือกหลักหนึ่งตัวที่รับสต็อก ประวัติสต็อก การจอง ตะกร้า และคำสั่งซื้อเดิมไปทั้งหมด
+-- inventory เปลี่ยนเป็นหนึ่งแถวต่อตัวเลือก ส่วน products.product_stock เป็นผลรวมของทุกตัวเลือก
+-- ต้องรันหลัง 0009_product_listing.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    variant_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    color VARCHAR(50),
+    size VARCHAR(50),
+    model VARCHAR(50),
+    price NUMERIC(10, 2) CHECK (price >= 0),
+    image_urls TEXT[] NOT NULL DEFAULT '{}',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_variants_default ON product_variants(product_id) WHERE is_default;
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants(product_id) WHERE deleted_at IS NULL;
+
+DROP TRIGGER IF EXISTS update_product_variants_updated_at ON product_variants;
+CREATE TRIGGER update_product_variants_updated_at BEFORE UPDATE ON product_variants
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+-- ตัวเลือกหลักของสินค้าที่มีอยู่เดิม
+INSERT INTO product_variants (product_id, sku, is_default)
+SELECT p.product_id, 'P' || p.product_id || '-DEFAULT', TRUE
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.product_id AND v.is_default);
+
+-- inventory: ย้ายสต็อกเดิมไปไว้ที่ตัวเลือกหลัก แล้วเปลี่ยน primary key เป็น variant_id
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS variant_id INT;
+
+UPDATE inventory i
+SET variant_id = v.variant_id
+FROM product_variants v
+WHERE v.product_id = i.product_id AND v.is_default AND i.variant_id IS NULL;
+
+ALTER TABLE inventory ALTER COLUMN variant_id SET NOT NULL;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint c
+        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+        WHERE c.conrelid = 'inventory'::regclass AND c.contype = 'p' AND a.attname = 'product_id'
+    ) THEN
+        ALTER TABLE inventory DROP CONSTRAINT inventory_pkey;
+        ALTER TABLE inventory ADD PRIMARY KEY (variant_id);
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'inventory_variant_id_fkey') THEN
+        ALTER TABLE inventory
+            ADD CONSTRAINT inventory_variant_id_fkey
+            FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE;
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_inventory_product_id ON inventory(product_id);
+
+CREATE OR REPLACE VIEW product_inventory AS
+SELECT product_id, SUM(quantity)::INT AS quantity, MAX(updated_at) AS updated_at
+FROM inventory
+GROUP BY product_id;
+
+-- stock_movements เพิ่มได้อย่างเดียว จึงต้องปิด trigger ชั่วคราวเพื่อเติม variant_id ให้ประวัติเดิม
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS variant_id INT;
+ALTER TABLE stock_movements DISABLE TRIGGER stock_movements_append_only;
+
+UPDATE stock_movements m
+SET variant_id = v.variant_id
+FROM product_variants v
+WHERE v.product_id = m.product_id AND v.is_default AND m.variant_id IS NULL;
+
+ALTER TABLE stock_movements ENABLE TRIGGER stock_movements_append_only;
+ALTER TABLE stock_movements ALTER COLUMN variant_id SET NOT NULL;
+
+-- การจอง ตะกร้า และคำสั่งซื้อเดิมอ้างอิงตัวเลือกหลัก
+ALTER TABLE stock_reservations ADD COLUMN IF NOT EXISTS variant_id INT;
+UPDATE stock_reservations r
+SET variant_id = v.variant_id
+FROM product_variants v
+WHERE v.product_id = r.product_id AND v.is_default AND r.variant_id IS NULL;
+ALTER TABLE stock_reservations ALTER COLUMN variant_id SET NOT NULL;
+
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS variant_id INT;
+UPDATE cart_items ci
+SET variant_id = v.variant_id
+FROM product_variants v
+WHERE v.product_id = ci.product_id AND v.is_default AND ci.variant_id IS NULL;
+ALTER TABLE cart_items ALTER COLUMN variant_id SET NOT NULL;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS variant_id INT;
+UPDATE order_items oi
+SET variant_id = v.variant_id
+FROM product_variants v
+WHERE v.product_id = oi.product_id AND v.is_default AND oi.variant_id IS NULL;
+ALTER TABLE order_items ALTER COLUMN variant_id SET NOT NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'stock_movements_variant_id_fkey') THEN
+        ALTER TABLE stock_movements
+            ADD CONSTRAINT stock_movements_variant_id_fkey
+            FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'stock_reservations_variant_id_fkey') THEN
+        ALTER TABLE stock_reservations
+            ADD CONSTRAINT stock_reservations_variant_id_fkey
+            FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'cart_items_variant_id_fkey') THEN
+        ALTER TABLE cart_items
+            ADD CONSTRAINT cart_items_variant_id_fkey
+            FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'order_items_variant_id_fkey') THEN
+        ALTER TABLE order_items
+            ADD CONSTRAINT order_items_variant_id_fkey
+            FOREIGN KEY (variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE;
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_variant_id ON stock_movements(variant_id, movement_id);
+DROP INDEX IF EXISTS idx_stock_reservations_product_id;
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_variant_id ON stock_reservations(variant_id, expires_at);
+
+-- trigger: สินค้าใหม่ได้ตัวเลือกหลัก ตัวเลือกใหม่ได้แถว inventory และ product_stock คือผลรวมของทุกตัวเลือก
+CREATE OR REPLACE FUNCTION create_default_variant_for_product()
+RETURNS TRIGGER AS $$
+DECLARE
+    default_variant_id INT;
+BEGIN
+    INSERT INTO product_variants (product_id, sku, is_default)
+    VALUES (NEW.product_id, 'P' || NEW.product_id || '-DEFAULT', TRUE)
+    RETURNING variant_id INTO default_variant_id;
+
+    IF COALESCE(NEW.product_stock, 0) > 0 THEN
+        UPDATE inventory SET quantity = NEW.product_stock, updated_at = CURRENT_TIMESTAMP
+        WHERE variant_id = default_variant_id;
+
+        INSERT INTO stock_movements (product_id, variant_id, change, quantity_after, reason, note)
+        VALUES (NEW.product_id, default_variant_id, NEW.product_stock, NEW.product_stock, 'restock', 'initial stock');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION create_inventory_for_variant()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO inventory (variant_id, product_id, quantity)
+    VALUES (NEW.variant_id, NEW.product_id, 0)
+    ON CONFLICT (variant_id) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION sync_product_stock_from_inventory()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_product_id INT := COALESCE(NEW.product_id, OLD.product_id);
+BEGIN
+    UPDATE products
+    SET product_stock = (SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE product_id = changed_product_id)
+    WHERE product_id = changed_product_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION enforce_product_stock_from_inventory()
+RETURNS TRIGGER AS $$
+DECLARE
+    inventory_quantity INT;
+BEGIN
+    SELECT SUM(quantity) INTO inventory_quantity FROM inventory WHERE product_id = NEW.product_id;
+    IF inventory_quantity IS NOT NULL THEN
+        NEW.product_stock := inventory_quantity;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE 'plpgsql';
+
+DROP TRIGGER IF EXISTS create_products_inventory ON products;
+DROP FUNCTION IF EXISTS create_inventory_for_product();
+
+DROP TRIGGER IF EXISTS create_products_default_variant ON products;
+CREATE TRIGGER create_products_default_variant AFTER INSERT ON products
+FOR EACH ROW EXECUTE PROCEDURE create_default_variant_for_product();
+
+DROP TRIGGER IF EXISTS create_variants_inventory ON product_variants;
+CREATE TRIGGER create_variants_inventory AFTER INSERT ON product_variants
+FOR EACH ROW EXECUTE PROCEDURE create_inventory_for_variant();
+
+DROP TRIGGER IF EXISTS sync_inventory_product_stock ON inventory;
+CREATE TRIGGER sync_inventory_product_stock AFTER INSERT OR UPDATE OF quantity OR DELETE ON inventory
+FOR EACH ROW EXECUTE PROCEDURE sync_product_stock_from_inventory();
+
+COMMIT;
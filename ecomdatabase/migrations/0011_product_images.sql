@@ -0,0 +1,36 @@
+-- 0011_product_images.sql
+-- แกลเลอรีรูปของสินค้า (อัปโหลดได้หลายรูป มี thumbnail ลำดับ และ alt text)
+-- รูปเดิมใน products.image_url กลายเป็นรูปแรกของแกลเลอรี (ลิงก์ภายนอก ไม่มีไฟล์ใน storage)
+-- ต้องรันหลัง 0010_product_variants.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS product_images (
+    image_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    alt_text VARCHAR(255) NOT NULL DEFAULT '',
+    position INT NOT NULL DEFAULT 0 CHECK (position >= 0),
+    thumbnails JSONB NOT NULL DEFAULT '{}',
+    storage_keys TEXT[] NOT NULL DEFAULT '{}',
+    content_type VARCHAR(50),
+    width INT,
+    height INT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id ON product_images(product_id, position);
+
+DROP TRIGGER IF EXISTS update_product_images_updated_at ON product_images;
+CREATE TRIGGER update_product_images_updated_at BEFORE UPDATE ON product_images
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+INSERT INTO product_images (product_id, url)
+SELECT p.product_id, p.image_url
+FROM products p
+WHERE p.image_url IS NOT NULL AND p.image_url <> ''
+  AND NOT EXISTS (SELECT 1 FROM product_images pi WHERE pi.product_id = p.product_id);
+
+COMMIT;
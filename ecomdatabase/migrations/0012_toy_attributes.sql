@@ -0,0 +1,50 @@
+-- 0012_toy_attributes.sql
+-- ข้อมูลจำเพาะของของเล่นแบบมีชนิดข้อมูล: ช่วงอายุ (เดือน) ชนิดและจำนวนถ่าน ขนาด น้ำหนัก และวัสดุ
+-- เติมชนิด/จำนวนถ่านและขนาดจากคำอธิบายเดิมที่เขียนไว้ในรูปแบบที่พบบ่อย
+-- ("ใช้ถ่าน AA 2 ก้อน", "ไม่ต้องใช้ถ่าน", "ขนาดสินค้า 12 x 11.5 ซม.") ส่วนที่เหลือให้ผู้ขายกรอกเอง
+-- ต้องรันหลัง 0011_product_images.sql
+
+BEGIN;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS min_age_months INTEGER CHECK (min_age_months >= 0),
+    ADD COLUMN IF NOT EXISTS max_age_months INTEGER CHECK (max_age_months >= 0),
+    ADD COLUMN IF NOT EXISTS battery_type VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS battery_count INTEGER CHECK (battery_count > 0),
+    ADD COLUMN IF NOT EXISTS length_cm NUMERIC(7, 1) CHECK (length_cm > 0),
+    ADD COLUMN IF NOT EXISTS width_cm NUMERIC(7, 1) CHECK (width_cm > 0),
+    ADD COLUMN IF NOT EXISTS height_cm NUMERIC(7, 1) CHECK (height_cm > 0),
+    ADD COLUMN IF NOT EXISTS weight_g INTEGER CHECK (weight_g > 0),
+    ADD COLUMN IF NOT EXISTS material VARCHAR(100);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_age_range') THEN
+        ALTER TABLE products ADD CONSTRAINT products_age_range CHECK (max_age_months >= min_age_months);
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_products_min_age_months ON products(min_age_months) WHERE deleted_at IS NULL;
+
+-- "ใช้ถ่าน AA 2 ก้อน", "ใส่ถ่าน AA จำนวน 3 ก้อน"
+UPDATE products
+SET battery_type = substring(description FROM 'ถ่าน\s*(AAA|AA)'),
+    battery_count = substring(description FROM 'ถ่าน\s*(?:AAA|AA)\D{0,20}(\d+)\s*ก้อน')::INT
+WHERE battery_type IS NULL AND description ~ 'ถ่าน\s*(AAA|AA)';
+
+-- "ไม่ต้องใช้ถ่าน", "ไม่ใช้ถ่าน"
+UPDATE products
+SET battery_type = 'none'
+WHERE battery_type IS NULL AND description ~ 'ไม่(ต้อง)?ใช้ถ่าน';
+
+-- "ขนาดสินค้า 12 x 11.5 ซม." หรือ "ขนาด 20 x 15 x 10 ซม."
+UPDATE products p
+SET length_cm = d.m[1]::NUMERIC, width_cm = d.m[2]::NUMERIC, height_cm = d.m[3]::NUMERIC
+FROM (
+    SELECT product_id,
+           regexp_match(description, 'ขนาด\D{0,10}([0-9]+(?:\.[0-9]+)?)\s*[xX×]\s*([0-9]+(?:\.[0-9]+)?)(?:\s*[xX×]\s*([0-9]+(?:\.[0-9]+)?))?\s*ซม') AS m
+    FROM products
+) d
+WHERE d.product_id = p.product_id AND d.m IS NOT NULL AND p.length_cm IS NULL;
+
+COMMIT;
@@ -0,0 +1,61 @@
+-- 0013_child_profiles.sql
+-- หมวดหมู่ระดับบนสุดเป็นช่วงอายุ จึงเก็บช่วงอายุ (เดือน) ไว้ในหมวดหมู่ด้วย หมวดหมู่ย่อยใช้ช่วงอายุของหมวดหมู่แม่
+-- และเพิ่มโปรไฟล์ลูก (เดือนเกิด ความสนใจ) ของผู้ใช้ สำหรับแนะนำของเล่นที่เหมาะกับอายุปัจจุบันของเด็ก
+-- ต้องรันหลัง 0012_toy_attributes.sql
+
+BEGIN;
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS min_age_months INT CHECK (min_age_months >= 0),
+    ADD COLUMN IF NOT EXISTS max_age_months INT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'categories_age_range') THEN
+        ALTER TABLE categories ADD CONSTRAINT categories_age_range
+            CHECK (max_age_months IS NULL OR (min_age_months IS NOT NULL AND max_age_months >= min_age_months));
+    END IF;
+END$$;
+
+-- ช่วงอายุของหมวดหมู่ตัวอย่างเดิม
+UPDATE categories SET min_age_months = 0, max_age_months = 6
+WHERE name = 'แรกเกิด - 6 เดือน' AND min_age_months IS NULL;
+UPDATE categories SET min_age_months = 7, max_age_months = 11
+WHERE name = '7 - 11 เดือน' AND min_age_months IS NULL;
+UPDATE categories SET min_age_months = 12, max_age_months = 35
+WHERE name = '1 - 2 ขวบ' AND min_age_months IS NULL;
+UPDATE categories SET min_age_months = 36
+WHERE name = '3 ขวบขึ้นไป' AND min_age_months IS NULL;
+
+CREATE OR REPLACE VIEW category_age_bands AS
+WITH RECURSIVE ancestors AS (
+    SELECT category_id AS leaf_id, parent_id, min_age_months, max_age_months, 0 AS depth
+    FROM categories
+    UNION ALL
+    SELECT a.leaf_id, c.parent_id, c.min_age_months, c.max_age_months, a.depth + 1
+    FROM categories c JOIN ancestors a ON c.category_id = a.parent_id
+    WHERE a.min_age_months IS NULL
+)
+SELECT DISTINCT ON (leaf_id) leaf_id AS category_id, min_age_months, max_age_months
+FROM ancestors
+WHERE min_age_months IS NOT NULL
+ORDER BY leaf_id, depth;
+
+CREATE TABLE IF NOT EXISTS child_profiles (
+    child_id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    birth_month DATE NOT NULL CHECK (EXTRACT(DAY FROM birth_month) = 1),
+    interests TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_child_profiles_user_id ON child_profiles(user_id);
+
+DROP TRIGGER IF EXISTS update_child_profiles_updated_at ON child_profiles;
+CREATE TRIGGER update_child_profiles_updated_at BEFORE UPDATE ON child_profiles
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- 0014_product_safety.sql
+-- ฉลากความปลอดภัยของสินค้า: ความเสี่ยงที่ต้องเตือน (เช่น small_parts ชิ้นส่วนเล็ก) เลขที่ มอก. และมาตรฐานอื่น
+-- ตะกร้าบันทึกเวลาที่ผู้ใช้ยืนยันคำเตือนเมื่อมีลูกอายุต่ำกว่าอายุขั้นต่ำของสินค้า
+-- ติดฉลากชิ้นส่วนเล็กให้สินค้าตัวอย่างเดิมชุดเดียวกับ init.sql ส่วนสินค้าอื่นให้ผู้ขายกรอกฉลากเอง
+-- ต้องรันหลัง 0013_child_profiles.sql
+
+BEGIN;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS hazards TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS tis_number VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS certifications TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS safety_acknowledged_at TIMESTAMPTZ;
+
+-- ฉลากของสินค้าตัวอย่างเดิมที่มีชิ้นส่วนเล็ก
+UPDATE products SET hazards = '{small_parts}', tis_number = COALESCE(tis_number, 'มอก. 685-2540')
+WHERE name IN ('ตัวเลขไม้', 'เลโก้พิพิธภัณฑ์ไดโนเสาร์', 'ตัวต่อเลโก้รถแข่ง') AND hazards = '{}';
+UPDATE products SET certifications = '{EN 71}'
+WHERE name IN ('เลโก้พิพิธภัณฑ์ไดโนเสาร์', 'ตัวต่อเลโก้รถแข่ง') AND certifications = '{}';
+
+COMMIT;
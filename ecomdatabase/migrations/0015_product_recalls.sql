@@ -0,0 +1,47 @@
+-- 0015_product_recalls.sql
+-- เพิ่มการเรียกคืนสินค้า (ทั้งสินค้าหรือเฉพาะบางตัวเลือก/ช่วงวันที่ขาย) และการแจ้งเตือนถึงลูกค้าที่เคยซื้อ
+-- ต้องรันหลัง 0014_product_safety.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS product_recalls (
+    recall_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    variant_ids INT[] NOT NULL DEFAULT '{}',
+    sold_from TIMESTAMPTZ,
+    sold_until TIMESTAMPTZ,
+    reason TEXT NOT NULL,
+    instructions TEXT,
+    status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'closed')),
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMPTZ,
+    CHECK (sold_until IS NULL OR sold_from IS NULL OR sold_until >= sold_from),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    notification_id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    recall_id INT,
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, recall_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (recall_id) REFERENCES product_recalls(recall_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_recalls_active ON product_recalls(product_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_order_items_product_id ON order_items(product_id);
+
+DROP TRIGGER IF EXISTS update_product_recalls_updated_at ON product_recalls;
+CREATE TRIGGER update_product_recalls_updated_at BEFORE UPDATE ON product_recalls
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,67 @@
+-- 0016_shipments.sql
+-- ย้ายสถานะการจัดส่งจาก cart_items.status มาเป็นการจัดส่งหนึ่งรายการต่อคำสั่งซื้อและร้านค้า
+-- พร้อมเวลาที่เข้าสู่แต่ละสถานะและประวัติการเปลี่ยนสถานะ (cart_items.status ยังถูกอัปเดตตามเพื่อให้ของเดิมใช้ได้)
+-- ต้องรันหลัง 0015_product_recalls.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS shipments (
+    shipment_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    seller_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing'
+        CHECK (status IN ('processing', 'packed', 'shipping', 'delivered', 'received', 'cancelled', 'returned')),
+    carrier VARCHAR(100),
+    tracking_number VARCHAR(100),
+    processing_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    packed_at TIMESTAMPTZ,
+    shipping_at TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    returned_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (order_id, seller_id),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY (seller_id) REFERENCES sellers(seller_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shipment_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    shipment_id INT NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    note TEXT,
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (shipment_id) REFERENCES shipments(shipment_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipments_status ON shipments(seller_id, status);
+CREATE INDEX IF NOT EXISTS idx_shipment_events_shipment_id ON shipment_events(shipment_id, created_at);
+
+DROP TRIGGER IF EXISTS update_shipments_updated_at ON shipments;
+CREATE TRIGGER update_shipments_updated_at BEFORE UPDATE ON shipments
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+-- สร้างการจัดส่งของคำสั่งซื้อเดิมจากสถานะใน cart_items (ทุกรายการของร้านเดียวกันมีสถานะเดียวกัน)
+-- สถานะที่ไม่รู้จักถือเป็น processing และไม่มีเวลาของสถานะอื่นนอกจากวันที่สั่งซื้อ
+INSERT INTO shipments (order_id, seller_id, status, processing_at, created_at)
+SELECT oi.order_id, oi.seller_id,
+       CASE WHEN MIN(ci.status) IN ('processing', 'packed', 'shipping', 'delivered', 'received', 'cancelled', 'returned')
+            THEN MIN(ci.status) ELSE 'processing' END,
+       MIN(o.order_date), MIN(o.order_date)
+FROM order_items oi
+JOIN orders o ON o.order_id = oi.order_id
+JOIN cart_items ci ON ci.cart_item_id = oi.cart_item_id
+GROUP BY oi.order_id, oi.seller_id
+ON CONFLICT (order_id, seller_id) DO NOTHING;
+
+INSERT INTO shipment_events (shipment_id, to_status, note, created_at)
+SELECT sh.shipment_id, sh.status, 'ยกมาจากสถานะเดิมใน cart_items', sh.processing_at
+FROM shipments sh
+WHERE NOT EXISTS (SELECT 1 FROM shipment_events e WHERE e.shipment_id = sh.shipment_id);
+
+COMMIT;
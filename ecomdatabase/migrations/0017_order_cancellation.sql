@@ -0,0 +1,22 @@
+-- 0017_order_cancellation.sql
+-- ยกเลิกคำสั่งซื้อได้ก่อนส่งของออก (ผู้ซื้อทั้งคำสั่งซื้อ ผู้ขายเฉพาะร้านของตัวเอง) สินค้าถูกคืนเข้าคลัง
+-- ด้วย movement แบบ cancellation และเก็บผู้ยกเลิกกับเหตุผลไว้ในการจัดส่ง
+-- ต้องรันหลัง 0016_shipments.sql
+
+BEGIN;
+
+ALTER TYPE stock_movement_reason ADD VALUE IF NOT EXISTS 'cancellation';
+
+ALTER TABLE shipments
+    ADD COLUMN IF NOT EXISTS cancelled_by UUID,
+    ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_shipments_cancelled_by') THEN
+        ALTER TABLE shipments ADD CONSTRAINT fk_shipments_cancelled_by
+            FOREIGN KEY (cancelled_by) REFERENCES users(user_id) ON DELETE SET NULL;
+    END IF;
+END$$;
+
+COMMIT;
@@ -0,0 +1,71 @@
+-- 0018_returns.sql
+-- คำขอคืนสินค้า (RMA) พร้อมรูปประกอบ ผู้ขายอนุมัติหรือปฏิเสธ รายการที่อนุมัติถูกคืนเข้าคลังหรือตัดทิ้ง
+-- และสร้างรายการคืนเงินอ้างอิงคำสั่งซื้อ
+-- ต้องรันหลัง 0017_order_cancellation.sql
+
+BEGIN;
+
+ALTER TYPE stock_movement_reason ADD VALUE IF NOT EXISTS 'write_off';
+
+CREATE TABLE IF NOT EXISTS return_requests (
+    return_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    shipment_id INT NOT NULL,
+    seller_id INT NOT NULL,
+    user_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'approved', 'rejected')),
+    reason TEXT NOT NULL,
+    decision_note TEXT,
+    decided_by UUID,
+    decided_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY (shipment_id) REFERENCES shipments(shipment_id) ON DELETE CASCADE,
+    FOREIGN KEY (seller_id) REFERENCES sellers(seller_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (decided_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    return_item_id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    disposition VARCHAR(20) CHECK (disposition IN ('restock', 'write_off')),
+    UNIQUE (return_id, order_item_id),
+    FOREIGN KEY (return_id) REFERENCES return_requests(return_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS return_photos (
+    photo_id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    thumbnails JSONB NOT NULL DEFAULT '{}',
+    storage_keys TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (return_id) REFERENCES return_requests(return_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS refunds (
+    refund_id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL UNIQUE,
+    order_id INT NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount >= 0),
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (return_id) REFERENCES return_requests(return_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_requests_user_id ON return_requests(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_return_requests_seller_id ON return_requests(seller_id, status);
+CREATE INDEX IF NOT EXISTS idx_return_items_order_item_id ON return_items(order_item_id);
+
+DROP TRIGGER IF EXISTS update_return_requests_updated_at ON return_requests;
+CREATE TRIGGER update_return_requests_updated_at BEFORE UPDATE ON return_requests
+FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+COMMIT;
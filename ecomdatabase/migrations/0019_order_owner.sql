@@ -0,0 +1,29 @@
+-- 0019_order_owner.sql
+-- ผูกคำสั่งซื้อกับผู้ซื้อ (orders.user_id) แทนการหาจากเจ้าของตะกร้าของรายการในคำสั่งซื้อ
+-- คำสั่งซื้อเดิมใช้เจ้าของตะกร้าของรายการ คำสั่งซื้อที่ไม่มีรายการเหลือแล้ว
+-- หรือสั่งซื้อก่อนตะกร้ามีเจ้าของ (0001_cart_owner.sql) จะเป็น NULL
+-- ต้องรันหลัง 0018_returns.sql
+
+BEGIN;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS user_id UUID;
+
+UPDATE orders o
+SET user_id = (
+    SELECT ci.user_id FROM order_items oi
+    JOIN cart_items ci ON ci.cart_item_id = oi.cart_item_id
+    WHERE oi.order_id = o.order_id AND ci.user_id IS NOT NULL
+    LIMIT 1)
+WHERE o.user_id IS NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_orders_user') THEN
+        ALTER TABLE orders ADD CONSTRAINT fk_orders_user
+            FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL;
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id, order_date);
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- 0020_seller_name_unique.sql
+-- ชื่อร้านห้ามซ้ำกันแบบไม่สนตัวพิมพ์ (เช่น "Fun Zone" กับ "fun zone") ให้ฐานข้อมูลตรวจเองด้วย unique index
+-- แทนการเช็กก่อน INSERT อย่างเดียว ซึ่งกันการลงทะเบียนพร้อมกันจากคนละบัญชีไม่ได้
+-- ถ้ามีชื่อร้านที่ซ้ำกันอยู่แล้ว migration จะหยุดพร้อมรายชื่อ ให้เปลี่ยนชื่อร้านก่อนแล้วรันใหม่
+-- ต้องรันหลัง 0019_order_owner.sql
+
+BEGIN;
+
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(names, '; ') INTO duplicates
+    FROM (
+        SELECT string_agg(name || ' (' || seller_id || ')', ', ' ORDER BY seller_id) AS names
+        FROM sellers
+        GROUP BY LOWER(name)
+        HAVING COUNT(*) > 1
+    ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'seller names differ only by case, rename them first: %', duplicates;
+    END IF;
+END$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sellers_name_lower ON sellers(LOWER(name));
+
+COMMIT;
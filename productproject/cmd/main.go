@@ -7,9 +7,13 @@ import (
 	"log"
 	"productproject/internal/config"
 	"productproject/internal/handlers"
+	"productproject/internal/inventory"
+	"productproject/internal/media"
+	"productproject/internal/middleware"
 
 	product "productproject/internal/product"
 
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -32,31 +36,83 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// ต้องใช้ secret เดียวกับ login service เพื่อตรวจสอบ token
+	if cfg.JWTSecret == "" {
+		log.Fatalf("JWT_SECRET is required")
+	}
+
 	db, err := product.NewPostgresDatabase(cfg.GetConnectionString())
 	if err != nil {
 		log.Printf("Failed to connect to database: %v", err)
 	}
 	if db != nil {
 		defer db.Close()
+		db.SetReservationTTL(cfg.ReservationTTL)
 	}
 
 	store := product.NewStore(db)
 	h := handlers.NewProductHandlers(store)
+	inv := inventory.NewService(db)
+	ih := handlers.NewInventoryHandlers(inv, store)
+
+	// รูปสินค้าที่อัปโหลดเก็บบนดิสก์เป็นค่าเริ่มต้น (backend อื่นต้องทำ media.Storage)
+	storage, err := media.NewLocalStorage(cfg.MediaDir, cfg.MediaBaseURL)
+	if err != nil {
+		log.Fatalf("Failed to set up media storage: %v", err)
+	}
+	mediaService := media.NewService(storage)
+	mh := handlers.NewMediaHandlers(store, mediaService)
+	rh := handlers.NewReturnHandlers(store, mediaService)
+
+	// งานเบื้องหลังทั้งหมดต้องใช้ฐานข้อมูล ข้ามไปเมื่อเชื่อมต่อไม่สำเร็จตอนเริ่ม
+	if db != nil {
+		go func() {
+			for {
+				time.Sleep(10 * time.Second)
+				if err := db.Ping(); err != nil {
+					log.Printf("Database connection lost: %v", err)
+					// พยายามเชื่อมต่อใหม่
+					if reconnErr := db.Reconnect(cfg.GetConnectionString()); reconnErr != nil {
+						log.Printf("Failed to reconnect: %v", reconnErr)
+					} else {
+						log.Printf("Successfully reconnected to the database")
+					}
+				}
+			}
+		}()
 
-	go func() {
-		for {
-			time.Sleep(10 * time.Second)
-			if err := db.Ping(); err != nil {
-				log.Printf("Database connection lost: %v", err)
-				// พยายามเชื่อมต่อใหม่
-				if reconnErr := db.Reconnect(cfg.GetConnectionString()); reconnErr != nil {
-					log.Printf("Failed to reconnect: %v", reconnErr)
-				} else {
-					log.Printf("Successfully reconnected to the database")
+		// ปล่อยสินค้าที่จองไว้ในตะกร้าที่ไม่มีการเคลื่อนไหวจนหมดอายุ
+		go func() {
+			for {
+				time.Sleep(time.Minute)
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				released, err := inv.ReleaseExpiredReservations(ctx)
+				cancel()
+				if err != nil {
+					log.Printf("Failed to release expired reservations: %v", err)
+				} else if released > 0 {
+					log.Printf("Released %d expired cart reservation(s)", released)
 				}
 			}
+		}()
+
+		// สร้าง index ค้นหาสินค้าตอนเริ่มและสร้างใหม่เป็นระยะ เพื่อให้ข้อมูลที่เปลี่ยนนอก API (เช่นแก้ในฐานข้อมูลตรง) ถูกค้นเจอ
+		searchRefresh := cfg.SearchRefresh
+		if searchRefresh <= 0 {
+			searchRefresh = 5 * time.Minute
 		}
-	}()
+		go func() {
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				err := db.RefreshSearchIndex(ctx)
+				cancel()
+				if err != nil {
+					log.Printf("Failed to refresh search index: %v", err)
+				}
+				time.Sleep(searchRefresh)
+			}
+		}()
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
@@ -64,7 +120,7 @@ func main() {
 	// กำหนดค่า CORS
 	configCors := cors.Config{
 		AllowOrigins:     []string{"*"}, // "*" ยอมรับทุกโดเมน
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -76,6 +132,17 @@ func main() {
 
 	r.GET("/health", h.HealthCheck)
 
+	// ให้บริการไฟล์รูปจาก local storage เมื่อ MEDIA_BASEURL เป็น path ของ service นี้เอง
+	if strings.HasPrefix(cfg.MediaBaseURL, "/") {
+		r.Static(cfg.MediaBaseURL, cfg.MediaDir)
+	}
+
+	// ตรวจสอบ JWT ที่ออกโดย login service สำหรับเส้นทางที่ต้องล็อกอิน
+	authRequired := middleware.AuthMiddleware(&cfg)
+	// จัดการสินค้าและสต็อกได้เฉพาะผู้ขายและผู้ดูแลระบบ
+	productAdmin := middleware.RequireRole(store, "seller", "admin")
+	adminOnly := middleware.RequireRole(store, "admin")
+
 	// API v1
 	v1 := r.Group("/api/v1")
 	{
@@ -86,13 +153,64 @@ func main() {
 			products.GET("/recommend", h.GetProductRecommend)
 			products.GET("/new", h.GetNewProduct)
 			products.GET("/search", h.SearchProduct)
+			products.GET("/suggest", h.SuggestProducts)
+			products.GET("/safety/hazards", h.GetSafetyHazards)
 			products.GET("/category/:category", h.GetProductByCategory)
+			products.GET("/for-child/:child_id", authRequired, h.ProductsForChild)
+
+			products.POST("", authRequired, productAdmin, h.CreateProduct)
+			products.PUT("/:id", authRequired, productAdmin, h.ReplaceProduct)
+			products.PATCH("/:id", authRequired, productAdmin, h.PatchProduct)
+			products.DELETE("/:id", authRequired, productAdmin, h.DeleteProduct)
+
+			// ตัวเลือกของสินค้า (สี ขนาด รุ่น)
+			products.GET("/:id/variants", h.GetVariants)
+			products.POST("/:id/variants", authRequired, productAdmin, h.CreateVariant)
+			products.PATCH("/:id/variants/:variant_id", authRequired, productAdmin, h.UpdateVariant)
+			products.DELETE("/:id/variants/:variant_id", authRequired, productAdmin, h.DeleteVariant)
+
+			// แกลเลอรีรูปของสินค้า (อัปโหลดแบบ multipart/form-data)
+			products.GET("/:id/images", mh.GetProductImages)
+			products.POST("/:id/images", authRequired, productAdmin, mh.UploadProductImage)
+			products.PUT("/:id/images/order", authRequired, productAdmin, mh.ReorderProductImages)
+			products.PATCH("/:id/images/:image_id", authRequired, productAdmin, mh.UpdateProductImage)
+			products.DELETE("/:id/images/:image_id", authRequired, productAdmin, mh.DeleteProductImage)
+		}
+		// หมวดหมู่แบบต้นไม้ (แก้ไขได้เฉพาะผู้ดูแลระบบ)
+		categories := v1.Group("/categories")
+		{
+			categories.GET("", h.GetCategories)
+			categories.POST("", authRequired, adminOnly, h.CreateCategory)
+			categories.PATCH("/:id", authRequired, adminOnly, h.UpdateCategory)
+			categories.DELETE("/:id", authRequired, adminOnly, h.DeleteCategory)
+		}
+		// การเรียกคืนสินค้า (ผู้ดูแลระบบ) สินค้าถูกซ่อนและหยุดขาย และลูกค้าที่เคยซื้อได้รับการแจ้งเตือน
+		recalls := v1.Group("/recalls", authRequired, adminOnly)
+		{
+			recalls.GET("", h.GetRecalls)
+			recalls.POST("", h.CreateRecall)
+			recalls.GET("/:id", h.GetRecall)
+			recalls.GET("/:id/report", h.GetRecallReport)
+			recalls.POST("/:id/close", h.CloseRecall)
+		}
+		// ประวัติและการปรับสต็อก (stock_movements)
+		stock := v1.Group("/inventory", authRequired)
+		{
+			stock.GET("/:product_id/movements", productAdmin, ih.GetStockMovements)
+			stock.POST("/:product_id/movements", productAdmin, ih.RecordStockMovement)
 		}
 		seller := v1.Group("/seller")
 		{
 			seller.GET("/:id", h.GetSeller)
+
+			// ลงทะเบียนและจัดการร้านค้าของตัวเอง
+			seller.POST("", authRequired, h.RegisterSeller)
+			seller.GET("/me", authRequired, h.GetMySeller)
+			seller.GET("/me/orders", authRequired, h.GetMySellerOrders)
+			seller.PATCH("/:id", authRequired, productAdmin, h.UpdateSeller)
+			seller.POST("/:id/deactivate", authRequired, productAdmin, h.DeactivateSeller)
 		}
-		cart := v1.Group("/cart")
+		cart := v1.Group("/cart", authRequired)
 		{
 			cart.GET("/allcart", h.GetAllCartItems)
 			cart.POST("/addcart", h.AddToCart)
@@ -100,7 +218,7 @@ func main() {
 			cart.DELETE("/deletecart", h.DeleteCartItem)
 		}
 		// User
-		users := v1.Group("/users")
+		users := v1.Group("/users", authRequired)
 		{
 			// ใช้ UserHandlers สำหรับเส้นทางที่เกี่ยวข้องกับผู้ใช้
 			userHandlers := handlers.NewUserHandlers(store) // สร้าง instance ของ UserHandlers
@@ -108,17 +226,53 @@ func main() {
 			// เส้นทาง "/users/me" สำหรับดึงข้อมูลของผู้ใช้ที่ล็อกอินอยู่
 			users.GET("/me", userHandlers.GetUserProfile)
 
+			// โปรไฟล์ลูกของผู้ใช้ ใช้แนะนำของเล่นตามอายุ (/products/for-child/:child_id)
+			users.GET("/me/children", userHandlers.GetChildren)
+			users.POST("/me/children", userHandlers.CreateChild)
+			users.PATCH("/me/children/:child_id", userHandlers.UpdateChild)
+			users.DELETE("/me/children/:child_id", userHandlers.DeleteChild)
+
+			// การแจ้งเตือนของผู้ใช้ (เช่นการเรียกคืนสินค้าที่เคยซื้อ)
+			users.GET("/me/notifications", userHandlers.GetNotifications)
+			users.POST("/me/notifications/:notification_id/read", userHandlers.MarkNotificationRead)
+
 			// เส้นทาง "/users/:user_id" สำหรับดึงข้อมูลของผู้ใช้ที่ระบุ
 			users.GET("/:user_id", userHandlers.GetUserProfile)
 			users.PUT("/updateuser", h.UpdateUserContactHandler)
 		}
 
-		order := v1.Group("/order")
+		// ข้อมูลของผู้ใช้ที่ล็อกอินอยู่
+		me := v1.Group("/me", authRequired)
+		{
+			me.GET("/orders", h.GetMyOrders)
+			me.GET("/orders/:id", h.GetMyOrder)
+		}
+
+		order := v1.Group("/order", authRequired)
 		{
 			order.POST("/create", h.CreateOrder)
-			order.GET("/allorder", h.GetOrders)
-			order.GET("/:status", h.GetOrdersSort)
+			// คำสั่งซื้อของทุกคนในระบบ ผู้ซื้อดูของตัวเองที่ /me/orders
+			order.GET("/allorder", adminOnly, h.GetOrders)
+			order.GET("/:status", adminOnly, h.GetOrdersSort)
 			order.PUT("/update", h.UpdateCartItemStatusHandler)
+			order.POST("/:id/cancel", h.CancelOrder)
+		}
+		// คำขอคืนสินค้า (RMA): ผู้ซื้อเปิดคำขอพร้อมรูป ผู้ขายของร้านอนุมัติ (คืนเข้าคลังหรือตัดทิ้ง และคืนเงิน) หรือปฏิเสธ
+		returns := v1.Group("/returns", authRequired)
+		{
+			returns.GET("", rh.GetReturns)
+			returns.POST("", rh.CreateReturn)
+			returns.GET("/:id", rh.GetReturn)
+			returns.POST("/:id/approve", rh.ApproveReturn)
+			returns.POST("/:id/reject", rh.RejectReturn)
+		}
+		// การจัดส่งของแต่ละร้านในคำสั่งซื้อ สถานะเปลี่ยนได้ตามลำดับใน internal/fulfillment
+		shipments := v1.Group("/shipments", authRequired)
+		{
+			shipments.GET("", h.GetShipments)
+			shipments.GET("/:id", h.GetShipment)
+			shipments.GET("/:id/history", h.GetShipmentHistory)
+			shipments.POST("/:id/status", h.UpdateShipmentStatus)
 		}
 	}
 
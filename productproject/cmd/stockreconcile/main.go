@@ -0,0 +1,79 @@
+// main.go
+//
+// stockreconcile รายงานตัวเลือกสินค้าที่ inventory.quantity ไม่ตรงกับผลรวมใน stock_movements
+// หรือสินค้าที่ products.product_stock ไม่ตรงกับผลรวม inventory ของทุกตัวเลือก
+// และแก้ให้ตรงกันเมื่อระบุ -fix (ถือ inventory เป็นข้อมูลที่ถูกต้อง)
+//
+//	go run ./cmd/stockreconcile        # รายงานอย่างเดียว
+//	go run ./cmd/stockreconcile -fix   # รายงานและแก้
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"productproject/internal/config"
+	"productproject/internal/inventory"
+	product "productproject/internal/product"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "แก้ product_stock และ stock_movements ให้ตรงกับ inventory (สร้างแถว inventory ที่ขาดของตัวเลือกหลักจาก product_stock)")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := product.NewPostgresDatabase(cfg.GetConnectionString())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	inv := inventory.NewService(db)
+
+	var drifts []inventory.Drift
+	if *fix {
+		drifts, err = inv.Reconcile(ctx)
+	} else {
+		drifts, err = inv.FindDrift(ctx)
+	}
+	if err != nil {
+		log.Fatalf("Failed to reconcile stock: %v", err)
+	}
+
+	if len(drifts) == 0 {
+		fmt.Println("stock is consistent: no drift found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT_ID\tVARIANT_ID\tSKU\tNAME\tINVENTORY\tPRODUCT_STOCK\tPRODUCT_TOTAL\tLEDGER")
+	for _, drift := range drifts {
+		quantity := "missing"
+		if drift.Quantity != nil {
+			quantity = fmt.Sprint(*drift.Quantity)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%d\t%d\t%d\n", drift.ProductID, drift.VariantID, drift.SKU, drift.Name,
+			quantity, drift.ProductStock, drift.ProductTotal, drift.LedgerQuantity)
+	}
+	w.Flush()
+
+	if *fix {
+		fmt.Printf("fixed %d variant(s)\n", len(drifts))
+		return
+	}
+
+	fmt.Printf("%d variant(s) with stock drift, run with -fix to reconcile\n", len(drifts))
+	os.Exit(1)
+}
@@ -5,6 +5,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -17,6 +18,11 @@ type Config struct {
 	DatabasePassword string
 	DatabaseName     string
 	DatabaseSSLMode  string
+	JWTSecret        string
+	ReservationTTL   time.Duration // ระยะเวลาที่จองสินค้าไว้ให้ตะกร้าที่ไม่มีการเคลื่อนไหว
+	SearchRefresh    time.Duration // ระยะเวลาระหว่างการสร้าง index ค้นหาสินค้าใหม่ทั้งหมด
+	MediaDir         string        // โฟลเดอร์เก็บรูปสินค้าที่อัปโหลด (local storage)
+	MediaBaseURL     string        // URL ที่ใช้เข้าถึงรูปใน MediaDir เช่น "/media" หรือโดเมนของ CDN
 }
 
 func LoadConfig() (Config, error) {
@@ -31,6 +37,10 @@ func LoadConfig() (Config, error) {
 	viper.SetDefault("POSTGRES.PASSWORD", "")
 	viper.SetDefault("POSTGRES.DBNAME", "bookstore")
 	viper.SetDefault("POSTGRES.SSLMODE", "disable")
+	viper.SetDefault("RESERVATION.TTL", "15m")
+	viper.SetDefault("SEARCH.REFRESH", "5m")
+	viper.SetDefault("MEDIA.DIR", "media")
+	viper.SetDefault("MEDIA.BASEURL", "/media")
 
 	// Set config values
 	config := Config{
@@ -41,6 +51,11 @@ func LoadConfig() (Config, error) {
 		DatabasePassword: viper.GetString("POSTGRES.PASSWORD"),
 		DatabaseName:     viper.GetString("POSTGRES.DBNAME"),
 		DatabaseSSLMode:  viper.GetString("POSTGRES.SSLMODE"),
+		JWTSecret:        viper.GetString("JWT.SECRET"),
+		ReservationTTL:   viper.GetDuration("RESERVATION.TTL"),
+		SearchRefresh:    viper.GetDuration("SEARCH.REFRESH"),
+		MediaDir:         viper.GetString("MEDIA.DIR"),
+		MediaBaseURL:     viper.GetString("MEDIA.BASEURL"),
 	}
 
 	return config, nil
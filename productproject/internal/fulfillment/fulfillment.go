@@ -0,0 +1,86 @@
+// fulfillment.go
+package fulfillment
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrUnknownStatus ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อสถานะไม่ใช่สถานะของการจัดส่งที่รองรับ
+var ErrUnknownStatus = errors.New("unknown shipment status")
+
+// ErrInvalidTransition ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อเปลี่ยนสถานะการจัดส่งข้ามขั้นหรือย้อนกลับ
+// หรือเมื่อผู้เปลี่ยนไม่มีสิทธิ์เปลี่ยนเป็นสถานะนั้น
+var ErrInvalidTransition = errors.New("invalid shipment transition")
+
+// สถานะของการจัดส่ง (หนึ่งรายการต่อคำสั่งซื้อและร้านค้า)
+const (
+	Processing = "processing"
+	Packed     = "packed"
+	Shipping   = "shipping"
+	Delivered  = "delivered"
+	Received   = "received"
+	Cancelled  = "cancelled"
+	Returned   = "returned"
+)
+
+// ผู้ที่เปลี่ยนสถานะการจัดส่ง
+const (
+	ActorCustomer = "customer"
+	ActorSeller   = "seller"
+	ActorAdmin    = "admin"
+)
+
+// statuses สถานะทั้งหมดตามลำดับปกติของการจัดส่ง
+var statuses = []string{Processing, Packed, Shipping, Delivered, Received, Cancelled, Returned}
+
+// transitions สถานะถัดไปที่เปลี่ยนได้จากแต่ละสถานะ สถานะแรกคือขั้นปกติ (ใช้ใน Next)
+// cancelled และ returned เป็นสถานะสุดท้าย ยกเลิกได้จนกว่าจะส่งของออก หลังจากนั้นต้องคืนสินค้าแทน
+var transitions = map[string][]string{
+	Processing: {Packed, Cancelled},
+	Packed:     {Shipping, Cancelled},
+	Shipping:   {Delivered, Returned},
+	Delivered:  {Received, Returned},
+	Received:   {Returned},
+}
+
+// permitted สถานะที่ผู้เปลี่ยนแต่ละฝ่ายเปลี่ยนไปได้ ลูกค้ายืนยันว่าได้รับสินค้าแล้ว หรือยกเลิกก่อนส่งของออกได้
+var permitted = map[string][]string{
+	ActorCustomer: {Received, Cancelled},
+	ActorSeller:   {Packed, Shipping, Delivered, Cancelled, Returned},
+	ActorAdmin:    {Packed, Shipping, Delivered, Received, Cancelled, Returned},
+}
+
+// Statuses คืนสถานะทั้งหมดตามลำดับปกติของการจัดส่ง
+func Statuses() []string {
+	return append([]string(nil), statuses...)
+}
+
+// Valid บอกว่า status เป็นสถานะของการจัดส่งที่รองรับหรือไม่
+func Valid(status string) bool {
+	return slices.Contains(statuses, status)
+}
+
+// Next คืนสถานะถัดไปตามขั้นปกติ (processing → packed → shipping → delivered → received)
+// คืนค่าว่างเมื่อไม่มีขั้นถัดไป (cancelled และ returned ไม่ใช่ขั้นปกติ)
+func Next(status string) string {
+	if next := transitions[status]; len(next) > 0 && next[0] != Cancelled && next[0] != Returned {
+		return next[0]
+	}
+	return ""
+}
+
+// Transition ตรวจว่า actor เปลี่ยนสถานะจาก from เป็น to ได้หรือไม่
+func Transition(from, to, actor string) error {
+	if !Valid(to) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+	if !slices.Contains(transitions[from], to) {
+		return fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidTransition, from, to)
+	}
+	if !slices.Contains(permitted[actor], to) {
+		return fmt.Errorf("%w: %s cannot set status %s", ErrInvalidTransition, actor, to)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package fulfillment
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestTransition ตรวจทุกคู่สถานะ from → to ของทุกผู้เปลี่ยน
+// คู่ที่ไม่อยู่ใน allowed ต้องได้ ErrInvalidTransition
+func TestTransition(t *testing.T) {
+	type move struct{ from, to, actor string }
+	allowed := map[move]bool{
+		{Processing, Packed, ActorSeller}: true,
+		{Processing, Packed, ActorAdmin}:  true,
+
+		{Processing, Cancelled, ActorCustomer}: true,
+		{Processing, Cancelled, ActorSeller}:   true,
+		{Processing, Cancelled, ActorAdmin}:    true,
+
+		{Packed, Shipping, ActorSeller}: true,
+		{Packed, Shipping, ActorAdmin}:  true,
+
+		{Packed, Cancelled, ActorCustomer}: true,
+		{Packed, Cancelled, ActorSeller}:   true,
+		{Packed, Cancelled, ActorAdmin}:    true,
+
+		{Shipping, Delivered, ActorSeller}: true,
+		{Shipping, Delivered, ActorAdmin}:  true,
+		{Shipping, Returned, ActorSeller}:  true,
+		{Shipping, Returned, ActorAdmin}:   true,
+
+		{Delivered, Received, ActorCustomer}: true,
+		{Delivered, Received, ActorAdmin}:    true,
+		{Delivered, Returned, ActorSeller}:   true,
+		{Delivered, Returned, ActorAdmin}:    true,
+
+		{Received, Returned, ActorSeller}: true,
+		{Received, Returned, ActorAdmin}:  true,
+	}
+
+	for _, actor := range []string{ActorCustomer, ActorSeller, ActorAdmin} {
+		for _, from := range Statuses() {
+			for _, to := range Statuses() {
+				m := move{from, to, actor}
+				err := Transition(from, to, actor)
+				switch {
+				case allowed[m] && err != nil:
+					t.Errorf("Transition(%s, %s, %s) = %v, want nil", from, to, actor, err)
+				case !allowed[m] && !errors.Is(err, ErrInvalidTransition):
+					t.Errorf("Transition(%s, %s, %s) = %v, want ErrInvalidTransition", from, to, actor, err)
+				}
+			}
+		}
+	}
+}
+
+// TestTransitionCustomer ลูกค้าจัดการการจัดส่งแทนร้านไม่ได้ ยืนยันได้แค่ว่าได้รับของหรือยกเลิกก่อนส่ง
+func TestTransitionCustomer(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{Processing, Packed},
+		{Packed, Shipping},
+		{Shipping, Delivered},
+		{Shipping, Cancelled},
+		{Delivered, Returned},
+	}
+	for _, tt := range tests {
+		if err := Transition(tt.from, tt.to, ActorCustomer); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("customer Transition(%s, %s) = %v, want ErrInvalidTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+// TestTerminalStatuses cancelled และ returned เป็นสถานะสุดท้าย เปลี่ยนต่อไม่ได้แม้เป็น admin
+func TestTerminalStatuses(t *testing.T) {
+	for _, from := range []string{Cancelled, Returned} {
+		if next := Next(from); next != "" {
+			t.Errorf("Next(%s) = %q, want none", from, next)
+		}
+		for _, to := range Statuses() {
+			if err := Transition(from, to, ActorAdmin); !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("Transition(%s, %s, admin) = %v, want ErrInvalidTransition", from, to, err)
+			}
+		}
+	}
+}
+
+// TestTransitionUnknownStatus สถานะที่ไม่รู้จักต้องได้ ErrUnknownStatus
+func TestTransitionUnknownStatus(t *testing.T) {
+	for _, to := range []string{"", "lost", "Packed"} {
+		if err := Transition(Processing, to, ActorAdmin); !errors.Is(err, ErrUnknownStatus) {
+			t.Errorf("Transition(processing, %q, admin) = %v, want ErrUnknownStatus", to, err)
+		}
+	}
+	if err := Transition(Processing, Packed, "guest"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Transition by unknown actor = %v, want ErrInvalidTransition", err)
+	}
+}
+
+// TestNext ขั้นถัดไปตามลำดับปกติ
+func TestNext(t *testing.T) {
+	tests := map[string]string{
+		Processing: Packed,
+		Packed:     Shipping,
+		Shipping:   Delivered,
+		Delivered:  Received,
+		Received:   "",
+		"unknown":  "",
+	}
+	for from, want := range tests {
+		if got := Next(from); got != want {
+			t.Errorf("Next(%s) = %q, want %q", from, got, want)
+		}
+	}
+}
+
+// TestStatusesCopy การแก้ slice ที่ Statuses คืนต้องไม่กระทบลำดับภายใน
+func TestStatusesCopy(t *testing.T) {
+	Statuses()[0] = "changed"
+	if got := Statuses()[0]; got != Processing {
+		t.Errorf("Statuses()[0] = %q after caller modified its copy, want %q", got, Processing)
+	}
+}
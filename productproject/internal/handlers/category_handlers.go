@@ -0,0 +1,103 @@
+// category_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondCategoryError แปลงข้อผิดพลาดจากการจัดการหมวดหมู่เป็น HTTP response
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, product.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error managing category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func categoryIDParam(c *gin.Context) (int, bool) {
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || categoryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return 0, false
+	}
+	return categoryID, true
+}
+
+// GetCategories แสดงหมวดหมู่ทั้งหมดเป็นต้นไม้ (หมวดหมู่ระดับบนสุดพร้อมหมวดหมู่ย่อย)
+func (h *ProductHandlers) GetCategories(c *gin.Context) {
+	tree, err := h.store.GetCategoryTree(c.Request.Context())
+	if err != nil {
+		log.Printf("Error fetching categories: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดึงข้อมูลหมวดหมู่ได้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// CreateCategory สร้างหมวดหมู่ใหม่ ระบุ parent_id เพื่อสร้างเป็นหมวดหมู่ย่อย
+func (h *ProductHandlers) CreateCategory(c *gin.Context) {
+	var input product.NewCategory
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	category, err := h.store.CreateCategory(c.Request.Context(), input)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory แก้ชื่อ คำอธิบาย หรือย้ายหมวดหมู่ (parent_id เป็น 0 คือย้ายไประดับบนสุด)
+func (h *ProductHandlers) UpdateCategory(c *gin.Context) {
+	categoryID, ok := categoryIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input product.UpdateCategory
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	category, err := h.store.UpdateCategory(c.Request.Context(), categoryID, input)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory ลบหมวดหมู่ที่ไม่มีหมวดหมู่ย่อยและไม่มีสินค้า
+func (h *ProductHandlers) DeleteCategory(c *gin.Context) {
+	categoryID, ok := categoryIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteCategory(c.Request.Context(), categoryID); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
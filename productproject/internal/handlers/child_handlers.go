@@ -0,0 +1,178 @@
+// child_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondChildError แปลงข้อผิดพลาดจากการจัดการโปรไฟล์ลูกเป็น HTTP response
+func respondChildError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidChild), errors.Is(err, product.ErrInvalidListing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrChildNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Child profile not found"})
+	default:
+		log.Printf("Error managing child profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func convertChildTimes(child *product.ChildProfile, loc *time.Location) {
+	child.CreatedAt = child.CreatedAt.In(loc)
+	child.UpdatedAt = child.UpdatedAt.In(loc)
+}
+
+func respondChild(c *gin.Context, status int, child product.ChildProfile) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertChildTimes(&child, loc)
+
+	c.JSON(status, child)
+}
+
+func childIDParam(c *gin.Context) (int, bool) {
+	childID, err := strconv.Atoi(c.Param("child_id"))
+	if err != nil || childID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid child ID"})
+		return 0, false
+	}
+	return childID, true
+}
+
+// GetChildren แสดงโปรไฟล์ลูกทั้งหมดของผู้ใช้ที่ล็อกอินอยู่ พร้อมอายุปัจจุบัน (เดือน)
+func (h *UserHandlers) GetChildren(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	children, err := h.store.GetChildProfiles(c.Request.Context(), userID)
+	if err != nil {
+		respondChildError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range children {
+		convertChildTimes(&children[i], loc)
+	}
+
+	c.JSON(http.StatusOK, children)
+}
+
+// CreateChild เพิ่มโปรไฟล์ลูก (ชื่อ เดือนเกิดแบบ YYYY-MM และความสนใจ)
+func (h *UserHandlers) CreateChild(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input product.NewChildProfile
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	child, err := h.store.CreateChildProfile(c.Request.Context(), userID, input)
+	if err != nil {
+		respondChildError(c, err)
+		return
+	}
+
+	respondChild(c, http.StatusCreated, child)
+}
+
+// UpdateChild (PATCH) แก้เฉพาะฟิลด์ที่ส่งมาของโปรไฟล์ลูก
+func (h *UserHandlers) UpdateChild(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	childID, ok := childIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input product.UpdateChildProfile
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	child, err := h.store.UpdateChildProfile(c.Request.Context(), userID, childID, input)
+	if err != nil {
+		respondChildError(c, err)
+		return
+	}
+
+	respondChild(c, http.StatusOK, child)
+}
+
+// DeleteChild ลบโปรไฟล์ลูก
+func (h *UserHandlers) DeleteChild(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	childID, ok := childIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteChildProfile(c.Request.Context(), userID, childID); err != nil {
+		respondChildError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Child profile deleted successfully"})
+}
+
+// ProductsForChild แนะนำสินค้าที่เหมาะกับอายุปัจจุบันของเด็ก (ช่วงอายุของสินค้า หรือของหมวดหมู่
+// เมื่อสินค้าไม่ได้ระบุ) สินค้าที่ตรงความสนใจขึ้นก่อน รองรับการแบ่งหน้าและตัวกรองของ listingQuery
+// ยกเว้น age_months ซึ่งคำนวณจากเดือนเกิดของเด็ก
+func (h *ProductHandlers) ProductsForChild(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	childID, ok := childIDParam(c)
+	if !ok {
+		return
+	}
+
+	q, ok := listingQuery(c)
+	if !ok {
+		return
+	}
+
+	recs, err := h.store.RecommendForChild(c.Request.Context(), userID, childID, q)
+	if err != nil {
+		respondChildError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertChildTimes(&recs.Child, loc)
+	for i := range recs.Items {
+		convertTimesToUserTimezone(&recs.Items[i], loc)
+	}
+
+	c.JSON(http.StatusOK, recs)
+}
@@ -0,0 +1,145 @@
+// inventory_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"productproject/internal/inventory"
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryHandlers struct {
+	inv   *inventory.Service
+	store *product.Store
+}
+
+func NewInventoryHandlers(inv *inventory.Service, store *product.Store) *InventoryHandlers {
+	return &InventoryHandlers{inv: inv, store: store}
+}
+
+// GetStockMovements แสดงประวัติการเปลี่ยนแปลงสต็อกของสินค้า (ล่าสุดก่อน)
+// ระบุ variant_id เพื่อดูเฉพาะตัวเลือกเดียว seller ดูได้เฉพาะสินค้าของร้านตัวเอง
+func (h *InventoryHandlers) GetStockMovements(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil || productID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	variantID, err := strconv.Atoi(c.DefaultQuery("variant_id", "0"))
+	if err != nil || variantID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	movements, err := h.inv.Movements(c.Request.Context(), productID, variantID, limit)
+	if err != nil {
+		log.Printf("Error fetching stock movements: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock movements"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range movements {
+		movements[i].CreatedAt = movements[i].CreatedAt.In(loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "movements": movements})
+}
+
+// RecordStockMovement เติมสินค้า รับคืน หรือปรับยอดสต็อกด้วยมือ พร้อมบันทึกเหตุผล
+// variant_id ระบุตัวเลือกที่จะปรับ หากไม่ส่งมาจะปรับตัวเลือกหลักของสินค้า
+// การขาย (sale) และการปล่อยสินค้าที่จองไว้ (reservation_release) ระบบบันทึกเองเท่านั้น
+// seller ปรับได้เฉพาะสินค้าของร้านตัวเอง
+func (h *InventoryHandlers) RecordStockMovement(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil || productID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	var input struct {
+		VariantID int    `json:"variant_id"`
+		Change    int    `json:"change"`
+		Reason    string `json:"reason"`
+		Note      string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	reason := inventory.Reason(input.Reason)
+	switch {
+	case input.Change == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "change must not be zero"})
+		return
+	case reason == inventory.ReasonRestock || reason == inventory.ReasonReturn:
+		if input.Change < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "restock and return must increase stock"})
+			return
+		}
+	case reason == inventory.ReasonAdjustment:
+		// ปรับยอดได้ทั้งเพิ่มและลด แต่ต้องระบุเหตุผล
+		if input.Note == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "note is required for adjustments"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be one of restock, return, adjustment"})
+		return
+	}
+
+	movement, err := h.inv.Record(c.Request.Context(), inventory.Movement{
+		ProductID: productID,
+		VariantID: input.VariantID,
+		Change:    input.Change,
+		Reason:    reason,
+		Note:      input.Note,
+		CreatedBy: &userID,
+	})
+	var stockErr *inventory.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "items": stockErr.Items})
+		return
+	}
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording stock movement: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, movement)
+}
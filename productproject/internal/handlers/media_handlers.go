@@ -0,0 +1,238 @@
+// media_handlers.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"productproject/internal/media"
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MediaHandlers struct {
+	store *product.Store
+	media *media.Service
+}
+
+func NewMediaHandlers(store *product.Store, media *media.Service) *MediaHandlers {
+	return &MediaHandlers{store: store, media: media}
+}
+
+// respondImageError แปลงข้อผิดพลาดจากการจัดการแกลเลอรีเป็น HTTP response
+func respondImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidImage), errors.Is(err, media.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product image not found"})
+	case errors.Is(err, product.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	default:
+		log.Printf("Error managing product images: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func respondImages(c *gin.Context, productID int, images []product.ProductImage) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range images {
+		convertImageTimes(&images[i], loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "images": images})
+}
+
+func respondImage(c *gin.Context, status int, image product.ProductImage) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertImageTimes(&image, loc)
+
+	c.JSON(status, image)
+}
+
+func imageIDParam(c *gin.Context) (int, bool) {
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil || imageID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return 0, false
+	}
+	return imageID, true
+}
+
+// GetProductImages แสดงแกลเลอรีรูปของสินค้าเรียงตามลำดับ พร้อม thumbnail ทุกขนาด
+func (h *MediaHandlers) GetProductImages(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	images, err := h.store.GetProductImages(c.Request.Context(), productID)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	respondImages(c, productID, images)
+}
+
+// UploadProductImage อัปโหลดรูปสินค้าแบบ multipart/form-data
+// ฟิลด์ image คือไฟล์รูป (JPEG, PNG หรือ GIF) ส่วน alt_text และ position ไม่บังคับ (ไม่ส่ง position คือต่อท้าย)
+// ระบบเก็บต้นฉบับและสร้าง thumbnail ทุกขนาดก่อนเพิ่มเข้าแกลเลอรี seller อัปโหลดได้เฉพาะสินค้าของร้านตัวเอง
+func (h *MediaHandlers) UploadProductImage(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	// เผื่อขนาดของฟิลด์อื่นในฟอร์มไว้ 1 MB
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, media.MaxUploadSize+1<<20)
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required (multipart field \"image\")"})
+		return
+	}
+
+	input := product.NewProductImage{AltText: c.PostForm("alt_text")}
+	if v := c.PostForm("position"); v != "" {
+		position, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid position"})
+			return
+		}
+		input.Position = &position
+	}
+	// ตรวจ alt_text และ position ก่อนเก็บไฟล์ จะได้ไม่ต้องเขียนไฟล์ที่ไม่ได้ใช้
+	if err := (product.UpdateProductImage{AltText: &input.AltText, Position: input.Position}).Validate(); err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		log.Printf("Error opening uploaded image: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return
+	}
+	defer file.Close()
+
+	stored, err := h.media.StoreImage(c.Request.Context(), fmt.Sprintf("products/%d", productID), file)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	input.URL = stored.URL
+	input.Thumbnails = stored.Thumbnails
+	input.StorageKeys = stored.Keys
+	input.ContentType = stored.ContentType
+	input.Width = stored.Width
+	input.Height = stored.Height
+
+	image, err := h.store.AddProductImage(c.Request.Context(), productID, input)
+	if err != nil {
+		// ไฟล์ถูกเขียนไปแล้วแต่ไม่ได้อยู่ในแกลเลอรี จึงต้องลบทิ้ง (ใช้ context ใหม่เผื่อ request หมดเวลาแล้ว)
+		h.media.Delete(context.Background(), stored.Keys)
+		respondImageError(c, err)
+		return
+	}
+
+	respondImage(c, http.StatusCreated, image)
+}
+
+// UpdateProductImage (PATCH) แก้ alt_text หรือย้าย position ของรูป
+func (h *MediaHandlers) UpdateProductImage(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	imageID, ok := imageIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	var input product.UpdateProductImage
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	image, err := h.store.UpdateProductImage(c.Request.Context(), productID, imageID, input)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	respondImage(c, http.StatusOK, image)
+}
+
+// ReorderProductImages จัดลำดับแกลเลอรีใหม่ตาม image_ids (ต้องมีครบทุกรูป รูปแรกคือรูปปก)
+func (h *MediaHandlers) ReorderProductImages(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	var input struct {
+		ImageIDs []int `json:"image_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	images, err := h.store.ReorderProductImages(c.Request.Context(), productID, input.ImageIDs)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	respondImages(c, productID, images)
+}
+
+// DeleteProductImage ลบรูปออกจากแกลเลอรีพร้อมไฟล์ต้นฉบับและ thumbnail ใน storage
+func (h *MediaHandlers) DeleteProductImage(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	imageID, ok := imageIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	image, err := h.store.DeleteProductImage(c.Request.Context(), productID, imageID)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	// รูปถูกลบจากแกลเลอรีแล้ว ไฟล์ที่ลบไม่สำเร็จจะถูก log ไว้โดยไม่ทำให้ request ล้มเหลว
+	h.media.Delete(context.Background(), image.StorageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product image deleted successfully"})
+}
@@ -0,0 +1,86 @@
+// notification_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+func convertNotificationTimes(n *product.Notification, loc *time.Location) {
+	n.CreatedAt = n.CreatedAt.In(loc)
+	if n.ReadAt != nil {
+		readAt := n.ReadAt.In(loc)
+		n.ReadAt = &readAt
+	}
+}
+
+// GetNotifications แสดงการแจ้งเตือนล่าสุดของผู้ใช้ที่ล็อกอินอยู่ ส่ง ?unread=true เพื่อดูเฉพาะที่ยังไม่ได้อ่าน
+func (h *UserHandlers) GetNotifications(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	unreadOnly, err := strconv.ParseBool(c.DefaultQuery("unread", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unread value"})
+		return
+	}
+
+	notifications, err := h.store.GetNotifications(c.Request.Context(), userID, unreadOnly)
+	if err != nil {
+		log.Printf("Error getting notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range notifications {
+		convertNotificationTimes(&notifications[i], loc)
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+// MarkNotificationRead บันทึกว่าผู้ใช้อ่านการแจ้งเตือนแล้ว
+func (h *UserHandlers) MarkNotificationRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(c.Param("notification_id"), 10, 64)
+	if err != nil || notificationID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	n, err := h.store.MarkNotificationRead(c.Request.Context(), userID, notificationID)
+	if errors.Is(err, product.ErrNotificationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error marking notification as read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertNotificationTimes(&n, loc)
+
+	c.JSON(http.StatusOK, n)
+}
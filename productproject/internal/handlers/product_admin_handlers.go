@@ -0,0 +1,242 @@
+// product_admin_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"productproject/internal/inventory"
+	product "productproject/internal/product"
+	"productproject/internal/safety"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sellerScope คืน seller_id ที่ผู้ใช้ปัจจุบันจัดการได้ (0 คือ admin ซึ่งจัดการได้ทุกร้าน)
+// ต้องใช้หลัง middleware.RequireRole หากผู้ใช้เป็น seller ที่ยังไม่ได้ผูกกับร้านจะตอบกลับ 403 และคืนค่า false
+func sellerScope(c *gin.Context, store *product.Store) (int, bool) {
+	if c.GetString("user_role") == "admin" {
+		return 0, true
+	}
+
+	sellerID, err := store.GetSellerIDByUserID(c.Request.Context(), c.GetString("user_id"))
+	if errors.Is(err, product.ErrSellerNotLinked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account is not linked to an active seller"})
+		return 0, false
+	}
+	if err != nil {
+		log.Printf("Error fetching seller for user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check seller"})
+		return 0, false
+	}
+
+	return sellerID, true
+}
+
+// canManageProduct ตรวจสอบว่าผู้ใช้ปัจจุบันแก้สินค้านี้ได้ (admin หรือ seller เจ้าของสินค้า)
+// คืน seller_id เหมือน sellerScope หากไม่ได้จะตอบกลับให้เองและคืนค่า false
+func canManageProduct(c *gin.Context, store *product.Store, productID int) (int, bool) {
+	scope, ok := sellerScope(c, store)
+	if !ok {
+		return 0, false
+	}
+	if scope == 0 {
+		return 0, true
+	}
+
+	ownerID, err := store.GetProductSellerID(c.Request.Context(), productID)
+	if errors.Is(err, product.ErrProductNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return 0, false
+	}
+	if err != nil {
+		log.Printf("Error fetching product seller: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check product"})
+		return 0, false
+	}
+
+	if ownerID != scope {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage your own products"})
+		return 0, false
+	}
+	return scope, true
+}
+
+// respondProductWriteError แปลงข้อผิดพลาดจากการสร้างหรือแก้สินค้าเป็น HTTP response
+func respondProductWriteError(c *gin.Context, err error) {
+	var stockErr *inventory.InsufficientStockError
+	switch {
+	case errors.Is(err, product.ErrInvalidProduct), errors.Is(err, product.ErrInvalidImage),
+		errors.Is(err, safety.ErrInvalidLabel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.As(err, &stockErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "items": stockErr.Items})
+	default:
+		log.Printf("Error writing product: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetSafetyHazards แสดงรหัสความเสี่ยงที่ใช้ในฉลากความปลอดภัยได้ พร้อมอายุขั้นต่ำและคำเตือน
+func (h *ProductHandlers) GetSafetyHazards(c *gin.Context) {
+	c.JSON(http.StatusOK, safety.Hazards())
+}
+
+func respondProduct(c *gin.Context, status int, item product.ProductItem) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertTimesToUserTimezone(&item, loc)
+
+	c.JSON(status, item)
+}
+
+func productIDParam(c *gin.Context) (int, bool) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || productID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return 0, false
+	}
+	return productID, true
+}
+
+// CreateProduct สร้างสินค้าใหม่ seller สร้างได้เฉพาะในร้านของตัวเอง (ไม่ต้องส่ง seller_id)
+func (h *ProductHandlers) CreateProduct(c *gin.Context) {
+	scope, ok := sellerScope(c, h.store)
+	if !ok {
+		return
+	}
+
+	var input product.NewProduct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if scope != 0 {
+		if input.SellerID != 0 && input.SellerID != scope {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage your own products"})
+			return
+		}
+		input.SellerID = scope
+	}
+
+	item, err := h.store.CreateProduct(c.Request.Context(), input)
+	if err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	respondProduct(c, http.StatusCreated, item)
+}
+
+// ReplaceProduct (PUT) แก้สินค้าทั้งรายการ ต้องส่งข้อมูลครบเหมือนตอนสร้าง
+// stock มีผลเฉพาะสินค้าที่มีตัวเลือกเดียว สินค้าหลายตัวเลือกต้องส่งสต็อกรวมเดิม (แก้สต็อกที่ /products/:id/variants/:variant_id)
+func (h *ProductHandlers) ReplaceProduct(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	scope, ok := canManageProduct(c, h.store, productID)
+	if !ok {
+		return
+	}
+
+	var input product.NewProduct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if scope != 0 {
+		if input.SellerID != 0 && input.SellerID != scope {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage your own products"})
+			return
+		}
+		input.SellerID = scope
+	}
+	if err := input.Validate(); err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	item, err := h.store.UpdateProduct(c.Request.Context(), productID, product.UpdateProduct{
+		Name:                  &input.Name,
+		Description:           &input.Description,
+		Brand:                 &input.Brand,
+		Price:                 &input.Price,
+		Discount:              &input.Discount,
+		Stock:                 &input.Stock,
+		ProductRecommend:      &input.ProductRecommend,
+		Image:                 &input.Image,
+		SellerID:              &input.SellerID,
+		CategoryID:            &input.CategoryID,
+		Attributes:            &input.Attributes,
+		ClearAttributes:       input.Attributes.Unset(),
+		Safety:                &input.Safety,
+		UnchangedVariantStock: true,
+	}, c.GetString("user_id"))
+	if err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	respondProduct(c, http.StatusOK, item)
+}
+
+// PatchProduct (PATCH) แก้เฉพาะฟิลด์ที่ส่งมา
+func (h *ProductHandlers) PatchProduct(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	scope, ok := canManageProduct(c, h.store, productID)
+	if !ok {
+		return
+	}
+
+	var input product.UpdateProduct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	// seller ย้ายสินค้าไปร้านอื่นไม่ได้
+	if scope != 0 && input.SellerID != nil && *input.SellerID != scope {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage your own products"})
+		return
+	}
+
+	item, err := h.store.UpdateProduct(c.Request.Context(), productID, input, c.GetString("user_id"))
+	if err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	respondProduct(c, http.StatusOK, item)
+}
+
+// DeleteProduct ลบสินค้า (soft delete) และนำออกจากตะกร้าที่ยังไม่สั่งซื้อ
+func (h *ProductHandlers) DeleteProduct(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	err := h.store.DeleteProduct(c.Request.Context(), productID)
+	if err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+}
@@ -2,11 +2,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"productproject/internal/fulfillment"
+	"productproject/internal/inventory"
 	product "productproject/internal/product"
 	user "productproject/internal/product"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,10 +33,130 @@ func NewUserHandlers(store *user.Store) *UserHandlers {
 	return &UserHandlers{store: store}
 }
 
+// ดึง user_id ของผู้ใช้ที่ล็อกอินอยู่ ซึ่ง middleware.AuthMiddleware เก็บไว้ใน gin context
+// หากไม่มีหรือไม่ถูกต้องจะตอบกลับ 401 ให้ทันทีและคืนค่า false
+func currentUserID(c *gin.Context) (string, bool) {
+	userID := c.GetString("user_id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return "", false
+	}
+	return userID, true
+}
+
 func convertTimesToUserTimezone(product *product.ProductItem, loc *time.Location) {
 	product.CreatedAt = product.CreatedAt.In(loc)
 	product.UpdatedAt = product.UpdatedAt.In(loc)
 	product.Inventory.UpdatedAt = product.Inventory.UpdatedAt.In(loc)
+	for i := range product.Variants {
+		convertVariantTimes(&product.Variants[i], loc)
+	}
+	for i := range product.Gallery {
+		convertImageTimes(&product.Gallery[i], loc)
+	}
+}
+
+func convertVariantTimes(variant *product.Variant, loc *time.Location) {
+	variant.CreatedAt = variant.CreatedAt.In(loc)
+	variant.UpdatedAt = variant.UpdatedAt.In(loc)
+	variant.Inventory.UpdatedAt = variant.Inventory.UpdatedAt.In(loc)
+}
+
+func convertImageTimes(image *product.ProductImage, loc *time.Location) {
+	image.CreatedAt = image.CreatedAt.In(loc)
+	image.UpdatedAt = image.UpdatedAt.In(loc)
+}
+
+// listingQuery อ่านพารามิเตอร์การแสดงรายการสินค้าจาก query string
+// limit, cursor, sort, min_price, max_price, brand, seller_id, in_stock, recommended,
+// age_months, battery, material
+// หากรูปแบบไม่ถูกต้องจะตอบกลับ 400 ให้เองและคืนค่า false
+func listingQuery(c *gin.Context) (product.ListingQuery, bool) {
+	q := product.ListingQuery{
+		Cursor:   c.Query("cursor"),
+		Sort:     c.Query("sort"),
+		Brand:    c.Query("brand"),
+		Battery:  c.Query("battery"),
+		Material: c.Query("material"),
+	}
+
+	var err error
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return q, false
+		}
+	}
+	if v := c.Query("age_months"); v != "" {
+		age, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid age_months"})
+			return q, false
+		}
+		q.AgeMonths = &age
+	}
+	if v := c.Query("seller_id"); v != "" {
+		if q.SellerID, err = strconv.Atoi(v); err != nil || q.SellerID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller_id"})
+			return q, false
+		}
+	}
+	for _, price := range []struct {
+		name string
+		dst  **float64
+	}{{"min_price", &q.MinPrice}, {"max_price", &q.MaxPrice}} {
+		v := c.Query(price.name)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + price.name})
+			return q, false
+		}
+		*price.dst = &f
+	}
+	for _, flag := range []struct {
+		name string
+		dst  *bool
+	}{{"in_stock", &q.InStockOnly}, {"recommended", &q.Recommended}} {
+		v := c.Query(flag.name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + flag.name})
+			return q, false
+		}
+		*flag.dst = b
+	}
+
+	return q, true
+}
+
+// respondListingError ตอบกลับ 400 เมื่อพารามิเตอร์ไม่ถูกต้อง มิฉะนั้นตอบ 500 พร้อมข้อความ message
+func respondListingError(c *gin.Context, err error, message string) {
+	if errors.Is(err, product.ErrInvalidListing) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Error listing products: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// respondProductPage แปลงเวลาเป็น timezone ของผู้ใช้แล้วส่งสินค้าหนึ่งหน้ากลับเป็น JSON
+func respondProductPage(c *gin.Context, page product.ProductPage) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+
+	for i := range page.Items {
+		convertTimesToUserTimezone(&page.Items[i], loc)
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *ProductHandlers) GetProduct(c *gin.Context) {
@@ -55,24 +179,20 @@ func (h *ProductHandlers) GetProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, product)
 }
 
+// AllProducts แสดงสินค้าทั้งหมด รองรับการแบ่งหน้า การเรียง และตัวกรองของ listingQuery
 func (h *ProductHandlers) AllProducts(c *gin.Context) {
-	allProducts, err := h.store.AllProducts(c.Request.Context())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดึงข้อมูลสินค้าล่าสุดได้"})
+	q, ok := listingQuery(c)
+	if !ok {
 		return
 	}
 
-	userTimezone := "Asia/Bangkok"
-	loc, err := time.LoadLocation(userTimezone)
+	page, err := h.store.AllProducts(c.Request.Context(), q)
 	if err != nil {
-		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
-	}
-
-	for i := range allProducts {
-		convertTimesToUserTimezone(&allProducts[i], loc)
+		respondListingError(c, err, "ไม่สามารถดึงข้อมูลสินค้าได้")
+		return
 	}
 
-	c.JSON(http.StatusOK, allProducts)
+	respondProductPage(c, page)
 }
 
 func (h ProductHandlers) GetSeller(c *gin.Context) {
@@ -92,27 +212,19 @@ func (h ProductHandlers) GetSeller(c *gin.Context) {
 }
 
 func (h *ProductHandlers) GetProductRecommend(c *gin.Context) {
-	// ดึงรายการสินค้าที่แนะนำจาก store
-	recommendedProducts, err := h.store.GetProductRecommend(c.Request.Context())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดึงข้อมูลสินค้าแนะนำได้"})
+	q, ok := listingQuery(c)
+	if !ok {
 		return
 	}
 
-	// ตั้งค่า timezone
-	userTimezone := "Asia/Bangkok"
-	loc, err := time.LoadLocation(userTimezone)
+	// ดึงรายการสินค้าที่แนะนำจาก store
+	page, err := h.store.GetProductRecommend(c.Request.Context(), q)
 	if err != nil {
-		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
-	}
-
-	// เปลี่ยนเวลาเป็น timezone ของผู้ใช้
-	for i := range recommendedProducts {
-		convertTimesToUserTimezone(&recommendedProducts[i], loc)
+		respondListingError(c, err, "ไม่สามารถดึงข้อมูลสินค้าแนะนำได้")
+		return
 	}
 
-	// ส่งข้อมูลสินค้าแนะนำเป็น JSON
-	c.JSON(http.StatusOK, recommendedProducts)
+	respondProductPage(c, page)
 }
 
 func (h *ProductHandlers) GetNewProduct(c *gin.Context) {
@@ -135,6 +247,9 @@ func (h *ProductHandlers) GetNewProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, newProducts)
 }
 
+// SearchProduct ค้นหาสินค้าจากชื่อ คำอธิบาย แบรนด์ หมวดหมู่ และชื่อร้าน (รองรับภาษาไทยและการพิมพ์ผิดเล็กน้อย)
+// เรียงตามความเกี่ยวข้องเป็นค่าเริ่มต้น และใช้ตัวกรองกับการแบ่งหน้าเดียวกับรายการสินค้าอื่น
+// ผลลัพธ์มี facets (จำนวนสินค้าแยกตามหมวดหมู่ แบรนด์ ร้าน ช่วงราคา และสถานะสต็อก) สำหรับแสดงตัวกรอง
 func (h *ProductHandlers) SearchProduct(c *gin.Context) {
 	// รับค่า query จาก URL
 	query := c.DefaultQuery("query", "") // ใช้ DefaultQuery หากไม่มี query จะส่งค่าเริ่มต้นเป็น ""
@@ -145,81 +260,93 @@ func (h *ProductHandlers) SearchProduct(c *gin.Context) {
 		return
 	}
 
+	q, ok := listingQuery(c)
+	if !ok {
+		return
+	}
+
 	// Log ค่าที่ได้รับจาก query เพื่อใช้ในการดีบัก
 	log.Printf("Searching products with query: %s", query)
 
 	// ค้นหาผลิตภัณฑ์จาก store
-	products, err := h.store.SearchProducts(c.Request.Context(), query)
+	page, err := h.store.SearchProducts(c.Request.Context(), query, q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถค้นหาผลิตภัณฑ์ได้"})
+		respondListingError(c, err, "ไม่สามารถค้นหาผลิตภัณฑ์ได้")
 		return
 	}
 
 	// ตรวจสอบว่ามีสินค้าตรงกับคำค้นหาหรือไม่
-	if len(products) == 0 {
+	if page.Total == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"message": "ไม่พบสินค้าตามคำค้นหาของคุณ"})
 		return
 	}
 
-	// ตั้งค่า timezone ของผู้ใช้
-	userTimezone := "Asia/Bangkok"
-	loc, err := time.LoadLocation(userTimezone)
-	if err != nil {
-		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	respondProductPage(c, page)
+}
+
+// SuggestProducts แนะนำชื่อสินค้า แบรนด์ และหมวดหมู่ที่ขึ้นต้นด้วยข้อความในช่องค้นหา (q)
+// เรียงตามความนิยม จำนวนสูงสุดกำหนดด้วย limit (ค่าเริ่มต้น 10)
+func (h *ProductHandlers) SuggestProducts(c *gin.Context) {
+	limit := 10
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
 	}
 
-	// แปลงวันที่ให้เป็น timezone ของผู้ใช้
-	for i := range products {
-		convertTimesToUserTimezone(&products[i], loc)
+	suggestions, err := h.store.SuggestProducts(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		log.Printf("Error suggesting products: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดึงคำแนะนำได้"})
+		return
 	}
 
-	// ส่งข้อมูลสินค้าเป็น JSON
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
 }
 
 func (h *ProductHandlers) GetProductByCategory(c *gin.Context) {
 	// รับค่า category จาก URL parameter
-	category := c.Param("category")
-
-	// ตรวจสอบว่าหมวดหมู่ที่ส่งมาไม่ว่างเปล่า
-	if category == "" {
+	categoryID, err := strconv.Atoi(c.Param("category"))
+	if err != nil || categoryID <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "กรุณาระบุหมวดหมู่สินค้า"})
 		return
 	}
 
-	// ดึงรายการสินค้าตามหมวดหมู่จาก store
-	products, err := h.store.GetProductByCategory(c.Request.Context(), category)
+	q, ok := listingQuery(c)
+	if !ok {
+		return
+	}
+
+	// ดึงรายการสินค้าตามหมวดหมู่ (รวมหมวดหมู่ย่อย) จาก store
+	page, err := h.store.GetProductByCategory(c.Request.Context(), categoryID, q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดึงข้อมูลสินค้าตามหมวดหมู่ได้"})
+		respondListingError(c, err, "ไม่สามารถดึงข้อมูลสินค้าตามหมวดหมู่ได้")
 		return
 	}
 
 	// ตรวจสอบว่ามีสินค้าตามหมวดหมู่ที่ค้นหาหรือไม่
-	if len(products) == 0 {
+	if page.Total == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"message": "ไม่พบสินค้าตามหมวดหมู่ที่ระบุ"})
 		return
 	}
 
-	// ตั้งค่า timezone ของผู้ใช้
-	userTimezone := "Asia/Bangkok"
-	loc, err := time.LoadLocation(userTimezone)
-	if err != nil {
-		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
-	}
-
-	// แปลงวันที่ให้เป็น timezone ของผู้ใช้
-	for i := range products {
-		convertTimesToUserTimezone(&products[i], loc)
-	}
-
-	// ส่งข้อมูลสินค้าเป็น JSON
-	c.JSON(http.StatusOK, products)
+	respondProductPage(c, page)
 }
 
+// AddToCart เพิ่มสินค้าลงตะกร้า variant_id ระบุตัวเลือก (สี ขนาด รุ่น) หากไม่ส่งมาจะใช้ตัวเลือกหลัก
 func (h *ProductHandlers) AddToCart(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	var input struct {
-		ProductID int `json:"product_id"`
-		Quantity  int `json:"quantity"`
+		ProductID         int  `json:"product_id"`
+		VariantID         int  `json:"variant_id"`
+		Quantity          int  `json:"quantity"`
+		AcknowledgeSafety bool `json:"acknowledge_safety"` // ยืนยันว่ารับทราบคำเตือนของสินค้าที่ไม่เหมาะกับอายุของลูก
 	}
 
 	// ตรวจสอบข้อมูล JSON ที่รับเข้ามา
@@ -234,20 +361,54 @@ func (h *ProductHandlers) AddToCart(c *gin.Context) {
 		return
 	}
 
-	// เรียกใช้ AddToCart พร้อม CartID
-	err := h.store.AddToCart(c.Request.Context(), input.ProductID, input.Quantity)
+	// เรียกใช้ AddToCart สำหรับตะกร้าของผู้ใช้
+	warnings, err := h.store.AddToCart(c.Request.Context(), userID, input.ProductID, input.VariantID, input.Quantity, input.AcknowledgeSafety)
+	var stockErr *inventory.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "items": stockErr.Items})
+		return
+	}
+	// ผู้ใช้มีลูกที่อายุยังไม่ถึงอายุขั้นต่ำของสินค้า ต้องส่ง acknowledge_safety: true มาใหม่เพื่อยืนยัน
+	var safetyErr *product.SafetyAcknowledgementError
+	if errors.As(err, &safetyErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "safety acknowledgement required",
+			"product_id":     safetyErr.ProductID,
+			"min_age_months": safetyErr.MinAgeMonths,
+			"warnings":       safetyErr.Warnings,
+			"children":       safetyErr.Children,
+		})
+		return
+	}
+	if errors.Is(err, product.ErrVariantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+		return
+	}
+	if errors.Is(err, product.ErrProductRecalled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Printf("Error adding product to cart: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if len(warnings) > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Product added to cart successfully", "safety_warnings": warnings})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Product added to cart successfully"})
 }
 
 func (h *ProductHandlers) GetAllCartItems(c *gin.Context) {
-	// เรียกใช้ method GetAllCartItems จาก store เพื่อดึงข้อมูลสินค้าจากทุกตะกร้า
-	cartItems, err := h.store.GetAllCartItems(c.Request.Context())
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// เรียกใช้ method GetAllCartItems จาก store เพื่อดึงข้อมูลสินค้าในตะกร้าของผู้ใช้
+	cartItems, err := h.store.GetAllCartItems(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -262,12 +423,15 @@ func (h *ProductHandlers) HealthCheck(c *gin.Context) {
 }
 
 func (h *UserHandlers) GetUserProfile(c *gin.Context) {
-	// ดึง userID จาก URL parameters
-	userID := c.Param("user_id")
+	// ผู้ใช้ที่ล็อกอินอยู่
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
-	// ตรวจสอบว่า userID มีค่าหรือไม่
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+	// เส้นทาง "/users/:user_id" ดูได้เฉพาะข้อมูลของตัวเองเท่านั้น
+	if paramID := c.Param("user_id"); paramID != "" && paramID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own profile"})
 		return
 	}
 
@@ -295,6 +459,11 @@ func (h *UserHandlers) GetUserProfile(c *gin.Context) {
 }
 
 func (h *ProductHandlers) UpdateCartItemQuantity(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	var input struct {
 		CartItemID string  `json:"cart_item_id"`
 		Quantity   int     `json:"quantity"`
@@ -311,7 +480,7 @@ func (h *ProductHandlers) UpdateCartItemQuantity(c *gin.Context) {
 	// เรียกใช้ฟังก์ชันจาก database layer เพื่ออัปเดตหรือลบรายการสินค้าในตะกร้า
 	if input.Quantity == 0 {
 		// ถ้า Quantity เป็น 0 ให้ลบรายการสินค้าออกจากตะกร้า
-		err := h.store.DeleteCartItem(c.Request.Context(), input.CartItemID)
+		err := h.store.DeleteCartItem(c.Request.Context(), userID, input.CartItemID)
 		if err != nil {
 			log.Printf("Error deleting cart item: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -322,7 +491,12 @@ func (h *ProductHandlers) UpdateCartItemQuantity(c *gin.Context) {
 	}
 
 	// อัปเดตจำนวนสินค้าในตะกร้า
-	err := h.store.UpdateCartItemQuantity(c.Request.Context(), input.CartItemID, input.Quantity)
+	err := h.store.UpdateCartItemQuantity(c.Request.Context(), userID, input.CartItemID, input.Quantity)
+	var stockErr *inventory.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "items": stockErr.Items})
+		return
+	}
 	if err != nil {
 		log.Printf("Error updating cart item quantity: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -334,6 +508,11 @@ func (h *ProductHandlers) UpdateCartItemQuantity(c *gin.Context) {
 
 // DeleteCartItem handler function
 func (h *ProductHandlers) DeleteCartItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	// สร้าง struct เพื่อรับข้อมูลจาก body
 	var requestBody struct {
 		CartItemID string `json:"cart_item_id"`
@@ -352,7 +531,7 @@ func (h *ProductHandlers) DeleteCartItem(c *gin.Context) {
 	}
 
 	// เรียกฟังก์ชันใน database layer เพื่อลบสินค้าจากตะกร้า
-	err := h.store.DeleteCartItem(c.Request.Context(), requestBody.CartItemID)
+	err := h.store.DeleteCartItem(c.Request.Context(), userID, requestBody.CartItemID)
 	if err != nil {
 		log.Printf("Error deleting cart item: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cart item"})
@@ -379,6 +558,12 @@ func (h *ProductHandlers) GetOrders(c *gin.Context) {
 }
 
 func (h *ProductHandlers) CreateOrder(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// total_amount คือยอดที่ผู้ใช้เห็นและยืนยัน ต้องส่งมาเสมอและต้องตรงกับยอดที่ระบบคำนวณเอง
 	var req struct {
 		CartItemIDs []int   `json:"cart_item_id"`
 		TotalAmount float64 `json:"total_amount"`
@@ -407,8 +592,32 @@ func (h *ProductHandlers) CreateOrder(c *gin.Context) {
 		cartItems = append(cartItems, product.CartItem{CartItemID: id, ProductID: id})
 	}
 
-	// เรียกใช้ฟังก์ชัน CreateOrder
-	orderID, err := h.store.CreateOrder(c.Request.Context(), cartItems, req.TotalAmount)
+	// เรียกใช้ฟังก์ชัน CreateOrder ซึ่งคำนวณยอดรวมจากราคาปัจจุบันเอง
+	orderID, totalAmount, err := h.store.CreateOrder(c.Request.Context(), userID, cartItems, req.TotalAmount)
+	if errors.Is(err, product.ErrTotalMismatch) {
+		// ราคาหรือส่วนลดเปลี่ยนไปตั้งแต่ client คำนวณ ให้ client แสดงยอดใหม่แล้วยืนยันอีกครั้ง
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "total_amount": totalAmount})
+		return
+	}
+	var stockErr *inventory.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		// สินค้าบางรายการหมดหรือเหลือไม่พอ แจ้งทีละรายการให้ผู้ใช้ปรับตะกร้า
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "items": stockErr.Items})
+		return
+	}
+	if errors.Is(err, product.ErrProductRecalled) {
+		// สินค้าถูกเรียกคืนหลังจากเพิ่มลงตะกร้า ให้ผู้ใช้ลบรายการนั้นออกก่อน
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, product.ErrCartItemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, product.ErrInvalidOrder) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create order: %v", err)})
 		return
@@ -416,8 +625,9 @@ func (h *ProductHandlers) CreateOrder(c *gin.Context) {
 
 	// ส่งคำตอบกลับไปยังผู้ใช้
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Order created successfully",
-		"order_id": orderID,
+		"message":      "Order created successfully",
+		"order_id":     orderID,
+		"total_amount": totalAmount,
 	})
 }
 
@@ -444,6 +654,72 @@ func (h *ProductHandlers) GetOrdersSort(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
+// GetMyOrders แสดงคำสั่งซื้อของผู้ใช้ที่ล็อกอินอยู่ เรียงจากล่าสุด
+func (h *ProductHandlers) GetMyOrders(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	orders, err := h.store.GetUserOrders(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range orders {
+		orders[i].OrderDate = orders[i].OrderDate.In(loc)
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+// GetMyOrder แสดงคำสั่งซื้อหนึ่งรายการของผู้ใช้ที่ล็อกอินอยู่ พร้อมการจัดส่งของแต่ละร้าน
+// คำสั่งซื้อของผู้อื่นตอบเป็น 404 เหมือนไม่มีคำสั่งซื้อนั้น
+func (h *ProductHandlers) GetMyOrder(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || orderID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.store.GetUserOrder(c.Request.Context(), userID, orderID)
+	if errors.Is(err, product.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching user order: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	order.OrderDate = order.OrderDate.In(loc)
+	for i := range order.Shipments {
+		convertShipmentTimes(&order.Shipments[i], loc)
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// UpdateCartItemStatusHandler เลื่อนสถานะการจัดส่งของร้านในคำสั่งซื้อไปขั้นถัดไปตามลำดับปกติ
+// (processing → packed → shipping → delivered) ในนามของร้าน การยืนยันว่าได้รับสินค้าเป็นของลูกค้า
+// ส่วนการยกเลิกใช้ POST /order/:id/cancel และการคืนสินค้าใช้ POST /returns
+// ผู้ขายเลื่อนได้เฉพาะร้านของตัวเอง (ไม่ใช้ seller_id ใน body) มีเพียง admin ที่ระบุ seller_id ได้
 func (h *ProductHandlers) UpdateCartItemStatusHandler(c *gin.Context) {
 	var input struct {
 		OrderID  int `json:"order_id"`
@@ -457,47 +733,54 @@ func (h *ProductHandlers) UpdateCartItemStatusHandler(c *gin.Context) {
 		return
 	}
 
-	// ดึงสถานะปัจจุบันของ cart item
-	currentStatus, err := h.store.GetCurrentCartItemStatus(c.Request.Context(), input.OrderID, input.SellerID)
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+	actor := fulfillment.ActorSeller
+	switch {
+	case viewer.role == "admin":
+		actor = fulfillment.ActorAdmin
+	case viewer.sellerID != 0:
+		input.SellerID = viewer.sellerID
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only sellers can update their shipments"})
+		return
+	}
+
+	// ดึงการจัดส่งของร้านในคำสั่งซื้อ
+	shipment, err := h.store.GetSellerShipment(c.Request.Context(), input.OrderID, input.SellerID)
 	if err != nil {
-		log.Printf("Error retrieving current cart item status: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondShipmentError(c, err)
 		return
 	}
 
 	// ตรวจสอบสถานะถัดไป
-	nextStatus := getNextStatus(currentStatus)
+	nextStatus := fulfillment.Next(shipment.Status)
 	if nextStatus == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid current status or no further status available"})
 		return
 	}
 
-	// เรียกใช้ฟังก์ชันจาก database layer เพื่ออัปเดตสถานะ
-	err = h.store.UpdateCartItemStatus(c.Request.Context(), input.OrderID, input.SellerID, nextStatus)
+	shipment, err = h.store.TransitionShipment(c.Request.Context(), shipment.ID,
+		product.ShipmentTransition{Status: nextStatus}, actor, viewer.userID)
 	if err != nil {
-		log.Printf("Error updating cart item status: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondShipmentError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Cart item status updated successfully", "new_status": nextStatus})
+	c.JSON(http.StatusOK, gin.H{"message": "Cart item status updated successfully", "new_status": shipment.Status, "shipment_id": shipment.ID})
 }
 
-// ฟังก์ชันเพื่อระบุสถานะถัดไป
-func getNextStatus(currentStatus string) string {
-	statusSequence := []string{"processing", "shipping", "delivered", "received"}
-	for i, status := range statusSequence {
-		if status == currentStatus && i+1 < len(statusSequence) {
-			return statusSequence[i+1]
-		}
+func (h *ProductHandlers) UpdateUserContactHandler(c *gin.Context) {
+	// ใช้ user_id จาก token เสมอ ไม่เชื่อค่าที่ส่งมาใน body
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
 	}
-	return ""
-}
 
-func (h *ProductHandlers) UpdateUserContactHandler(c *gin.Context) {
 	// อ่านข้อมูลจาก Body
 	var req struct {
-		UserID      string `json:"user_id" binding:"required"`
 		DisplayName string `json:"display_name"`
 		Address     string `json:"address"`
 		Phone       string `json:"phone"`
@@ -507,14 +790,8 @@ func (h *ProductHandlers) UpdateUserContactHandler(c *gin.Context) {
 		return
 	}
 
-	// ตรวจสอบว่า user_id เป็น UUID ที่ถูกต้อง
-	if _, err := uuid.Parse(req.UserID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
 	// อัปเดตข้อมูลในฐานข้อมูล
-	if err := h.store.UpdateUserContact(c.Request.Context(), req.UserID, req.DisplayName, req.Address, req.Phone); err != nil {
+	if err := h.store.UpdateUserContact(c.Request.Context(), userID, req.DisplayName, req.Address, req.Phone); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update contact: %v", err)})
 		return
 	}
@@ -0,0 +1,210 @@
+// recall_handlers.go
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondRecallError แปลงข้อผิดพลาดจากการจัดการการเรียกคืนเป็น HTTP response
+func respondRecallError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidRecall):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrRecallNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recall not found"})
+	case errors.Is(err, product.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	default:
+		log.Printf("Error managing recall: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func convertRecallTimes(recall *product.Recall, loc *time.Location) {
+	recall.CreatedAt = recall.CreatedAt.In(loc)
+	recall.UpdatedAt = recall.UpdatedAt.In(loc)
+	for _, t := range []**time.Time{&recall.SoldFrom, &recall.SoldUntil, &recall.ClosedAt} {
+		if *t != nil {
+			local := (*t).In(loc)
+			*t = &local
+		}
+	}
+}
+
+func respondRecall(c *gin.Context, status int, recall product.Recall) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertRecallTimes(&recall, loc)
+
+	c.JSON(status, recall)
+}
+
+func recallIDParam(c *gin.Context) (int, bool) {
+	recallID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || recallID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recall ID"})
+		return 0, false
+	}
+	return recallID, true
+}
+
+// CreateRecall ประกาศเรียกคืนสินค้า สินค้าถูกซ่อนและหยุดขายทันที และลูกค้าที่เคยซื้อได้รับการแจ้งเตือน
+func (h *ProductHandlers) CreateRecall(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input product.NewRecall
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	recall, err := h.store.CreateRecall(c.Request.Context(), input, userID)
+	if err != nil {
+		respondRecallError(c, err)
+		return
+	}
+
+	respondRecall(c, http.StatusCreated, recall)
+}
+
+// GetRecalls แสดงการเรียกคืนทั้งหมด กรองตามสถานะได้ด้วย ?status=active|closed
+func (h *ProductHandlers) GetRecalls(c *gin.Context) {
+	recalls, err := h.store.GetRecalls(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		respondRecallError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range recalls {
+		convertRecallTimes(&recalls[i], loc)
+	}
+
+	c.JSON(http.StatusOK, recalls)
+}
+
+// GetRecall แสดงการเรียกคืนหนึ่งรายการ
+func (h *ProductHandlers) GetRecall(c *gin.Context) {
+	recallID, ok := recallIDParam(c)
+	if !ok {
+		return
+	}
+
+	recall, err := h.store.GetRecall(c.Request.Context(), recallID)
+	if err != nil {
+		respondRecallError(c, err)
+		return
+	}
+
+	respondRecall(c, http.StatusOK, recall)
+}
+
+// CloseRecall ปิดการเรียกคืน สินค้ากลับมาแสดงและขายได้
+func (h *ProductHandlers) CloseRecall(c *gin.Context) {
+	recallID, ok := recallIDParam(c)
+	if !ok {
+		return
+	}
+
+	recall, err := h.store.CloseRecall(c.Request.Context(), recallID)
+	if err != nil {
+		respondRecallError(c, err)
+		return
+	}
+
+	respondRecall(c, http.StatusOK, recall)
+}
+
+// GetRecallReport รายงานลูกค้าและรายการสั่งซื้อที่ได้รับผลกระทบจากการเรียกคืน
+// ส่ง ?format=csv เพื่อดาวน์โหลดเป็นไฟล์ CSV (มี BOM เพื่อให้ Excel อ่านภาษาไทยได้)
+func (h *ProductHandlers) GetRecallReport(c *gin.Context) {
+	recallID, ok := recallIDParam(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	report, err := h.store.GetRecallReport(c.Request.Context(), recallID)
+	if err != nil {
+		respondRecallError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertRecallTimes(&report.Recall, loc)
+	for i := range report.Items {
+		item := &report.Items[i]
+		item.OrderDate = item.OrderDate.In(loc)
+		for _, t := range []**time.Time{&item.NotifiedAt, &item.ReadAt} {
+			if *t != nil {
+				local := (*t).In(loc)
+				*t = &local
+			}
+		}
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="recall-%d.csv"`, recallID))
+	c.Status(http.StatusOK)
+	c.Writer.WriteString("\ufeff")
+
+	optional := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"order_id", "order_date", "user_id", "full_name", "email", "phone", "address",
+		"variant_id", "sku", "quantity", "notified_at", "read_at"})
+	for _, item := range report.Items {
+		w.Write([]string{
+			strconv.Itoa(item.OrderID), item.OrderDate.Format(time.RFC3339), item.UserID, item.FullName, item.Email,
+			optional(item.Phone), optional(item.Address),
+			strconv.Itoa(item.VariantID), item.SKU, strconv.Itoa(item.Quantity),
+			formatTime(item.NotifiedAt), formatTime(item.ReadAt),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("Error writing recall report %d: %v", recallID, err)
+	}
+}
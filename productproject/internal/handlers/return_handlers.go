@@ -0,0 +1,306 @@
+// return_handlers.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"productproject/internal/fulfillment"
+	"productproject/internal/media"
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReturnHandlers struct {
+	store *product.Store
+	media *media.Service
+}
+
+func NewReturnHandlers(store *product.Store, media *media.Service) *ReturnHandlers {
+	return &ReturnHandlers{store: store, media: media}
+}
+
+// respondReturnError แปลงข้อผิดพลาดจากการจัดการคำขอคืนสินค้าเป็น HTTP response
+func respondReturnError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidReturn), errors.Is(err, media.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrReturnDecided), errors.Is(err, fulfillment.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrReturnNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return request not found"})
+	case errors.Is(err, product.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	default:
+		log.Printf("Error managing return request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func convertReturnTimes(r *product.ReturnRequest, loc *time.Location) {
+	r.CreatedAt = r.CreatedAt.In(loc)
+	r.UpdatedAt = r.UpdatedAt.In(loc)
+	if r.DecidedAt != nil {
+		decidedAt := r.DecidedAt.In(loc)
+		r.DecidedAt = &decidedAt
+	}
+	for i := range r.Photos {
+		r.Photos[i].CreatedAt = r.Photos[i].CreatedAt.In(loc)
+	}
+	if r.Refund != nil {
+		r.Refund.CreatedAt = r.Refund.CreatedAt.In(loc)
+	}
+}
+
+func respondReturn(c *gin.Context, status int, r product.ReturnRequest) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertReturnTimes(&r, loc)
+
+	c.JSON(status, r)
+}
+
+func returnIDParam(c *gin.Context) (int, bool) {
+	returnID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || returnID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return 0, false
+	}
+	return returnID, true
+}
+
+// returnActor คืนบทบาทของผู้ใช้ต่อคำขอคืนสินค้า (admin, seller ของร้านนั้น หรือผู้ซื้อที่ขอคืน)
+// คืนค่าว่างเมื่อไม่เกี่ยวข้องกับคำขอนี้
+func (v shipmentViewer) returnActor(r product.ReturnRequest) string {
+	switch {
+	case v.role == "admin":
+		return fulfillment.ActorAdmin
+	case v.sellerID != 0 && v.sellerID == r.SellerID:
+		return fulfillment.ActorSeller
+	case v.userID == r.UserID:
+		return fulfillment.ActorCustomer
+	}
+	return ""
+}
+
+// CreateReturn เปิดคำขอคืนสินค้าของผู้ซื้อ
+// ส่งเป็น JSON หรือ multipart/form-data เมื่อแนบรูป: ฟิลด์ order_id, reason, items (JSON เช่น
+// [{"cart_item_id": 1, "quantity": 1}]) และ photos (ไฟล์รูป ไม่เกิน product.MaxReturnPhotos รูป)
+func (h *ReturnHandlers) CreateReturn(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input product.NewReturn
+	var photos []*multipart.FileHeader
+	if c.ContentType() == "multipart/form-data" {
+		// เผื่อขนาดของฟิลด์อื่นในฟอร์มไว้ 1 MB
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, product.MaxReturnPhotos*media.MaxUploadSize+1<<20)
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+			return
+		}
+		input.OrderID, _ = strconv.Atoi(c.PostForm("order_id"))
+		input.Reason = c.PostForm("reason")
+		if err := json.Unmarshal([]byte(c.PostForm("items")), &input.Items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "items must be a JSON array of {cart_item_id, quantity}"})
+			return
+		}
+		photos = form.File["photos"]
+	} else if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	// ตรวจคำขอก่อนเก็บไฟล์ จะได้ไม่ต้องเขียนไฟล์ที่ไม่ได้ใช้
+	if err := input.Validate(); err != nil {
+		respondReturnError(c, err)
+		return
+	}
+	if len(photos) > product.MaxReturnPhotos {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d photos are allowed", product.MaxReturnPhotos)})
+		return
+	}
+
+	var keys []string
+	for _, header := range photos {
+		stored, err := h.storePhoto(c.Request.Context(), input.OrderID, header)
+		if err != nil {
+			h.media.Delete(context.Background(), keys)
+			respondReturnError(c, err)
+			return
+		}
+		keys = append(keys, stored.Keys...)
+		input.Photos = append(input.Photos, product.ReturnPhoto{
+			URL:         stored.URL,
+			Thumbnails:  stored.Thumbnails,
+			StorageKeys: stored.Keys,
+		})
+	}
+
+	r, err := h.store.CreateReturn(c.Request.Context(), userID, input)
+	if err != nil {
+		// ไฟล์ถูกเขียนไปแล้วแต่ไม่ได้ผูกกับคำขอ จึงต้องลบทิ้ง (ใช้ context ใหม่เผื่อ request หมดเวลาแล้ว)
+		h.media.Delete(context.Background(), keys)
+		respondReturnError(c, err)
+		return
+	}
+
+	respondReturn(c, http.StatusCreated, r)
+}
+
+// storePhoto เก็บรูปประกอบหนึ่งรูปพร้อม thumbnail ไว้ใต้ returns/<order_id>
+func (h *ReturnHandlers) storePhoto(ctx context.Context, orderID int, header *multipart.FileHeader) (media.StoredImage, error) {
+	file, err := header.Open()
+	if err != nil {
+		return media.StoredImage{}, fmt.Errorf("failed to open uploaded photo: %v", err)
+	}
+	defer file.Close()
+
+	return h.media.StoreImage(ctx, fmt.Sprintf("returns/%d", orderID), file)
+}
+
+// GetReturns แสดงคำขอคืนสินค้า (?status=) ผู้ซื้อเห็นคำขอของตัวเอง ผู้ขายเห็นคำขอของร้าน admin เห็นทั้งหมด
+func (h *ReturnHandlers) GetReturns(c *gin.Context) {
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	filter := product.ReturnFilter{Status: c.Query("status")}
+	switch filter.Status {
+	case "", product.ReturnRequested, product.ReturnApproved, product.ReturnRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be requested, approved or rejected"})
+		return
+	}
+	switch {
+	case viewer.role == "admin":
+	case viewer.sellerID != 0:
+		filter.SellerID = viewer.sellerID
+	default:
+		filter.UserID = viewer.userID
+	}
+
+	returns, err := h.store.GetReturns(c.Request.Context(), filter)
+	if err != nil {
+		respondReturnError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range returns {
+		convertReturnTimes(&returns[i], loc)
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetReturn แสดงคำขอคืนสินค้าพร้อมรายการ รูปประกอบ และการคืนเงิน
+func (h *ReturnHandlers) GetReturn(c *gin.Context) {
+	returnID, ok := returnIDParam(c)
+	if !ok {
+		return
+	}
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	r, err := h.store.GetReturn(c.Request.Context(), returnID)
+	if err != nil {
+		respondReturnError(c, err)
+		return
+	}
+	if viewer.returnActor(r) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own return requests"})
+		return
+	}
+
+	respondReturn(c, http.StatusOK, r)
+}
+
+// decideReturn ตรวจว่าผู้ใช้เป็นผู้ขายของร้านในคำขอหรือ admin หากไม่ใช่จะตอบกลับให้เองและคืนค่าว่าง
+func (h *ReturnHandlers) decideReturn(c *gin.Context, returnID int, input *product.ReturnDecision) (shipmentViewer, string) {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return shipmentViewer{}, ""
+	}
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return shipmentViewer{}, ""
+	}
+
+	r, err := h.store.GetReturn(c.Request.Context(), returnID)
+	if err != nil {
+		respondReturnError(c, err)
+		return shipmentViewer{}, ""
+	}
+	actor := viewer.returnActor(r)
+	if actor != fulfillment.ActorSeller && actor != fulfillment.ActorAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the seller can decide on a return request"})
+		return shipmentViewer{}, ""
+	}
+	return viewer, actor
+}
+
+// ApproveReturn อนุมัติคำขอคืนสินค้า dispositions ระบุ restock หรือ write_off ต่อ return_item_id
+// (ไม่ระบุคือ restock) สินค้าถูกคืนเข้าคลังหรือตัดทิ้ง และสร้างรายการคืนเงิน
+func (h *ReturnHandlers) ApproveReturn(c *gin.Context) {
+	returnID, ok := returnIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input product.ReturnDecision
+	viewer, actor := h.decideReturn(c, returnID, &input)
+	if actor == "" {
+		return
+	}
+
+	r, err := h.store.ApproveReturn(c.Request.Context(), returnID, input, actor, viewer.userID)
+	if err != nil {
+		respondReturnError(c, err)
+		return
+	}
+
+	respondReturn(c, http.StatusOK, r)
+}
+
+// RejectReturn ปฏิเสธคำขอคืนสินค้า ต้องระบุ note เพื่อแจ้งเหตุผลผู้ซื้อ
+func (h *ReturnHandlers) RejectReturn(c *gin.Context) {
+	returnID, ok := returnIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input product.ReturnDecision
+	viewer, actor := h.decideReturn(c, returnID, &input)
+	if actor == "" {
+		return
+	}
+
+	r, err := h.store.RejectReturn(c.Request.Context(), returnID, input, viewer.userID)
+	if err != nil {
+		respondReturnError(c, err)
+		return
+	}
+
+	respondReturn(c, http.StatusOK, r)
+}
@@ -0,0 +1,225 @@
+// seller_handlers.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"productproject/internal/fulfillment"
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// respondSellerError แปลงข้อผิดพลาดจากการจัดการร้านค้าเป็น HTTP response
+func respondSellerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidSeller):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrSellerExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrSellerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+	default:
+		log.Printf("Error managing seller: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// canManageSeller ตรวจสอบว่าผู้ใช้ปัจจุบันเป็น admin หรือเป็นเจ้าของร้านตาม :id
+// หากไม่ได้จะตอบกลับให้เองและคืนค่า false
+func canManageSeller(c *gin.Context, store *product.Store) (int, bool) {
+	sellerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || sellerID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
+		return 0, false
+	}
+
+	scope, ok := sellerScope(c, store)
+	if !ok {
+		return 0, false
+	}
+	if scope != 0 && scope != sellerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage your own shop"})
+		return 0, false
+	}
+
+	return sellerID, true
+}
+
+// RegisterSeller ลงทะเบียนร้านค้าให้ผู้ใช้ที่ล็อกอินอยู่ และเปลี่ยน role เป็น seller
+// ผู้ดูแลระบบลงทะเบียนให้ผู้ใช้อื่นได้โดยระบุ user_id
+func (h *ProductHandlers) RegisterSeller(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		product.NewSeller
+		UserID string `json:"user_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	ownerID := userID
+	if input.UserID != "" && input.UserID != userID {
+		caller, err := h.store.GetUserByID(c.Request.Context(), userID)
+		if err != nil || caller.Role != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can register a shop for another user"})
+			return
+		}
+		if _, err := uuid.Parse(input.UserID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		ownerID = input.UserID
+	}
+
+	seller, err := h.store.RegisterSeller(c.Request.Context(), ownerID, input.NewSeller)
+	if err != nil {
+		respondSellerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, seller)
+}
+
+// GetMySeller แสดงข้อมูลร้านของผู้ใช้ที่ล็อกอินอยู่
+func (h *ProductHandlers) GetMySeller(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sellerID, err := h.store.GetSellerIDByUserID(c.Request.Context(), userID)
+	if errors.Is(err, product.ErrSellerNotLinked) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You do not have an active shop"})
+		return
+	}
+	if err != nil {
+		respondSellerError(c, err)
+		return
+	}
+
+	seller, err := h.store.GetSeller(c.Request.Context(), strconv.Itoa(sellerID))
+	if err != nil {
+		respondSellerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, seller)
+}
+
+// GetMySellerOrders กล่องคำสั่งซื้อของร้านของผู้ใช้ที่ล็อกอินอยู่ แสดงเฉพาะรายการของร้านพร้อมที่อยู่จัดส่งของผู้ซื้อ
+// กรองด้วย ?status= (สถานะการจัดส่ง) และ ?from= / ?to= (วันที่สั่งซื้อแบบ YYYY-MM-DD ตามเวลาไทย รวมวันสุดท้าย)
+// แบ่งหน้าด้วย ?limit= และ ?offset=
+func (h *ProductHandlers) GetMySellerOrders(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+
+	q := product.SellerOrderQuery{Status: c.Query("status")}
+	if q.Status != "" && !fulfillment.Valid(q.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("status must be one of %v", fulfillment.Statuses())})
+		return
+	}
+	for _, date := range []struct {
+		name string
+		days int // วันสุดท้ายรวมทั้งวัน จึงใช้ต้นวันถัดไปเป็นขอบบน
+		dst  **time.Time
+	}{{"from", 0, &q.From}, {"to", 1, &q.To}} {
+		v := c.Query(date.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a date in YYYY-MM-DD format", date.name)})
+			return
+		}
+		t = t.AddDate(0, 0, date.days)
+		*date.dst = &t
+	}
+	if q.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(product.DefaultSellerOrderLimit))); err != nil ||
+		q.Limit <= 0 || q.Limit > product.MaxSellerOrderLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", product.MaxSellerOrderLimit)})
+		return
+	}
+	if q.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || q.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	sellerID, err := h.store.GetSellerIDByUserID(c.Request.Context(), userID)
+	if errors.Is(err, product.ErrSellerNotLinked) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You do not have an active shop"})
+		return
+	}
+	if err != nil {
+		respondSellerError(c, err)
+		return
+	}
+
+	orders, err := h.store.GetSellerOrders(c.Request.Context(), sellerID, q)
+	if err != nil {
+		log.Printf("Error fetching seller orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+	for i := range orders {
+		orders[i].OrderDate = orders[i].OrderDate.In(loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"seller_id": sellerID, "orders": orders})
+}
+
+// UpdateSeller แก้ที่อยู่ เบอร์โทร อีเมล และคำอธิบายของร้าน (เฉพาะฟิลด์ที่ส่งมา)
+func (h *ProductHandlers) UpdateSeller(c *gin.Context) {
+	sellerID, ok := canManageSeller(c, h.store)
+	if !ok {
+		return
+	}
+
+	var input product.UpdateSeller
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	seller, err := h.store.UpdateSeller(c.Request.Context(), sellerID, input)
+	if err != nil {
+		respondSellerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, seller)
+}
+
+// DeactivateSeller ปิดร้าน สินค้าของร้านจะไม่แสดงและสั่งซื้อไม่ได้อีก
+func (h *ProductHandlers) DeactivateSeller(c *gin.Context) {
+	sellerID, ok := canManageSeller(c, h.store)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeactivateSeller(c.Request.Context(), sellerID); err != nil {
+		respondSellerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Seller deactivated successfully"})
+}
@@ -0,0 +1,320 @@
+// shipment_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"productproject/internal/fulfillment"
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondShipmentError แปลงข้อผิดพลาดจากการจัดการการจัดส่งเป็น HTTP response
+func respondShipmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrInvalidShipment), errors.Is(err, fulfillment.ErrUnknownStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, fulfillment.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrShipmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+	default:
+		log.Printf("Error managing shipment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func convertShipmentTimes(sh *product.Shipment, loc *time.Location) {
+	sh.CreatedAt = sh.CreatedAt.In(loc)
+	sh.UpdatedAt = sh.UpdatedAt.In(loc)
+	for _, t := range []**time.Time{&sh.ProcessingAt, &sh.PackedAt, &sh.ShippingAt, &sh.DeliveredAt,
+		&sh.ReceivedAt, &sh.CancelledAt, &sh.ReturnedAt} {
+		if *t != nil {
+			local := (*t).In(loc)
+			*t = &local
+		}
+	}
+	for i := range sh.History {
+		sh.History[i].CreatedAt = sh.History[i].CreatedAt.In(loc)
+	}
+}
+
+func shipmentIDParam(c *gin.Context) (int, bool) {
+	shipmentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || shipmentID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return 0, false
+	}
+	return shipmentID, true
+}
+
+// shipmentViewer ผู้ใช้ปัจจุบันกับร้านที่ผูกไว้ (sellerID เป็น 0 เมื่อไม่ใช่ผู้ขาย)
+type shipmentViewer struct {
+	userID   string
+	role     string
+	sellerID int
+}
+
+// currentShipmentViewer ดึงบทบาทและร้านของผู้ใช้ปัจจุบัน หากล้มเหลวจะตอบกลับให้เองและคืน false
+func currentShipmentViewer(c *gin.Context, store *product.Store) (shipmentViewer, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return shipmentViewer{}, false
+	}
+
+	u, err := store.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user for shipment access: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return shipmentViewer{}, false
+	}
+
+	viewer := shipmentViewer{userID: userID, role: u.Role}
+	if u.Role == "seller" {
+		sellerID, err := store.GetSellerIDByUserID(c.Request.Context(), userID)
+		if err != nil && !errors.Is(err, product.ErrSellerNotLinked) {
+			log.Printf("Error fetching seller for user: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check seller"})
+			return shipmentViewer{}, false
+		}
+		viewer.sellerID = sellerID
+	}
+	return viewer, true
+}
+
+// actor คืนบทบาทของผู้ใช้ต่อการจัดส่ง (admin, seller ของร้านนั้น หรือลูกค้าที่สั่งซื้อ)
+// คืนค่าว่างเมื่อไม่เกี่ยวข้องกับการจัดส่งนี้
+func (v shipmentViewer) actor(sh product.Shipment) string {
+	switch {
+	case v.role == "admin":
+		return fulfillment.ActorAdmin
+	case v.sellerID != 0 && v.sellerID == sh.SellerID:
+		return fulfillment.ActorSeller
+	case v.userID == sh.CustomerID:
+		return fulfillment.ActorCustomer
+	}
+	return ""
+}
+
+// GetShipments แสดงการจัดส่งของคำสั่งซื้อ (?order_id=) ลูกค้าเห็นทุกร้าน ผู้ขายเห็นเฉพาะร้านของตัวเอง
+func (h *ProductHandlers) GetShipments(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Query("order_id"))
+	if err != nil || orderID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order_id is required"})
+		return
+	}
+
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	shipments, err := h.store.GetShipments(c.Request.Context(), orderID)
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+	if len(shipments) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	visible := []product.Shipment{}
+	for _, sh := range shipments {
+		if viewer.actor(sh) != "" {
+			convertShipmentTimes(&sh, loc)
+			visible = append(visible, sh)
+		}
+	}
+	if len(visible) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// GetShipment แสดงการจัดส่งพร้อมเวลาของแต่ละสถานะและประวัติการเปลี่ยนสถานะ
+func (h *ProductHandlers) GetShipment(c *gin.Context) {
+	shipmentID, ok := shipmentIDParam(c)
+	if !ok {
+		return
+	}
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.store.GetShipment(c.Request.Context(), shipmentID)
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+	if viewer.actor(shipment) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own orders"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertShipmentTimes(&shipment, loc)
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// GetShipmentHistory แสดงเฉพาะประวัติการเปลี่ยนสถานะของการจัดส่ง เรียงตามเวลา
+func (h *ProductHandlers) GetShipmentHistory(c *gin.Context) {
+	shipmentID, ok := shipmentIDParam(c)
+	if !ok {
+		return
+	}
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.store.GetShipment(c.Request.Context(), shipmentID)
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+	if viewer.actor(shipment) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own orders"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertShipmentTimes(&shipment, loc)
+
+	c.JSON(http.StatusOK, shipment.History)
+}
+
+// UpdateShipmentStatus เปลี่ยนสถานะการจัดส่ง ผู้ขายเปลี่ยนได้เฉพาะร้านของตัวเอง
+// ลูกค้ายืนยันได้เฉพาะว่าได้รับสินค้าแล้ว (received) ส่วน admin เปลี่ยนได้ทุกสถานะตามลำดับ
+// ยกเว้น cancelled และ returned ซึ่งต้องผ่านการยกเลิกคำสั่งซื้อและคำขอคืนสินค้า
+func (h *ProductHandlers) UpdateShipmentStatus(c *gin.Context) {
+	shipmentID, ok := shipmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input product.ShipmentTransition
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.store.GetShipment(c.Request.Context(), shipmentID)
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+	actor := viewer.actor(shipment)
+	if actor == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own shipments"})
+		return
+	}
+
+	shipment, err = h.store.TransitionShipment(c.Request.Context(), shipmentID, input, actor, viewer.userID)
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertShipmentTimes(&shipment, loc)
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// CancelOrder ยกเลิกคำสั่งซื้อก่อนส่งของออก ผู้ซื้อยกเลิกได้ทุกร้านในคำสั่งซื้อ ผู้ขายยกเลิกได้เฉพาะร้านของตัวเอง
+// admin ระบุ seller_id เพื่อยกเลิกเฉพาะร้านได้ (0 คือทุกร้าน) สินค้าถูกคืนเข้าคลังและยอดรวมถูกปรับตาม
+func (h *ProductHandlers) CancelOrder(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || orderID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input struct {
+		Reason   string `json:"reason" binding:"required"`
+		SellerID int    `json:"seller_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	viewer, ok := currentShipmentViewer(c, h.store)
+	if !ok {
+		return
+	}
+
+	shipments, err := h.store.GetShipments(c.Request.Context(), orderID)
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+	if len(shipments) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	// ผู้ขายที่เป็นเจ้าของคำสั่งซื้อด้วยถือเป็นผู้ขาย เพื่อไม่ให้ยกเลิกร้านอื่นในคำสั่งซื้อเดียวกันได้
+	var actor string
+	var sellerID int
+	switch {
+	case viewer.role == "admin":
+		actor, sellerID = fulfillment.ActorAdmin, input.SellerID
+	case slices.ContainsFunc(shipments, func(sh product.Shipment) bool { return viewer.actor(sh) == fulfillment.ActorSeller }):
+		actor, sellerID = fulfillment.ActorSeller, viewer.sellerID
+	case viewer.userID == shipments[0].CustomerID:
+		actor = fulfillment.ActorCustomer
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only cancel your own orders"})
+		return
+	}
+
+	result, err := h.store.CancelOrder(c.Request.Context(), orderID, sellerID, input.Reason, actor, viewer.userID)
+	if err != nil {
+		if errors.Is(err, product.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		respondShipmentError(c, err)
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range result.Shipments {
+		convertShipmentTimes(&result.Shipments[i], loc)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
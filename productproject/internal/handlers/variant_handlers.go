@@ -0,0 +1,160 @@
+// variant_handlers.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"productproject/internal/inventory"
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondVariantError แปลงข้อผิดพลาดจากการจัดการตัวเลือกสินค้าเป็น HTTP response
+func respondVariantError(c *gin.Context, err error) {
+	var stockErr *inventory.InsufficientStockError
+	switch {
+	case errors.Is(err, product.ErrInvalidVariant), errors.Is(err, product.ErrInvalidProduct):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+	case errors.Is(err, product.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, product.ErrVariantExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &stockErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "items": stockErr.Items})
+	default:
+		log.Printf("Error managing variant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func respondVariant(c *gin.Context, status int, variant product.Variant) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	convertVariantTimes(&variant, loc)
+
+	c.JSON(status, variant)
+}
+
+func variantIDParam(c *gin.Context) (int, bool) {
+	variantID, err := strconv.Atoi(c.Param("variant_id"))
+	if err != nil || variantID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return 0, false
+	}
+	return variantID, true
+}
+
+// GetVariants แสดงตัวเลือกทั้งหมดของสินค้า (สี ขนาด รุ่น) พร้อมราคาและสต็อกของแต่ละตัว
+func (h *ProductHandlers) GetVariants(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	variants, err := h.store.GetVariants(c.Request.Context(), productID)
+	if err != nil {
+		log.Printf("Error fetching variants: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดึงข้อมูลตัวเลือกสินค้าได้"})
+		return
+	}
+	if len(variants) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		log.Fatal("ไม่สามารถโหลด timezone ได้:", err)
+	}
+	for i := range variants {
+		convertVariantTimes(&variants[i], loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "variants": variants})
+}
+
+// CreateVariant เพิ่มตัวเลือกให้สินค้า seller เพิ่มได้เฉพาะสินค้าของร้านตัวเอง
+func (h *ProductHandlers) CreateVariant(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	var input product.NewVariant
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	variant, err := h.store.CreateVariant(c.Request.Context(), productID, input, c.GetString("user_id"))
+	if err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	respondVariant(c, http.StatusCreated, variant)
+}
+
+// UpdateVariant (PATCH) แก้เฉพาะฟิลด์ที่ส่งมา ส่ง clear_price เพื่อกลับไปใช้ราคาของสินค้า
+func (h *ProductHandlers) UpdateVariant(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	var input product.UpdateVariant
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	variant, err := h.store.UpdateVariant(c.Request.Context(), productID, variantID, input, c.GetString("user_id"))
+	if err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	respondVariant(c, http.StatusOK, variant)
+}
+
+// DeleteVariant ลบตัวเลือก (soft delete) ตัดสต็อกที่เหลือ และนำออกจากตะกร้าที่ยังไม่สั่งซื้อ
+func (h *ProductHandlers) DeleteVariant(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := canManageProduct(c, h.store, productID); !ok {
+		return
+	}
+
+	err := h.store.DeleteVariant(c.Request.Context(), productID, variantID, c.GetString("user_id"))
+	if err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product variant deleted successfully"})
+}
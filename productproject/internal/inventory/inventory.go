@@ -0,0 +1,324 @@
+// inventory.go
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// inventory.quantity (หนึ่งแถวต่อตัวเลือกของสินค้า) คือจำนวนสต็อกที่ถูกต้องเพียงแหล่งเดียว
+// products.product_stock เป็นผลรวมของทุกตัวเลือกที่ trigger ในฐานข้อมูลซิงก์ให้ (ดู ecomdatabase/docker/init.sql)
+// โค้ดที่ต้องการเปลี่ยนสต็อกต้องผ่าน package นี้ ซึ่งแก้ inventory และบันทึก stock_movements ไปพร้อมกัน
+// ผลรวม change ใน stock_movements ของตัวเลือกจึงต้องเท่ากับ inventory.quantity เสมอ
+
+// ErrNotFound ถูกส่งคืนเมื่อไม่พบแถว inventory ของตัวเลือกที่ระบุ (หรือตัวเลือกไม่ใช่ของสินค้านั้น)
+var ErrNotFound = errors.New("inventory not found")
+
+// DB คือการเชื่อมต่อฐานข้อมูลที่ Service ต้องใช้ (product.PostgresDatabase ใช้ได้โดยตรง)
+type DB interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Shortage รายละเอียดของตัวเลือกสินค้าที่มีจำนวนคงเหลือไม่พอ
+type Shortage struct {
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// InsufficientStockError ถูกส่งคืนเมื่อสินค้าอย่างน้อยหนึ่งรายการมีไม่พอ
+// (เช่น ผู้ซื้อสองคนแย่งชิ้นสุดท้ายพร้อมกัน) โดยระบุทุกรายการที่ขาด
+type InsufficientStockError struct {
+	Items []Shortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	parts := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		parts = append(parts, fmt.Sprintf("product %d variant %d (%s %s): requested %d, available %d",
+			item.ProductID, item.VariantID, item.Name, item.SKU, item.Requested, item.Available))
+	}
+	return "insufficient stock for " + strings.Join(parts, "; ")
+}
+
+// Drift ตัวเลือกสินค้าที่ inventory.quantity ไม่ตรงกับผลรวมใน stock_movements
+// หรือสินค้าที่ผลรวม inventory ของทุกตัวเลือกไม่ตรงกับ products.product_stock
+type Drift struct {
+	ProductID      int    `json:"product_id"`
+	VariantID      int    `json:"variant_id"`
+	SKU            string `json:"sku"`
+	Name           string `json:"name"`
+	Quantity       *int   `json:"quantity"` // nil หมายถึงตัวเลือกนี้ยังไม่มีแถวใน inventory
+	ProductStock   int    `json:"product_stock"`
+	ProductTotal   int    `json:"product_total"`   // ผลรวม inventory.quantity ของทุกตัวเลือกของสินค้า
+	LedgerQuantity int    `json:"ledger_quantity"` // ผลรวม change ใน stock_movements ของตัวเลือก
+}
+
+// describeVariant คืนชื่อสินค้า SKU และ product_id ของตัวเลือก ใช้ประกอบ Shortage
+func describeVariant(ctx context.Context, tx *sql.Tx, variantID int) (Shortage, error) {
+	shortage := Shortage{VariantID: variantID}
+	err := tx.QueryRowContext(ctx, `
+		SELECT p.product_id, p.name, v.sku
+		FROM product_variants v
+		JOIN products p ON p.product_id = v.product_id
+		WHERE v.variant_id = $1`, variantID).Scan(&shortage.ProductID, &shortage.Name, &shortage.SKU)
+	if err != nil {
+		return Shortage{}, fmt.Errorf("failed to get product name for variant %d: %v", variantID, err)
+	}
+	return shortage, nil
+}
+
+// Decrement ล็อกแถว inventory ของตัวเลือกทุกตัว (เรียงตาม variant_id เพื่อกัน deadlock)
+// ตรวจสอบว่ามีของพอ แล้วตัด inventory.quantity พร้อมบันทึก movement แบบ sale
+// ภายใน transaction ของผู้เรียก requested คือจำนวนที่ต้องการต่อ variant_id
+// สินค้าที่ผู้ใช้อื่นจองไว้ในตะกร้า (ยังไม่หมดอายุ) จะไม่ถูกนับเป็นของที่ userID ซื้อได้
+// reference อ้างอิงเอกสารต้นทาง (เช่น "order:12") หากมีสินค้าไม่พอแม้แต่รายการเดียว
+// จะคืน *InsufficientStockError โดยไม่ตัดสต็อกใดๆ
+func Decrement(ctx context.Context, tx *sql.Tx, userID string, requested map[int]int, reference string) error {
+	variantIDs := make([]int, 0, len(requested))
+	for variantID := range requested {
+		variantIDs = append(variantIDs, variantID)
+	}
+	sort.Ints(variantIDs)
+
+	var shortages []Shortage
+	current := make(map[int]int, len(variantIDs))
+	for _, variantID := range variantIDs {
+		// ล็อกแถว inventory ไว้จนจบ transaction ผู้ซื้อคนอื่นต้องรอจนกว่าจะ commit
+		onHand, reserved, err := lockStock(ctx, tx, variantID, userID)
+		if err != nil {
+			return err
+		}
+		current[variantID] = onHand
+
+		available := onHand - reserved
+		if available < 0 {
+			available = 0
+		}
+		if available < requested[variantID] {
+			shortage, err := describeVariant(ctx, tx, variantID)
+			if err != nil {
+				return err
+			}
+			shortage.Requested = requested[variantID]
+			shortage.Available = available
+			shortages = append(shortages, shortage)
+		}
+	}
+
+	if len(shortages) > 0 {
+		return &InsufficientStockError{Items: shortages}
+	}
+
+	for _, variantID := range variantIDs {
+		_, err := applyLocked(ctx, tx, Movement{
+			VariantID: variantID,
+			Change:    -requested[variantID],
+			Reason:    ReasonSale,
+			Reference: reference,
+		}, current[variantID])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore คืนสินค้ากลับเข้าคลังตามจำนวนต่อ variant_id ใน quantities ภายใน transaction ของผู้เรียก
+// (ล็อกเรียงตาม variant_id เพื่อกัน deadlock) ทุกรายการบันทึก movement ด้วย Reason, Reference, Note
+// และ CreatedBy จาก template เช่นเมื่อยกเลิกคำสั่งซื้อหรือรับคืนสินค้า
+func Restore(ctx context.Context, tx *sql.Tx, quantities map[int]int, template Movement) error {
+	variantIDs := make([]int, 0, len(quantities))
+	for variantID := range quantities {
+		variantIDs = append(variantIDs, variantID)
+	}
+	sort.Ints(variantIDs)
+
+	for _, variantID := range variantIDs {
+		if quantities[variantID] <= 0 {
+			continue
+		}
+		m := template
+		m.ProductID = 0
+		m.VariantID = variantID
+		m.Change = quantities[variantID]
+		if _, err := Apply(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteOff บันทึกสินค้าที่รับคืนแต่ขายต่อไม่ได้ตามจำนวนต่อ variant_id ภายใน transaction ของผู้เรียก
+// แต่ละตัวเลือกมีสอง movement คือรับคืนเข้าคลัง (ReasonReturn) แล้วตัดออก (ReasonWriteOff)
+// สต็อกจึงไม่เปลี่ยนแต่สมุดบัญชีสต็อกยังเห็นจำนวนที่ถูกตัดทิ้ง Reason ใน template ไม่ถูกใช้
+func WriteOff(ctx context.Context, tx *sql.Tx, quantities map[int]int, template Movement) error {
+	variantIDs := make([]int, 0, len(quantities))
+	for variantID := range quantities {
+		variantIDs = append(variantIDs, variantID)
+	}
+	sort.Ints(variantIDs)
+
+	for _, variantID := range variantIDs {
+		if quantities[variantID] <= 0 {
+			continue
+		}
+		m := template
+		m.ProductID = 0
+		m.VariantID = variantID
+		for _, step := range []struct {
+			reason Reason
+			change int
+		}{{ReasonReturn, quantities[variantID]}, {ReasonWriteOff, -quantities[variantID]}} {
+			m.Reason = step.reason
+			m.Change = step.change
+			if _, err := Apply(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type Service struct {
+	db DB
+}
+
+func NewService(db DB) *Service {
+	return &Service{db: db}
+}
+
+// FindDrift คืนรายการตัวเลือกที่ inventory ไม่ตรงกับประวัติใน stock_movements
+// หรือไม่มีแถวใน inventory รวมถึงทุกตัวเลือกของสินค้าที่ผลรวม inventory ไม่ตรงกับ product_stock
+func (s *Service) FindDrift(ctx context.Context) ([]Drift, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.product_id, v.variant_id, v.sku, p.name, i.quantity, COALESCE(p.product_stock, 0),
+		       COALESCE(t.total, 0), COALESCE(l.total, 0)
+		FROM product_variants v
+		JOIN products p ON p.product_id = v.product_id
+		LEFT JOIN inventory i ON i.variant_id = v.variant_id
+		LEFT JOIN (
+			SELECT product_id, SUM(quantity) AS total
+			FROM inventory
+			GROUP BY product_id
+		) t ON t.product_id = p.product_id
+		LEFT JOIN (
+			SELECT variant_id, SUM(change) AS total
+			FROM stock_movements
+			GROUP BY variant_id
+		) l ON l.variant_id = v.variant_id
+		WHERE i.variant_id IS NULL
+		   OR i.quantity <> COALESCE(l.total, 0)
+		   OR COALESCE(t.total, 0) IS DISTINCT FROM p.product_stock
+		ORDER BY p.product_id, v.variant_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock drift: %v", err)
+	}
+	defer rows.Close()
+
+	var drifts []Drift
+	for rows.Next() {
+		var drift Drift
+		var quantity sql.NullInt64
+		if err := rows.Scan(&drift.ProductID, &drift.VariantID, &drift.SKU, &drift.Name, &quantity,
+			&drift.ProductStock, &drift.ProductTotal, &drift.LedgerQuantity); err != nil {
+			return nil, fmt.Errorf("failed to scan stock drift: %v", err)
+		}
+		if quantity.Valid {
+			q := int(quantity.Int64)
+			drift.Quantity = &q
+		}
+		drifts = append(drifts, drift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stock drift: %v", err)
+	}
+
+	return drifts, nil
+}
+
+// Reconcile แก้สต็อกที่ไม่ตรงกันโดยถือ inventory เป็นหลัก ตัวเลือกที่ไม่มีแถว inventory จะถูกสร้าง
+// (ตัวเลือกหลักได้ส่วนของ product_stock ที่ตัวเลือกอื่นไม่ได้ถือไว้ ตัวเลือกอื่นเริ่มที่ 0)
+// หากประวัติไม่ตรงกับ inventory จะบันทึก movement แบบ adjustment เพื่อให้ยอดตรงกันโดยไม่แก้ประวัติเดิม
+// แล้วซิงก์ product_stock ใหม่ คืนรายการที่ถูกแก้ (สถานะก่อนแก้)
+func (s *Service) Reconcile(ctx context.Context) ([]Drift, error) {
+	drifts, err := s.FindDrift(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(drifts) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	for _, drift := range drifts {
+		if drift.Quantity == nil {
+			// trigger จะซิงก์ product_stock ให้เท่ากับผลรวมใหม่
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO inventory (variant_id, product_id, quantity)
+				SELECT v.variant_id, v.product_id,
+				       CASE WHEN v.is_default THEN GREATEST($2::int - $3::int, 0) ELSE 0 END
+				FROM product_variants v
+				WHERE v.variant_id = $1
+				ON CONFLICT (variant_id) DO NOTHING`, drift.VariantID, drift.ProductStock, drift.ProductTotal)
+			if err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to reconcile variant %d: %v", drift.VariantID, err)
+			}
+		}
+
+		// บันทึกส่วนต่างระหว่าง inventory กับประวัติเป็น adjustment
+		var quantity, ledger int
+		err = tx.QueryRowContext(ctx, `
+			SELECT i.quantity, COALESCE((SELECT SUM(change) FROM stock_movements WHERE variant_id = i.variant_id), 0)
+			FROM inventory i
+			WHERE i.variant_id = $1
+			FOR UPDATE OF i`, drift.VariantID).Scan(&quantity, &ledger)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to read ledger for variant %d: %v", drift.VariantID, err)
+		}
+		if quantity != ledger {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO stock_movements (product_id, variant_id, change, quantity_after, reason, note)
+				VALUES ($1, $2, $3, $4, 'adjustment', 'reconciliation: untracked stock change')`,
+				drift.ProductID, drift.VariantID, quantity-ledger, quantity)
+			if err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to record reconciliation for variant %d: %v", drift.VariantID, err)
+			}
+		}
+
+		// trigger enforce_products_stock จะแทนค่าด้วยผลรวม inventory ของสินค้า
+		_, err = tx.ExecContext(ctx, `UPDATE products SET product_stock = product_stock WHERE product_id = $1`, drift.ProductID)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to reconcile product %d: %v", drift.ProductID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,175 @@
+// movements.go
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Reason เหตุผลของการเปลี่ยนแปลงสต็อก (ตรงกับ ENUM stock_movement_reason ในฐานข้อมูล)
+type Reason string
+
+const (
+	ReasonSale               Reason = "sale"                // ขายออกตอนสร้างคำสั่งซื้อ
+	ReasonReturn             Reason = "return"              // ลูกค้าคืนสินค้ากลับเข้าคลัง
+	ReasonRestock            Reason = "restock"             // ผู้ขายเติมสินค้า
+	ReasonAdjustment         Reason = "adjustment"          // ปรับยอดด้วยมือ เช่น นับสต็อกใหม่หรือของเสีย
+	ReasonReservationRelease Reason = "reservation_release" // การจองในตะกร้าหมดอายุ สินค้ากลับมาขายได้ (change เป็น 0)
+	ReasonCancellation       Reason = "cancellation"        // คืนสินค้าของคำสั่งซื้อที่ถูกยกเลิกกลับเข้าคลัง
+	ReasonWriteOff           Reason = "write_off"           // ตัดสินค้าที่รับคืนแต่ขายต่อไม่ได้ออกจากคลัง
+)
+
+// Valid ตรวจสอบว่าเป็นเหตุผลที่ระบบรู้จัก
+func (r Reason) Valid() bool {
+	switch r {
+	case ReasonSale, ReasonReturn, ReasonRestock, ReasonAdjustment, ReasonReservationRelease, ReasonCancellation, ReasonWriteOff:
+		return true
+	}
+	return false
+}
+
+// Movement หนึ่งรายการในสมุดบัญชีสต็อก (stock_movements) ซึ่งเพิ่มได้อย่างเดียว แก้หรือลบไม่ได้
+type Movement struct {
+	ID            int64     `json:"movement_id"`
+	ProductID     int       `json:"product_id"`
+	VariantID     int       `json:"variant_id"`
+	Change        int       `json:"change"`         // จำนวนที่เปลี่ยน (ติดลบคือออกจากคลัง)
+	QuantityAfter int       `json:"quantity_after"` // inventory.quantity ของตัวเลือกหลังการเปลี่ยนแปลง
+	Reason        Reason    `json:"reason"`
+	Reference     string    `json:"reference,omitempty"` // อ้างอิงเอกสารต้นทาง เช่น "order:12"
+	Note          string    `json:"note,omitempty"`
+	CreatedBy     *string   `json:"created_by,omitempty"` // users.user_id ของผู้ทำรายการ (nil = ระบบ)
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Apply ล็อกแถว inventory ของตัวเลือก m.VariantID ปรับ quantity ตาม m.Change และบันทึกลง stock_movements
+// ภายใน transaction ของผู้เรียก หากไม่ระบุ VariantID จะใช้ตัวเลือกหลักของ m.ProductID
+// หากระบุทั้งสองค่า ตัวเลือกต้องเป็นของสินค้านั้น มิฉะนั้นคืน ErrNotFound
+// หากทำให้สต็อกติดลบจะคืน *InsufficientStockError
+func Apply(ctx context.Context, tx *sql.Tx, m Movement) (Movement, error) {
+	if !m.Reason.Valid() {
+		return Movement{}, fmt.Errorf("invalid stock movement reason %q", m.Reason)
+	}
+	if m.VariantID <= 0 && m.ProductID <= 0 {
+		return Movement{}, fmt.Errorf("stock movement requires a variant or product")
+	}
+
+	var current int
+	err := tx.QueryRowContext(ctx, `
+		SELECT i.variant_id, i.product_id, i.quantity
+		FROM inventory i
+		JOIN product_variants v ON v.variant_id = i.variant_id
+		WHERE CASE WHEN $1 > 0 THEN i.variant_id = $1 ELSE v.is_default END
+		  AND ($2 <= 0 OR i.product_id = $2)
+		FOR UPDATE OF i`, m.VariantID, m.ProductID).Scan(&m.VariantID, &m.ProductID, &current)
+	if err == sql.ErrNoRows {
+		return Movement{}, ErrNotFound
+	} else if err != nil {
+		return Movement{}, fmt.Errorf("failed to lock stock for variant %d: %v", m.VariantID, err)
+	}
+
+	if current+m.Change < 0 {
+		shortage, err := describeVariant(ctx, tx, m.VariantID)
+		if err != nil {
+			return Movement{}, err
+		}
+		shortage.Requested = -m.Change
+		shortage.Available = current
+		return Movement{}, &InsufficientStockError{Items: []Shortage{shortage}}
+	}
+
+	return applyLocked(ctx, tx, m, current)
+}
+
+// applyLocked ทำงานเหมือน Apply แต่ถือว่าผู้เรียกล็อกแถว inventory ของ m.VariantID และตรวจสอบจำนวนไว้แล้ว
+func applyLocked(ctx context.Context, tx *sql.Tx, m Movement, current int) (Movement, error) {
+	m.QuantityAfter = current + m.Change
+
+	// products.product_stock จะถูกซิงก์ตามโดย trigger
+	_, err := tx.ExecContext(ctx, `
+		UPDATE inventory SET quantity = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE variant_id = $2`, m.QuantityAfter, m.VariantID)
+	if err != nil {
+		return Movement{}, fmt.Errorf("failed to update inventory for variant %d: %v", m.VariantID, err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO stock_movements (product_id, variant_id, change, quantity_after, reason, reference, note, created_by)
+		SELECT v.product_id, v.variant_id, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7
+		FROM product_variants v
+		WHERE v.variant_id = $1
+		RETURNING movement_id, product_id, created_at`,
+		m.VariantID, m.Change, m.QuantityAfter, m.Reason, m.Reference, m.Note, m.CreatedBy,
+	).Scan(&m.ID, &m.ProductID, &m.CreatedAt)
+	if err != nil {
+		return Movement{}, fmt.Errorf("failed to record stock movement for variant %d: %v", m.VariantID, err)
+	}
+
+	return m, nil
+}
+
+// Record ปรับสต็อกหนึ่งรายการพร้อมบันทึกประวัติใน transaction ของตัวเอง
+// ใช้กับการเติมสินค้า คืนสินค้า หรือปรับยอดด้วยมือจาก API
+func (s *Service) Record(ctx context.Context, m Movement) (Movement, error) {
+	if m.Change == 0 {
+		return Movement{}, fmt.Errorf("stock movement change must not be zero")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Movement{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	m, err = Apply(ctx, tx, m)
+	if err != nil {
+		tx.Rollback()
+		return Movement{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Movement{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return m, nil
+}
+
+// Movements คืนประวัติการเปลี่ยนแปลงสต็อกของสินค้า เรียงจากล่าสุด
+// variantID เป็น 0 คือทุกตัวเลือกของสินค้า
+func (s *Service) Movements(ctx context.Context, productID, variantID int, limit int) ([]Movement, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT movement_id, product_id, variant_id, change, quantity_after, reason,
+		       COALESCE(reference, ''), COALESCE(note, ''), created_by, created_at
+		FROM stock_movements
+		WHERE product_id = $1 AND ($2 = 0 OR variant_id = $2)
+		ORDER BY movement_id DESC
+		LIMIT $3
+	`, productID, variantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock movements: %v", err)
+	}
+	defer rows.Close()
+
+	var movements []Movement
+	for rows.Next() {
+		var m Movement
+		var createdBy sql.NullString
+		if err := rows.Scan(
+			&m.ID, &m.ProductID, &m.VariantID, &m.Change, &m.QuantityAfter, &m.Reason,
+			&m.Reference, &m.Note, &createdBy, &m.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %v", err)
+		}
+		if createdBy.Valid {
+			m.CreatedBy = &createdBy.String
+		}
+		movements = append(movements, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stock movements: %v", err)
+	}
+
+	return movements, nil
+}
@@ -0,0 +1,123 @@
+// reservations.go
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// การจองสินค้าในตะกร้า (stock_reservations) ไม่ได้ตัด inventory.quantity
+// แต่ถูกหักออกเมื่อคำนวณจำนวนที่ผู้ใช้คนอื่นซื้อได้ จนกว่าจะหมดอายุหรือถูกสั่งซื้อ
+// ทุกครั้งที่ผู้ใช้แก้ตะกร้า การจองทั้งหมดของผู้ใช้จะถูกต่ออายุ หากตะกร้าไม่มีการเคลื่อนไหว
+// จนเลย expires_at ตัวกวาด (ReleaseExpiredReservations) จะปล่อยสินค้ากลับมาขายได้
+
+// DefaultReservationTTL ระยะเวลาที่จองสินค้าไว้ให้ตะกร้าที่ไม่มีการเคลื่อนไหว
+const DefaultReservationTTL = 15 * time.Minute
+
+// Reservation การจองสินค้าของรายการในตะกร้าหนึ่งรายการ
+type Reservation struct {
+	CartItemID int
+	UserID     string
+	ProductID  int
+	VariantID  int
+	Quantity   int
+}
+
+// lockStock ล็อกแถว inventory ของตัวเลือก คืนจำนวนในคลังและจำนวนที่ผู้ใช้อื่นจองไว้และยังไม่หมดอายุ
+// userID ว่างหมายถึงนับการจองของทุกคน
+func lockStock(ctx context.Context, tx *sql.Tx, variantID int, userID string) (onHand int, reservedByOthers int, err error) {
+	err = tx.QueryRowContext(ctx, `
+		SELECT quantity FROM inventory
+		WHERE variant_id = $1
+		FOR UPDATE`, variantID).Scan(&onHand)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, fmt.Errorf("failed to lock stock for variant %d: %v", variantID, err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM stock_reservations
+		WHERE variant_id = $1 AND expires_at > CURRENT_TIMESTAMP
+		  AND user_id::text IS DISTINCT FROM NULLIF($2, '')`, variantID, userID).Scan(&reservedByOthers)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum reservations for variant %d: %v", variantID, err)
+	}
+
+	return onHand, reservedByOthers, nil
+}
+
+// LockAvailable ล็อกแถว inventory ของตัวเลือกและคืนจำนวนที่ผู้ใช้คนนี้ซื้อหรือจองได้
+// (สต็อกในคลังหักด้วยการจองที่ยังไม่หมดอายุของผู้ใช้อื่น)
+func LockAvailable(ctx context.Context, tx *sql.Tx, variantID int, userID string) (int, error) {
+	onHand, reserved, err := lockStock(ctx, tx, variantID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if onHand < reserved {
+		return 0, nil
+	}
+	return onHand - reserved, nil
+}
+
+// Reserve สร้างหรือปรับการจองของรายการในตะกร้าให้เท่ากับ r.Quantity และต่ออายุการจองทั้งหมดของผู้ใช้
+// ผู้เรียกต้องตรวจสอบจำนวนด้วย LockAvailable ใน transaction เดียวกันก่อน
+func Reserve(ctx context.Context, tx *sql.Tx, r Reservation, ttl time.Duration) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_reservations (cart_item_id, user_id, product_id, variant_id, quantity, expires_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + $6 * INTERVAL '1 second')
+		ON CONFLICT (cart_item_id) DO UPDATE
+		SET quantity = EXCLUDED.quantity, expires_at = EXCLUDED.expires_at, updated_at = CURRENT_TIMESTAMP`,
+		r.CartItemID, r.UserID, r.ProductID, r.VariantID, r.Quantity, ttl.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to reserve variant %d: %v", r.VariantID, err)
+	}
+
+	// ผู้ใช้ยังใช้งานตะกร้าอยู่ ต่ออายุการจองอื่นๆ ด้วย
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stock_reservations
+		SET expires_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+		WHERE user_id = $1 AND expires_at > CURRENT_TIMESTAMP`, r.UserID, ttl.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to extend reservations: %v", err)
+	}
+
+	return nil
+}
+
+// ReleaseCartItem ยกเลิกการจองของรายการในตะกร้า เช่น เมื่อรายการถูกสั่งซื้อแล้ว
+func ReleaseCartItem(ctx context.Context, tx *sql.Tx, cartItemID int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM stock_reservations WHERE cart_item_id = $1`, cartItemID)
+	if err != nil {
+		return fmt.Errorf("failed to release reservation for cart item %d: %v", cartItemID, err)
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations ลบการจองที่หมดอายุ คืนจำนวนรายการที่ถูกปล่อย
+// การปล่อยแต่ละรายการถูกบันทึกลง stock_movements เป็น reservation_release ที่ change เป็น 0
+// (การจองไม่ได้ตัด inventory.quantity) อ้างอิงรายการในตะกร้าและระบุจำนวนที่ถูกปล่อยไว้ใน note
+func (s *Service) ReleaseExpiredReservations(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		WITH released AS (
+			DELETE FROM stock_reservations
+			WHERE expires_at <= CURRENT_TIMESTAMP
+			RETURNING cart_item_id, product_id, variant_id, quantity
+		)
+		INSERT INTO stock_movements (product_id, variant_id, change, quantity_after, reason, reference, note)
+		SELECT r.product_id, r.variant_id, 0, COALESCE(i.quantity, 0), $1,
+		       'cart_item:' || r.cart_item_id, 'released ' || r.quantity || ' reserved units'
+		FROM released r
+		LEFT JOIN inventory i ON i.variant_id = r.variant_id`, ReasonReservationRelease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release expired reservations: %v", err)
+	}
+
+	released, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count released reservations: %v", err)
+	}
+
+	return released, nil
+}
@@ -0,0 +1,169 @@
+// image.go
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // ให้ image.Decode อ่าน GIF ได้
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidImage ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อไฟล์ที่อัปโหลดไม่ใช่รูปที่รองรับ ใหญ่เกินไป หรือเสียหาย
+var ErrInvalidImage = errors.New("invalid image")
+
+// MaxUploadSize ขนาดไฟล์รูปสูงสุดที่รับอัปโหลด
+const MaxUploadSize = 10 << 20
+
+// ขนาดภาพสูงสุด (กว้าง x สูง) กันไฟล์เล็กที่ขยายแล้วใช้หน่วยความจำมหาศาล
+const maxImagePixels = 40_000_000
+
+// คุณภาพของ thumbnail แบบ JPEG
+const jpegQuality = 85
+
+// ThumbnailSize ขนาดของ thumbnail หนึ่งแบบ ย่อให้ด้านที่ยาวที่สุดไม่เกิน MaxSide (ไม่ขยายรูปที่เล็กกว่า)
+type ThumbnailSize struct {
+	Name    string
+	MaxSide int
+}
+
+// DefaultThumbnailSizes ขนาด thumbnail ที่สร้างให้ทุกรูป
+var DefaultThumbnailSizes = []ThumbnailSize{
+	{Name: "small", MaxSide: 160},
+	{Name: "medium", MaxSide: 480},
+	{Name: "large", MaxSide: 1024},
+}
+
+// นามสกุลไฟล์ของชนิดรูปที่รองรับ
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// StoredImage รูปที่ถูกเก็บลง storage แล้ว พร้อม thumbnail ทุกขนาด
+type StoredImage struct {
+	URL         string
+	ContentType string
+	Width       int
+	Height      int
+	Thumbnails  map[string]string // ชื่อขนาด -> URL
+	Keys        []string          // key ของทุกไฟล์ (ต้นฉบับและ thumbnail) ใช้ตอนลบ
+}
+
+// Service รับไฟล์รูปที่อัปโหลด ตรวจสอบ สร้าง thumbnail และเก็บลง Storage
+type Service struct {
+	storage Storage
+	sizes   []ThumbnailSize
+}
+
+// NewService สร้าง Service ที่เก็บไฟล์ลง storage และสร้าง thumbnail ตาม DefaultThumbnailSizes
+func NewService(storage Storage) *Service {
+	return &Service{storage: storage, sizes: DefaultThumbnailSizes}
+}
+
+// StoreImage อ่านรูปจาก r (ไม่เกิน MaxUploadSize) แล้วเก็บต้นฉบับและ thumbnail ไว้ใต้ prefix
+// รองรับ JPEG, PNG และ GIF (ใช้เฟรมแรกทำ thumbnail) หากเก็บไม่สำเร็จจะลบไฟล์ที่เขียนไปแล้วออก
+func (s *Service) StoreImage(ctx context.Context, prefix string, r io.Reader) (StoredImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadSize+1))
+	if err != nil {
+		return StoredImage{}, fmt.Errorf("failed to read upload: %v", err)
+	}
+	if len(data) > MaxUploadSize {
+		return StoredImage{}, fmt.Errorf("%w: file must be at most %d MB", ErrInvalidImage, MaxUploadSize>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return StoredImage{}, fmt.Errorf("%w: unsupported file type %s (use JPEG, PNG or GIF)", ErrInvalidImage, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return StoredImage{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return StoredImage{}, fmt.Errorf("%w: image must be at most %d pixels", ErrInvalidImage, maxImagePixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return StoredImage{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	base := strings.Trim(prefix, "/") + "/" + uuid.NewString()
+	stored := StoredImage{
+		ContentType: contentType,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Thumbnails:  make(map[string]string, len(s.sizes)),
+	}
+
+	put := func(key string, body []byte, contentType string) error {
+		if err := s.storage.Put(ctx, key, bytes.NewReader(body), contentType); err != nil {
+			s.Delete(ctx, stored.Keys)
+			return err
+		}
+		stored.Keys = append(stored.Keys, key)
+		return nil
+	}
+
+	originalKey := base + ext
+	if err := put(originalKey, data, contentType); err != nil {
+		return StoredImage{}, err
+	}
+	stored.URL = s.storage.URL(originalKey)
+
+	// รูป JPEG ใช้ thumbnail เป็น JPEG ส่วน PNG และ GIF ใช้ PNG เพื่อเก็บพื้นหลังโปร่งใสไว้
+	thumbType, thumbExt := "image/jpeg", ".jpg"
+	if contentType != "image/jpeg" {
+		thumbType, thumbExt = "image/png", ".png"
+	}
+
+	src := toRGBA(img)
+	for _, size := range s.sizes {
+		var buf bytes.Buffer
+		thumb := resize(src, size.MaxSide)
+		if thumbType == "image/jpeg" {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			s.Delete(ctx, stored.Keys)
+			return StoredImage{}, fmt.Errorf("failed to encode %s thumbnail: %v", size.Name, err)
+		}
+
+		key := base + "_" + size.Name + thumbExt
+		if err := put(key, buf.Bytes(), thumbType); err != nil {
+			return StoredImage{}, err
+		}
+		stored.Thumbnails[size.Name] = s.storage.URL(key)
+	}
+
+	return stored, nil
+}
+
+// Delete ลบไฟล์ตาม keys ทั้งหมด ไฟล์ที่ลบไม่ได้จะถูก log ไว้และคืนข้อผิดพลาดแรกที่พบ
+func (s *Service) Delete(ctx context.Context, keys []string) error {
+	var first error
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete media %s: %v", key, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
@@ -0,0 +1,66 @@
+// resize.go
+package media
+
+import (
+	"image"
+	"image/draw"
+)
+
+// toRGBA แปลงรูปเป็น *image.RGBA ที่เริ่มที่ (0, 0) เพื่อให้อ่าน pixel ได้ตรงๆ
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// resize ย่อรูปให้ด้านที่ยาวที่สุดไม่เกิน maxSide โดยคงสัดส่วนเดิม รูปที่เล็กกว่านั้นคืนขนาดเดิม
+// ใช้ค่าเฉลี่ยของ pixel ในแต่ละช่อง (box filter) ซึ่งให้ผลดีพอสำหรับการย่อ thumbnail
+func resize(src *image.RGBA, maxSide int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+
+	dw, dh := maxSide, maxSide
+	if w >= h {
+		dh = max(1, (h*maxSide+w/2)/w)
+	} else {
+		dw = max(1, (w*maxSide+h/2)/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, (y+1)*h/dh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, (x+1)*w/dw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			off := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[off+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
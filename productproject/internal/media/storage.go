@@ -0,0 +1,98 @@
+// storage.go
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey ถูกส่งคืนเมื่อ key ของไฟล์ว่างหรือพยายามออกนอกโฟลเดอร์ของ storage
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage ที่เก็บไฟล์รูป key เป็น path แบบใช้ "/" คั่น เช่น "products/20/abc.jpg"
+// ใช้ LocalStorage เป็นค่าเริ่มต้น ส่วน backend อื่น (เช่น S3) ต้องทำ interface นี้ให้ครบ
+type Storage interface {
+	// Put เขียนไฟล์ทับ key เดิมหากมีอยู่แล้ว
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Delete ลบไฟล์ ไม่ถือเป็นข้อผิดพลาดหากไม่มีไฟล์นั้นอยู่แล้ว
+	Delete(ctx context.Context, key string) error
+	// URL คืน URL สาธารณะของไฟล์
+	URL(key string) string
+}
+
+// LocalStorage เก็บไฟล์ไว้บนดิสก์ในโฟลเดอร์ dir และให้บริการผ่าน baseURL
+// (เช่น "/media" ที่ cmd/main.go เปิดเป็น static route ไว้)
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStorage สร้าง LocalStorage และสร้างโฟลเดอร์ dir หากยังไม่มี
+func NewLocalStorage(dir, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %v", err)
+	}
+	return &LocalStorage{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+// path แปลง key เป็น path บนดิสก์ โดยไม่ยอมให้ออกนอก dir
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned == "/" || cleaned[1:] != key {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create media directory: %v", err)
+	}
+
+	// เขียนลงไฟล์ชั่วคราวก่อนแล้วค่อยเปลี่ยนชื่อ เพื่อไม่ให้ผู้ใช้เห็นไฟล์ที่เขียนไม่ครบ
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create media file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write media file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write media file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write media file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("failed to save media file: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete media file: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
@@ -0,0 +1,71 @@
+// auth_middleware.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"productproject/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// AuthMiddleware ตรวจสอบ JWT (HS256) ที่ออกโดย login service
+// รับ token ได้ทั้งจาก header "Authorization: Bearer <token>" และจาก cookie "token"
+// เมื่อผ่านการตรวจสอบจะเก็บ user_id ไว้ใน gin context ภายใต้ key "user_id"
+func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, err := extractToken(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		claims, err := verifyToken(tokenString, cfg.JWTSecret)
+		if err != nil || claims.Subject == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		c.Set("user_id", claims.Subject)
+		c.Next()
+	}
+}
+
+// ดึง token จาก Authorization header ก่อน ถ้าไม่มีจึงใช้ cookie "token"
+func extractToken(c *gin.Context) (string, error) {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		parts := strings.SplitN(authHeader, " ", 2)
+		if !(len(parts) == 2 && parts[0] == "Bearer" && parts[1] != "") {
+			return "", fmt.Errorf("Invalid authorization header format")
+		}
+		return parts[1], nil
+	}
+
+	if token, err := c.Cookie("token"); err == nil && token != "" {
+		return token, nil
+	}
+
+	return "", fmt.Errorf("Authorization token is required")
+}
+
+func verifyToken(tokenString string, secret string) (*jwt.StandardClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+		// ยอมรับเฉพาะ token ที่เซ็นด้วย HMAC เท่านั้น (login service ใช้ HS256)
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*jwt.StandardClaims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("invalid token")
+}
@@ -0,0 +1,46 @@
+// role_middleware.go
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	product "productproject/internal/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole อนุญาตเฉพาะผู้ใช้ที่มี role (users.role) ตรงกับที่ระบุและบัญชียัง active อยู่
+// ต้องใช้หลัง AuthMiddleware เมื่อผ่านจะเก็บ role ไว้ใน gin context ภายใต้ key "user_role"
+func RequireRole(store *product.Store, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// อ่าน role จากฐานข้อมูลทุกครั้ง เพื่อให้การถอดสิทธิ์มีผลทันทีโดยไม่ต้องรอ token หมดอายุ
+		user, err := store.GetUserByID(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("Error checking user role: %v", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+
+		if user.Status != "active" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Set("user_role", user.Role)
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+	}
+}
@@ -0,0 +1,207 @@
+// attributes.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// อายุสูงสุดที่ระบุได้ (18 ปี)
+const maxAgeMonths = 216
+
+// ขนาดสูงสุดที่คอลัมน์ NUMERIC(7, 1) ของขนาดสินค้าเก็บได้ (ซม.)
+const maxDimensionCM = 99999.9
+
+// น้ำหนักสูงสุดที่ระบุได้ (กรัม)
+const maxWeightG = 1000000
+
+// batteryTypes ชนิดถ่านที่รองรับ (key ตัวพิมพ์ใหญ่ -> ค่าที่เก็บ)
+// none คือไม่ต้องใช้ถ่าน ส่วน rechargeable คือแบตเตอรี่ในตัวที่ชาร์จได้
+var batteryTypes = map[string]string{
+	"NONE":         "none",
+	"AA":           "AA",
+	"AAA":          "AAA",
+	"C":            "C",
+	"D":            "D",
+	"9V":           "9V",
+	"CR2032":       "CR2032",
+	"LR44":         "LR44",
+	"RECHARGEABLE": "rechargeable",
+}
+
+// normalizeBatteryType คืนชนิดถ่านในรูปที่เก็บในฐานข้อมูล หรือ false หากไม่รองรับ
+func normalizeBatteryType(batteryType string) (string, bool) {
+	normalized, ok := batteryTypes[strings.ToUpper(strings.TrimSpace(batteryType))]
+	return normalized, ok
+}
+
+// ToyAttributes ข้อมูลจำเพาะของของเล่น ฟิลด์ที่เป็น nil คือไม่ได้ระบุ
+// อายุเป็นเดือน (MaxAgeMonths เป็น nil คือไม่จำกัด) ขนาดเป็นเซนติเมตร น้ำหนักเป็นกรัม
+type ToyAttributes struct {
+	MinAgeMonths *int     `json:"min_age_months"`
+	MaxAgeMonths *int     `json:"max_age_months"`
+	BatteryType  *string  `json:"battery_type"` // AA, AAA, C, D, 9V, CR2032, LR44, rechargeable หรือ none
+	BatteryCount *int     `json:"battery_count"`
+	LengthCM     *float64 `json:"length_cm"`
+	WidthCM      *float64 `json:"width_cm"`
+	HeightCM     *float64 `json:"height_cm"`
+	WeightG      *int     `json:"weight_g"`
+	Material     *string  `json:"material"`
+}
+
+// attributeColumns คอลัมน์ของ ToyAttributes ในตาราง products เรียงตาม scanTargets และ values
+// ชื่อคอลัมน์ตรงกับชื่อฟิลด์ใน JSON ซึ่งใช้กับ clear_attributes ด้วย
+var attributeColumns = []string{
+	"min_age_months", "max_age_months", "battery_type", "battery_count",
+	"length_cm", "width_cm", "height_cm", "weight_g", "material",
+}
+
+// attributeSelect รายการคอลัมน์สำหรับ SELECT จากตาราง products ที่ใช้ alias p
+var attributeSelect = "p." + strings.Join(attributeColumns, ", p.")
+
+// scanTargets ตัวแปรปลายทางสำหรับ Scan ตามลำดับของ attributeColumns
+func (a *ToyAttributes) scanTargets() []interface{} {
+	return []interface{}{
+		&a.MinAgeMonths, &a.MaxAgeMonths, &a.BatteryType, &a.BatteryCount,
+		&a.LengthCM, &a.WidthCM, &a.HeightCM, &a.WeightG, &a.Material,
+	}
+}
+
+// values ค่าของแต่ละฟิลด์ (nil คือ NULL) ตามลำดับของ attributeColumns
+func (a ToyAttributes) values() []interface{} {
+	return []interface{}{
+		a.MinAgeMonths, a.MaxAgeMonths, a.BatteryType, a.BatteryCount,
+		a.LengthCM, a.WidthCM, a.HeightCM, a.WeightG, a.Material,
+	}
+}
+
+// Unset คืนชื่อฟิลด์ที่ไม่ได้ระบุ ใช้ล้างค่าเดิมเมื่อแทนที่สินค้าทั้งรายการ (PUT)
+func (a ToyAttributes) Unset() []string {
+	var unset []string
+	for i, isSet := range []bool{
+		a.MinAgeMonths != nil, a.MaxAgeMonths != nil, a.BatteryType != nil, a.BatteryCount != nil,
+		a.LengthCM != nil, a.WidthCM != nil, a.HeightCM != nil, a.WeightG != nil, a.Material != nil,
+	} {
+		if !isSet {
+			unset = append(unset, attributeColumns[i])
+		}
+	}
+	return unset
+}
+
+// Validate ตรวจสอบค่าที่ระบุมา และปรับชนิดถ่านกับวัสดุให้อยู่ในรูปที่เก็บ
+func (a *ToyAttributes) Validate() error {
+	for _, age := range []struct {
+		field string
+		value *int
+	}{{"min_age_months", a.MinAgeMonths}, {"max_age_months", a.MaxAgeMonths}} {
+		if age.value != nil && (*age.value < 0 || *age.value > maxAgeMonths) {
+			return fmt.Errorf("%w: %s must be between 0 and %d", ErrInvalidProduct, age.field, maxAgeMonths)
+		}
+	}
+	if a.BatteryType != nil {
+		batteryType, ok := normalizeBatteryType(*a.BatteryType)
+		if !ok {
+			return fmt.Errorf("%w: battery_type must be one of none, AA, AAA, C, D, 9V, CR2032, LR44, rechargeable", ErrInvalidProduct)
+		}
+		a.BatteryType = &batteryType
+	}
+	if a.BatteryCount != nil && (*a.BatteryCount <= 0 || *a.BatteryCount > 50) {
+		return fmt.Errorf("%w: battery_count must be between 1 and 50", ErrInvalidProduct)
+	}
+	for _, dim := range []struct {
+		field string
+		value *float64
+	}{{"length_cm", a.LengthCM}, {"width_cm", a.WidthCM}, {"height_cm", a.HeightCM}} {
+		if dim.value != nil && (*dim.value <= 0 || *dim.value > maxDimensionCM) {
+			return fmt.Errorf("%w: %s must be greater than 0 and at most %.1f", ErrInvalidProduct, dim.field, maxDimensionCM)
+		}
+	}
+	if a.WeightG != nil && (*a.WeightG <= 0 || *a.WeightG > maxWeightG) {
+		return fmt.Errorf("%w: weight_g must be between 1 and %d", ErrInvalidProduct, maxWeightG)
+	}
+	if a.Material != nil {
+		material := strings.TrimSpace(*a.Material)
+		if material == "" || len(material) > 100 {
+			return fmt.Errorf("%w: material must be non-empty and at most 100 characters", ErrInvalidProduct)
+		}
+		a.Material = &material
+	}
+	return nil
+}
+
+// checkConsistency ตรวจความสอดคล้องระหว่างฟิลด์ของข้อมูลที่รวมกับค่าเดิมแล้ว
+func (a ToyAttributes) checkConsistency() error {
+	if a.MinAgeMonths != nil && a.MaxAgeMonths != nil && *a.MinAgeMonths > *a.MaxAgeMonths {
+		return fmt.Errorf("%w: min_age_months must not be greater than max_age_months", ErrInvalidProduct)
+	}
+	if a.BatteryCount != nil && (a.BatteryType == nil || *a.BatteryType == "none") {
+		return fmt.Errorf("%w: battery_count requires a battery_type other than none", ErrInvalidProduct)
+	}
+	return nil
+}
+
+// validateClearAttributes ตรวจว่าชื่อใน clear ถูกต้องและไม่ถูกระบุค่าใหม่พร้อมกัน
+func validateClearAttributes(attrs *ToyAttributes, clear []string) error {
+	var set ToyAttributes
+	if attrs != nil {
+		set = *attrs
+	}
+	unset := make(map[string]bool)
+	for _, name := range set.Unset() {
+		unset[name] = true
+	}
+
+	for _, name := range clear {
+		known := false
+		for _, column := range attributeColumns {
+			if name == column {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown attribute %q in clear_attributes", ErrInvalidProduct, name)
+		}
+		if !unset[name] {
+			return fmt.Errorf("%w: attribute %q cannot be set and cleared together", ErrInvalidProduct, name)
+		}
+	}
+	return nil
+}
+
+// updateAttributes แก้ข้อมูลจำเพาะของสินค้าเฉพาะฟิลด์ที่ระบุใน attrs และล้างฟิลด์ใน clear
+// แล้วตรวจความสอดคล้องของค่าที่รวมกับค่าเดิมแล้ว (เช่นอายุต่ำสุดไม่เกินอายุสูงสุด)
+func updateAttributes(ctx context.Context, tx *sql.Tx, productID int, attrs *ToyAttributes, clear []string) error {
+	var set ToyAttributes
+	if attrs != nil {
+		set = *attrs
+	}
+
+	assignments := make([]string, len(attributeColumns))
+	for i, column := range attributeColumns {
+		assignments[i] = fmt.Sprintf("%s = CASE WHEN '%s' = ANY($2::text[]) THEN NULL ELSE COALESCE($%d, %s) END",
+			column, column, i+3, column)
+	}
+
+	args := append([]interface{}{productID, pq.Array(clear)}, set.values()...)
+
+	var merged ToyAttributes
+	err := tx.QueryRowContext(ctx, `
+		UPDATE products SET `+strings.Join(assignments, ", ")+`
+		WHERE product_id = $1
+		RETURNING `+strings.Join(attributeColumns, ", "),
+		args...,
+	).Scan(merged.scanTargets()...)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to update product attributes: %v", err)
+	}
+
+	return merged.checkConsistency()
+}
@@ -0,0 +1,184 @@
+// cancel.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"productproject/internal/fulfillment"
+	"productproject/internal/inventory"
+
+	"github.com/lib/pq"
+)
+
+// ErrOrderNotFound ถูกส่งคืนเมื่อไม่พบคำสั่งซื้อ
+var ErrOrderNotFound = errors.New("order not found")
+
+// CancelledOrder ผลการยกเลิกคำสั่งซื้อ: การจัดส่งที่ถูกยกเลิก ยอดที่ยกเลิก และยอดรวมใหม่ของคำสั่งซื้อ
+type CancelledOrder struct {
+	OrderID         int        `json:"order_id"`
+	CancelledAmount float64    `json:"cancelled_amount"`
+	TotalAmount     float64    `json:"total_amount"`
+	Shipments       []Shipment `json:"shipments"`
+}
+
+// CancelOrder ยกเลิกการจัดส่งของคำสั่งซื้อที่ยังไม่ได้ส่งของออก (processing หรือ packed)
+// sellerID เป็น 0 คือทุกร้านในคำสั่งซื้อ (ผู้ซื้อหรือ admin) การจัดส่งที่ส่งออกไปแล้วจะถูกข้าม
+// หากไม่มีการจัดส่งใดยกเลิกได้จะคืน fulfillment.ErrInvalidTransition
+// รายการที่ถูกยกเลิกถูกคืนเข้าคลัง ยอดของรายการถูกหักจาก orders.total_amount
+// และเก็บผู้ยกเลิกกับเหตุผลไว้ในการจัดส่งและประวัติ ทั้งหมดใน transaction เดียวกัน
+func (pdb *PostgresDatabase) CancelOrder(ctx context.Context, orderID, sellerID int, reason, actor, userID string) (CancelledOrder, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len([]rune(reason)) > 500 {
+		return CancelledOrder{}, fmt.Errorf("%w: reason must be non-empty and at most 500 characters", ErrInvalidShipment)
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return CancelledOrder{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	// ล็อกคำสั่งซื้อไว้เพื่อให้การหักยอดรวมไม่ชนกับการยกเลิกพร้อมกัน
+	var exists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT TRUE FROM orders WHERE order_id = $1 FOR UPDATE`, orderID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return CancelledOrder{}, ErrOrderNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to lock order: %v", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT shipment_id, order_id, seller_id, status FROM shipments
+		WHERE order_id = $1 AND ($2 = 0 OR seller_id = $2)
+		ORDER BY shipment_id
+		FOR UPDATE`, orderID, sellerID)
+	if err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to lock shipments: %v", err)
+	}
+	var locked []lockedShipment
+	for rows.Next() {
+		var sh lockedShipment
+		if err := rows.Scan(&sh.id, &sh.orderID, &sh.sellerID, &sh.status); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return CancelledOrder{}, fmt.Errorf("failed to scan shipment: %v", err)
+		}
+		locked = append(locked, sh)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to iterate shipments: %v", err)
+	}
+	if len(locked) == 0 {
+		tx.Rollback()
+		return CancelledOrder{}, ErrShipmentNotFound
+	}
+
+	// ยกเลิกเฉพาะการจัดส่งที่ยังยกเลิกได้ ข้อผิดพลาดแรกใช้อธิบายเมื่อไม่มีรายการใดยกเลิกได้เลย
+	transition := ShipmentTransition{Status: fulfillment.Cancelled, Note: reason}
+	var cancelledSellers []int64
+	var cancelledIDs []int
+	var firstErr error
+	for _, sh := range locked {
+		if err := fulfillment.Transition(sh.status, fulfillment.Cancelled, actor); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := applyShipmentTransition(ctx, tx, sh, transition, actor, userID); err != nil {
+			tx.Rollback()
+			return CancelledOrder{}, err
+		}
+		cancelledSellers = append(cancelledSellers, int64(sh.sellerID))
+		cancelledIDs = append(cancelledIDs, sh.id)
+	}
+	if len(cancelledIDs) == 0 {
+		tx.Rollback()
+		return CancelledOrder{}, firstErr
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE shipments SET cancelled_by = $2, cancellation_reason = $3
+		WHERE shipment_id = ANY($1)`, pq.Array(cancelledIDs), userID, reason)
+	if err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to record cancellation: %v", err)
+	}
+
+	// คืนสินค้าของรายการที่ถูกยกเลิกเข้าคลัง
+	rows, err = tx.QueryContext(ctx, `
+		SELECT variant_id, SUM(quantity)
+		FROM order_items
+		WHERE order_id = $1 AND seller_id = ANY($2)
+		GROUP BY variant_id`, orderID, pq.Array(cancelledSellers))
+	if err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to query cancelled items: %v", err)
+	}
+	restored := make(map[int]int)
+	for rows.Next() {
+		var variantID, quantity int
+		if err := rows.Scan(&variantID, &quantity); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return CancelledOrder{}, fmt.Errorf("failed to scan cancelled item: %v", err)
+		}
+		restored[variantID] = quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to iterate cancelled items: %v", err)
+	}
+
+	err = inventory.Restore(ctx, tx, restored, inventory.Movement{
+		Reason:    inventory.ReasonCancellation,
+		Reference: fmt.Sprintf("order:%d", orderID),
+		Note:      reason,
+		CreatedBy: &userID,
+	})
+	if err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, err
+	}
+
+	// หักยอดของรายการที่ถูกยกเลิกออกจากยอดรวมของคำสั่งซื้อ
+	result := CancelledOrder{OrderID: orderID}
+	err = tx.QueryRowContext(ctx, `
+		WITH cancelled AS (
+			SELECT COALESCE(SUM(line_total), 0) AS amount
+			FROM order_items
+			WHERE order_id = $1 AND seller_id = ANY($2)
+		)
+		UPDATE orders SET total_amount = total_amount - cancelled.amount
+		FROM cancelled
+		WHERE order_id = $1
+		RETURNING cancelled.amount, orders.total_amount`, orderID, pq.Array(cancelledSellers)).Scan(
+		&result.CancelledAmount, &result.TotalAmount)
+	if err != nil {
+		tx.Rollback()
+		return CancelledOrder{}, fmt.Errorf("failed to update order total: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return CancelledOrder{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	for _, id := range cancelledIDs {
+		sh, err := pdb.GetShipment(ctx, id)
+		if err != nil {
+			return CancelledOrder{}, err
+		}
+		result.Shipments = append(result.Shipments, sh)
+	}
+	return result, nil
+}
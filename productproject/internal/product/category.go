@@ -0,0 +1,347 @@
+// category.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCategory ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลหมวดหมู่ไม่ผ่านการตรวจสอบ
+var ErrInvalidCategory = errors.New("invalid category")
+
+// ErrCategoryNotFound ถูกส่งคืนเมื่อไม่พบหมวดหมู่
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrCategoryInUse ถูกส่งคืนเมื่อจะลบหมวดหมู่ที่ยังมีหมวดหมู่ย่อยหรือสินค้าอยู่
+var ErrCategoryInUse = errors.New("category is in use")
+
+// CategoryNode หมวดหมู่พร้อมหมวดหมู่ย่อย ใช้แสดงเป็นต้นไม้
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// NewCategory ข้อมูลสำหรับสร้างหมวดหมู่ ParentID เป็น nil คือหมวดหมู่ระดับบนสุด
+// ระบุ MinAgeMonths (และ MaxAgeMonths) เมื่อหมวดหมู่เป็นช่วงอายุ
+type NewCategory struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	ParentID     *int   `json:"parent_id"`
+	MinAgeMonths *int   `json:"min_age_months"`
+	MaxAgeMonths *int   `json:"max_age_months"`
+}
+
+// UpdateCategory ข้อมูลสำหรับแก้หมวดหมู่ ฟิลด์ที่เป็น nil จะไม่ถูกแก้
+// ParentID เป็น 0 คือย้ายไปเป็นหมวดหมู่ระดับบนสุด ClearAgeRange ให้หมวดหมู่ไม่เป็นช่วงอายุอีก
+type UpdateCategory struct {
+	Name          *string `json:"name"`
+	Description   *string `json:"description"`
+	ParentID      *int    `json:"parent_id"`
+	MinAgeMonths  *int    `json:"min_age_months"`
+	MaxAgeMonths  *int    `json:"max_age_months"`
+	ClearAgeRange bool    `json:"clear_age_range"`
+}
+
+func validateCategoryName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 60 {
+		return fmt.Errorf("%w: name is required and must be at most 60 characters", ErrInvalidCategory)
+	}
+	return nil
+}
+
+// validateCategoryAgeRange ตรวจช่วงอายุของหมวดหมู่ (ระบุอายุสูงสุดต้องระบุอายุต่ำสุดด้วย)
+func validateCategoryAgeRange(minAge, maxAge *int) error {
+	if maxAge != nil && minAge == nil {
+		return fmt.Errorf("%w: max_age_months requires min_age_months", ErrInvalidCategory)
+	}
+	for _, age := range []*int{minAge, maxAge} {
+		if age != nil && (*age < 0 || *age > maxAgeMonths) {
+			return fmt.Errorf("%w: age range must be between 0 and %d months", ErrInvalidCategory, maxAgeMonths)
+		}
+	}
+	if minAge != nil && maxAge != nil && *minAge > *maxAge {
+		return fmt.Errorf("%w: min_age_months must not be greater than max_age_months", ErrInvalidCategory)
+	}
+	return nil
+}
+
+// checkCategoryName ตรวจว่าชื่อหมวดหมู่ยังไม่ถูกใช้ (ยกเว้นหมวดหมู่ excludeID)
+func checkCategoryName(ctx context.Context, tx *sql.Tx, name string, excludeID int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM categories WHERE name = $1 AND category_id <> $2)`, name, excludeID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check category name: %v", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: name %q is already used", ErrInvalidCategory, name)
+	}
+	return nil
+}
+
+// GetCategoryTree คืนหมวดหมู่ทั้งหมดเป็นต้นไม้ เรียงตามชื่อในแต่ละระดับ
+func (pdb *PostgresDatabase) GetCategoryTree(ctx context.Context) ([]CategoryNode, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT category_id, name, COALESCE(description, ''), parent_id, min_age_months, max_age_months
+		FROM categories
+		ORDER BY category_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %v", err)
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var category Category
+		var parentID sql.NullInt64
+		if err := rows.Scan(&category.ID, &category.Name, &category.Description, &parentID,
+			&category.MinAgeMonths, &category.MaxAgeMonths); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			category.ParentID = &id
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate categories: %v", err)
+	}
+
+	return buildCategoryTree(categories, nil), nil
+}
+
+// buildCategoryTree สร้างต้นไม้จากรายการหมวดหมู่ โดยเริ่มจากหมวดหมู่ที่มีแม่เป็น parentID
+func buildCategoryTree(categories []Category, parentID *int) []CategoryNode {
+	nodes := []CategoryNode{}
+	for _, category := range categories {
+		isChild := (parentID == nil && category.ParentID == nil) ||
+			(parentID != nil && category.ParentID != nil && *category.ParentID == *parentID)
+		if !isChild {
+			continue
+		}
+		id := category.ID
+		nodes = append(nodes, CategoryNode{
+			Category: category,
+			Children: buildCategoryTree(categories, &id),
+		})
+	}
+	return nodes
+}
+
+// CreateCategory สร้างหมวดหมู่ใหม่ (เป็นหมวดหมู่ย่อยได้หากระบุ ParentID)
+func (pdb *PostgresDatabase) CreateCategory(ctx context.Context, c NewCategory) (Category, error) {
+	if err := validateCategoryName(c.Name); err != nil {
+		return Category{}, err
+	}
+	if err := validateCategoryAgeRange(c.MinAgeMonths, c.MaxAgeMonths); err != nil {
+		return Category{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Category{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	category := Category{
+		Name:         strings.TrimSpace(c.Name),
+		Description:  c.Description,
+		ParentID:     c.ParentID,
+		MinAgeMonths: c.MinAgeMonths,
+		MaxAgeMonths: c.MaxAgeMonths,
+	}
+	if err := checkCategoryName(ctx, tx, category.Name, 0); err != nil {
+		tx.Rollback()
+		return Category{}, err
+	}
+
+	if c.ParentID != nil {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM categories WHERE category_id = $1)`, *c.ParentID).Scan(&exists)
+		if err != nil {
+			tx.Rollback()
+			return Category{}, fmt.Errorf("failed to check parent category: %v", err)
+		}
+		if !exists {
+			tx.Rollback()
+			return Category{}, fmt.Errorf("%w: parent category %d does not exist", ErrInvalidCategory, *c.ParentID)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO categories (name, description, parent_id, min_age_months, max_age_months)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING category_id`, category.Name, category.Description, category.ParentID,
+		category.MinAgeMonths, category.MaxAgeMonths).Scan(&category.ID)
+	if err != nil {
+		tx.Rollback()
+		return Category{}, fmt.Errorf("failed to create category: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Category{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return category, nil
+}
+
+// UpdateCategory แก้ชื่อ คำอธิบาย หรือย้ายหมวดหมู่ไปอยู่ใต้หมวดหมู่อื่น
+// ย้ายไปอยู่ใต้ตัวเองหรือหมวดหมู่ย่อยของตัวเองไม่ได้
+func (pdb *PostgresDatabase) UpdateCategory(ctx context.Context, categoryID int, u UpdateCategory) (Category, error) {
+	if u.Name != nil {
+		if err := validateCategoryName(*u.Name); err != nil {
+			return Category{}, err
+		}
+		trimmed := strings.TrimSpace(*u.Name)
+		u.Name = &trimmed
+	}
+	if u.ClearAgeRange && (u.MinAgeMonths != nil || u.MaxAgeMonths != nil) {
+		return Category{}, fmt.Errorf("%w: age range and clear_age_range cannot be used together", ErrInvalidCategory)
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Category{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var category Category
+	var parentID sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT category_id, name, COALESCE(description, ''), parent_id, min_age_months, max_age_months
+		FROM categories
+		WHERE category_id = $1
+		FOR UPDATE`, categoryID).Scan(&category.ID, &category.Name, &category.Description, &parentID,
+		&category.MinAgeMonths, &category.MaxAgeMonths)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return Category{}, ErrCategoryNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return Category{}, fmt.Errorf("failed to get category: %v", err)
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		category.ParentID = &id
+	}
+
+	if u.Name != nil {
+		if err := checkCategoryName(ctx, tx, *u.Name, categoryID); err != nil {
+			tx.Rollback()
+			return Category{}, err
+		}
+		category.Name = *u.Name
+	}
+	if u.Description != nil {
+		category.Description = *u.Description
+	}
+	if u.ClearAgeRange {
+		category.MinAgeMonths, category.MaxAgeMonths = nil, nil
+	}
+	if u.MinAgeMonths != nil {
+		category.MinAgeMonths = u.MinAgeMonths
+	}
+	if u.MaxAgeMonths != nil {
+		category.MaxAgeMonths = u.MaxAgeMonths
+	}
+	if err := validateCategoryAgeRange(category.MinAgeMonths, category.MaxAgeMonths); err != nil {
+		tx.Rollback()
+		return Category{}, err
+	}
+
+	if u.ParentID != nil {
+		if *u.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			// ตรวจว่าแม่ใหม่มีอยู่และไม่ได้อยู่ในต้นไม้ย่อยของหมวดหมู่นี้ (กันวงวน)
+			var exists, inSubtree bool
+			err := tx.QueryRowContext(ctx, `
+				WITH RECURSIVE subtree AS (
+					SELECT category_id FROM categories WHERE category_id = $1
+					UNION
+					SELECT ch.category_id FROM categories ch JOIN subtree t ON ch.parent_id = t.category_id
+				)
+				SELECT EXISTS(SELECT 1 FROM categories WHERE category_id = $2),
+				       EXISTS(SELECT 1 FROM subtree WHERE category_id = $2)`,
+				categoryID, *u.ParentID).Scan(&exists, &inSubtree)
+			if err != nil {
+				tx.Rollback()
+				return Category{}, fmt.Errorf("failed to check parent category: %v", err)
+			}
+			if !exists {
+				tx.Rollback()
+				return Category{}, fmt.Errorf("%w: parent category %d does not exist", ErrInvalidCategory, *u.ParentID)
+			}
+			if inSubtree {
+				tx.Rollback()
+				return Category{}, fmt.Errorf("%w: a category cannot be moved under itself or its subcategories", ErrInvalidCategory)
+			}
+			category.ParentID = u.ParentID
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE categories SET name = $1, description = $2, parent_id = $3, min_age_months = $4, max_age_months = $5
+		WHERE category_id = $6`, category.Name, category.Description, category.ParentID,
+		category.MinAgeMonths, category.MaxAgeMonths, categoryID)
+	if err != nil {
+		tx.Rollback()
+		return Category{}, fmt.Errorf("failed to update category: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Category{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	pdb.refreshSearchIndexAfter(ctx, "category update")
+	return category, nil
+}
+
+// DeleteCategory ลบหมวดหมู่ที่ไม่มีหมวดหมู่ย่อยและไม่มีสินค้า (รวมสินค้าที่ถูกลบแบบ soft delete
+// เพราะ foreign key ของสินค้าจะลบตามหมวดหมู่) มิฉะนั้นคืน ErrCategoryInUse
+func (pdb *PostgresDatabase) DeleteCategory(ctx context.Context, categoryID int) error {
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var hasChildren, hasProducts bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = c.category_id),
+		       EXISTS(SELECT 1 FROM products WHERE category_id = c.category_id)
+		FROM categories c
+		WHERE c.category_id = $1
+		FOR UPDATE`, categoryID).Scan(&hasChildren, &hasProducts)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ErrCategoryNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check category: %v", err)
+	}
+
+	if hasChildren {
+		tx.Rollback()
+		return fmt.Errorf("%w: category has subcategories", ErrCategoryInUse)
+	}
+	if hasProducts {
+		tx.Rollback()
+		return fmt.Errorf("%w: category has products", ErrCategoryInUse)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE category_id = $1`, categoryID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete category: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,356 @@
+// child.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidChild ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลโปรไฟล์ลูกไม่ผ่านการตรวจสอบ
+var ErrInvalidChild = errors.New("invalid child profile")
+
+// ErrChildNotFound ถูกส่งคืนเมื่อไม่พบโปรไฟล์ลูก (หรือไม่ใช่ของผู้ใช้คนนั้น)
+var ErrChildNotFound = errors.New("child profile not found")
+
+// จำนวนโปรไฟล์ลูกสูงสุดต่อผู้ใช้
+const maxChildProfiles = 10
+
+// จำนวนความสนใจสูงสุดต่อโปรไฟล์
+const maxChildInterests = 10
+
+// รูปแบบของเดือนเกิดใน JSON
+const birthMonthLayout = "2006-01"
+
+// ChildProfile โปรไฟล์ลูกของผู้ใช้ เก็บแค่เดือนเกิด (ไม่เก็บวันเกิด)
+// AgeMonths คืออายุเป็นเดือนเต็ม ณ เดือนปัจจุบัน ซึ่งคำนวณใหม่ทุกครั้งที่อ่าน
+type ChildProfile struct {
+	ID         int       `json:"child_id"`
+	Name       string    `json:"name"`
+	BirthMonth string    `json:"birth_month"` // YYYY-MM
+	AgeMonths  int       `json:"age_months"`
+	Interests  []string  `json:"interests"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NewChildProfile ข้อมูลสำหรับเพิ่มโปรไฟล์ลูก BirthMonth อยู่ในรูป YYYY-MM
+type NewChildProfile struct {
+	Name       string   `json:"name"`
+	BirthMonth string   `json:"birth_month"`
+	Interests  []string `json:"interests"`
+}
+
+// UpdateChildProfile ข้อมูลสำหรับแก้โปรไฟล์ลูก ฟิลด์ที่เป็น nil จะไม่ถูกแก้
+type UpdateChildProfile struct {
+	Name       *string   `json:"name"`
+	BirthMonth *string   `json:"birth_month"`
+	Interests  *[]string `json:"interests"`
+}
+
+// ChildRecommendations สินค้าที่เหมาะกับอายุปัจจุบันของเด็ก
+// AgeBand คือหมวดหมู่ช่วงอายุที่เด็กอยู่ตอนนี้ ส่วน NextBand คือช่วงอายุถัดไปที่เด็กจะขยับไปเมื่อโตขึ้น
+type ChildRecommendations struct {
+	Child    ChildProfile `json:"child"`
+	AgeBand  *Category    `json:"age_band"`
+	NextBand *Category    `json:"next_band"`
+	ProductPage
+}
+
+// ageInMonths อายุเป็นเดือนเต็มของเด็กที่เกิดในเดือน birthMonth ณ เวลา now
+// (เดือนเกิดคืออายุ 0 เดือน) เด็กจึงขยับไปช่วงอายุถัดไปเองเมื่อขึ้นเดือนใหม่
+func ageInMonths(birthMonth, now time.Time) int {
+	return (now.Year()-birthMonth.Year())*12 + int(now.Month()) - int(birthMonth.Month())
+}
+
+// parseBirthMonth แปลงเดือนเกิด (YYYY-MM) เป็นวันที่ 1 ของเดือนนั้น
+// ต้องไม่ใช่เดือนในอนาคตและอายุไม่เกิน maxAgeMonths
+func parseBirthMonth(s string) (time.Time, error) {
+	birthMonth, err := time.Parse(birthMonthLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: birth_month must be in YYYY-MM format", ErrInvalidChild)
+	}
+	age := ageInMonths(birthMonth, time.Now())
+	if age < 0 {
+		return time.Time{}, fmt.Errorf("%w: birth_month must not be in the future", ErrInvalidChild)
+	}
+	if age > maxAgeMonths {
+		return time.Time{}, fmt.Errorf("%w: child must be at most %d months old", ErrInvalidChild, maxAgeMonths)
+	}
+	return birthMonth, nil
+}
+
+// Validate ตรวจสอบค่าของโปรไฟล์ใหม่
+func (c *NewChildProfile) Validate() error {
+	if c.Interests == nil {
+		c.Interests = []string{}
+	}
+	return (&UpdateChildProfile{Name: &c.Name, BirthMonth: &c.BirthMonth, Interests: &c.Interests}).Validate()
+}
+
+// Validate ตรวจสอบเฉพาะฟิลด์ที่ระบุมา และตัดช่องว่างของชื่อกับความสนใจ (ความสนใจที่ซ้ำกันถูกรวมเป็นอันเดียว)
+func (u *UpdateChildProfile) Validate() error {
+	if u.Name != nil {
+		name := strings.TrimSpace(*u.Name)
+		if name == "" || len([]rune(name)) > 100 {
+			return fmt.Errorf("%w: name is required and must be at most 100 characters", ErrInvalidChild)
+		}
+		u.Name = &name
+	}
+	if u.BirthMonth != nil {
+		if _, err := parseBirthMonth(*u.BirthMonth); err != nil {
+			return err
+		}
+	}
+	if u.Interests != nil {
+		if len(*u.Interests) > maxChildInterests {
+			return fmt.Errorf("%w: at most %d interests are allowed", ErrInvalidChild, maxChildInterests)
+		}
+		seen := make(map[string]bool)
+		interests := []string{}
+		for _, interest := range *u.Interests {
+			interest = strings.TrimSpace(interest)
+			if interest == "" || len([]rune(interest)) > 50 {
+				return fmt.Errorf("%w: interests must be non-empty and at most 50 characters", ErrInvalidChild)
+			}
+			if key := strings.ToLower(interest); !seen[key] {
+				seen[key] = true
+				interests = append(interests, interest)
+			}
+		}
+		u.Interests = &interests
+	}
+	return nil
+}
+
+// scanChildProfile อ่านโปรไฟล์ลูกหนึ่งแถวและคำนวณอายุปัจจุบัน
+func scanChildProfile(row interface{ Scan(...interface{}) error }) (ChildProfile, error) {
+	var child ChildProfile
+	var birthMonth time.Time
+	err := row.Scan(&child.ID, &child.Name, &birthMonth, pq.Array(&child.Interests), &child.CreatedAt, &child.UpdatedAt)
+	if err != nil {
+		return ChildProfile{}, err
+	}
+	child.BirthMonth = birthMonth.Format(birthMonthLayout)
+	child.AgeMonths = max(0, ageInMonths(birthMonth, time.Now()))
+	if child.Interests == nil {
+		child.Interests = []string{}
+	}
+	return child, nil
+}
+
+// GetChildProfiles แสดงโปรไฟล์ลูกทั้งหมดของผู้ใช้ เรียงตามลำดับที่เพิ่ม
+func (pdb *PostgresDatabase) GetChildProfiles(ctx context.Context, userID string) ([]ChildProfile, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT child_id, name, birth_month, interests, created_at, updated_at
+		FROM child_profiles
+		WHERE user_id = $1
+		ORDER BY child_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child profiles: %v", err)
+	}
+	defer rows.Close()
+
+	children := []ChildProfile{}
+	for rows.Next() {
+		child, err := scanChildProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan child profile: %v", err)
+		}
+		children = append(children, child)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate child profiles: %v", err)
+	}
+
+	return children, nil
+}
+
+// GetChildProfile แสดงโปรไฟล์ลูกหนึ่งคนของผู้ใช้
+func (pdb *PostgresDatabase) GetChildProfile(ctx context.Context, userID string, childID int) (ChildProfile, error) {
+	child, err := scanChildProfile(pdb.db.QueryRowContext(ctx, `
+		SELECT child_id, name, birth_month, interests, created_at, updated_at
+		FROM child_profiles
+		WHERE child_id = $1 AND user_id = $2`, childID, userID))
+	if err == sql.ErrNoRows {
+		return ChildProfile{}, ErrChildNotFound
+	} else if err != nil {
+		return ChildProfile{}, fmt.Errorf("failed to get child profile: %v", err)
+	}
+	return child, nil
+}
+
+// CreateChildProfile เพิ่มโปรไฟล์ลูกให้ผู้ใช้ (ไม่เกิน maxChildProfiles คน)
+func (pdb *PostgresDatabase) CreateChildProfile(ctx context.Context, userID string, c NewChildProfile) (ChildProfile, error) {
+	if err := c.Validate(); err != nil {
+		return ChildProfile{}, err
+	}
+	birthMonth, _ := parseBirthMonth(c.BirthMonth)
+	birthDate := birthMonth.Format("2006-01-02")
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ChildProfile{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	// ล็อกแถวผู้ใช้ไว้เพื่อให้การนับจำนวนโปรไฟล์ไม่ชนกับการเพิ่มพร้อมกัน
+	var count int
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM child_profiles WHERE user_id = u.user_id)
+		FROM users u
+		WHERE u.user_id = $1
+		FOR UPDATE`, userID).Scan(&count)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ChildProfile{}, fmt.Errorf("user not found")
+	} else if err != nil {
+		tx.Rollback()
+		return ChildProfile{}, fmt.Errorf("failed to count child profiles: %v", err)
+	}
+	if count >= maxChildProfiles {
+		tx.Rollback()
+		return ChildProfile{}, fmt.Errorf("%w: at most %d child profiles are allowed", ErrInvalidChild, maxChildProfiles)
+	}
+
+	child, err := scanChildProfile(tx.QueryRowContext(ctx, `
+		INSERT INTO child_profiles (user_id, name, birth_month, interests)
+		VALUES ($1, $2, $3, $4)
+		RETURNING child_id, name, birth_month, interests, created_at, updated_at`,
+		userID, c.Name, birthDate, pq.Array(c.Interests)))
+	if err != nil {
+		tx.Rollback()
+		return ChildProfile{}, fmt.Errorf("failed to create child profile: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ChildProfile{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return child, nil
+}
+
+// UpdateChildProfile แก้ชื่อ เดือนเกิด หรือความสนใจของโปรไฟล์ลูก
+func (pdb *PostgresDatabase) UpdateChildProfile(ctx context.Context, userID string, childID int, u UpdateChildProfile) (ChildProfile, error) {
+	if err := u.Validate(); err != nil {
+		return ChildProfile{}, err
+	}
+
+	var birthDate *string
+	if u.BirthMonth != nil {
+		parsed, _ := parseBirthMonth(*u.BirthMonth)
+		date := parsed.Format("2006-01-02")
+		birthDate = &date
+	}
+	var interests interface{}
+	if u.Interests != nil {
+		interests = pq.Array(*u.Interests)
+	}
+
+	child, err := scanChildProfile(pdb.db.QueryRowContext(ctx, `
+		UPDATE child_profiles SET
+			name = COALESCE($3, name),
+			birth_month = COALESCE($4, birth_month),
+			interests = COALESCE($5, interests)
+		WHERE child_id = $1 AND user_id = $2
+		RETURNING child_id, name, birth_month, interests, created_at, updated_at`,
+		childID, userID, u.Name, birthDate, interests))
+	if err == sql.ErrNoRows {
+		return ChildProfile{}, ErrChildNotFound
+	} else if err != nil {
+		return ChildProfile{}, fmt.Errorf("failed to update child profile: %v", err)
+	}
+
+	return child, nil
+}
+
+// DeleteChildProfile ลบโปรไฟล์ลูกของผู้ใช้
+func (pdb *PostgresDatabase) DeleteChildProfile(ctx context.Context, userID string, childID int) error {
+	result, err := pdb.db.ExecContext(ctx, `
+		DELETE FROM child_profiles WHERE child_id = $1 AND user_id = $2`, childID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete child profile: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check deleted child profile: %v", err)
+	} else if n == 0 {
+		return ErrChildNotFound
+	}
+	return nil
+}
+
+// getAgeBands หาหมวดหมู่ช่วงอายุที่ครอบคลุมอายุ ageMonths และช่วงอายุถัดไป (nil เมื่อไม่มี)
+// เลือกหมวดหมู่ระดับบนสุดก่อนหมวดหมู่ย่อย
+func (pdb *PostgresDatabase) getAgeBands(ctx context.Context, ageMonths int) (*Category, *Category, error) {
+	find := func(where, order string) (*Category, error) {
+		var category Category
+		var parentID sql.NullInt64
+		err := pdb.db.QueryRowContext(ctx, `
+			SELECT category_id, name, COALESCE(description, ''), parent_id, min_age_months, max_age_months
+			FROM categories
+			WHERE `+where+`
+			ORDER BY parent_id NULLS FIRST, `+order+`, category_id
+			LIMIT 1`, ageMonths).Scan(&category.ID, &category.Name, &category.Description, &parentID,
+			&category.MinAgeMonths, &category.MaxAgeMonths)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get age band: %v", err)
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			category.ParentID = &id
+		}
+		return &category, nil
+	}
+
+	current, err := find("min_age_months <= $1 AND COALESCE(max_age_months, $1) >= $1", "min_age_months DESC")
+	if err != nil {
+		return nil, nil, err
+	}
+	next, err := find("min_age_months > $1", "min_age_months")
+	if err != nil {
+		return nil, nil, err
+	}
+	return current, next, nil
+}
+
+// RecommendForChild แนะนำสินค้าที่เหมาะกับอายุปัจจุบันของเด็ก (คำนวณจากเดือนเกิดทุกครั้ง
+// เด็กจึงขยับไปช่วงอายุถัดไปเองเมื่อโตขึ้น) โดยใช้ตัวกรองอายุเดียวกับ ListProducts
+// ถ้าไม่ระบุ sort สินค้าที่ตรงกับความสนใจของเด็กจะขึ้นก่อน
+func (pdb *PostgresDatabase) RecommendForChild(ctx context.Context, userID string, childID int, q ListingQuery) (ChildRecommendations, error) {
+	child, err := pdb.GetChildProfile(ctx, userID, childID)
+	if err != nil {
+		return ChildRecommendations{}, err
+	}
+
+	age := child.AgeMonths
+	q.AgeMonths = &age
+
+	q.preferredIDs = []int64{}
+	if len(child.Interests) > 0 && pdb.searchIndex.Ready() {
+		for _, hit := range pdb.searchIndex.Search(strings.Join(child.Interests, " "), maxSearchHits) {
+			q.preferredIDs = append(q.preferredIDs, int64(hit.ProductID))
+		}
+	}
+	if q.Sort == "" {
+		q.Sort = sortInterests
+	}
+
+	page, err := pdb.ListProducts(ctx, q)
+	if err != nil {
+		return ChildRecommendations{}, err
+	}
+
+	current, next, err := pdb.getAgeBands(ctx, age)
+	if err != nil {
+		return ChildRecommendations{}, err
+	}
+
+	return ChildRecommendations{Child: child, AgeBand: current, NextBand: next, ProductPage: page}, nil
+}
@@ -0,0 +1,156 @@
+// facets.go
+package product
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ขอบของช่วงราคาใน facet ราคา (บาท) ช่วงสุดท้ายไม่มีขอบบน
+var priceBucketEdges = []float64{200, 500, 1000, 2000}
+
+// Facets จำนวนสินค้าที่ตรงกับการค้นหาแยกตามหมวดหมู่ แบรนด์ ร้าน ช่วงราคา และสถานะสต็อก
+// นับจากสินค้าทั้งหมดที่ตรงเงื่อนไข (ไม่ขึ้นกับ cursor) ใช้แสดงตัวกรองด้านข้างของหน้าค้นหา
+type Facets struct {
+	Categories   []CategoryFacet `json:"categories"`
+	Brands       []BrandFacet    `json:"brands"`
+	Sellers      []SellerFacet   `json:"sellers"`
+	PriceBuckets []PriceBucket   `json:"price_buckets"`
+	Stock        StockFacet      `json:"stock"`
+}
+
+type CategoryFacet struct {
+	CategoryID int    `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+type BrandFacet struct {
+	Brand string `json:"brand"`
+	Count int    `json:"count"`
+}
+
+type SellerFacet struct {
+	SellerID int    `json:"seller_id"`
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+}
+
+// PriceBucket ช่วงราคา [Min, Max) Max เป็น nil คือไม่มีขอบบน
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+type StockFacet struct {
+	InStock    int `json:"in_stock"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+// newFacets คืน Facets ว่าง ที่ทุกช่วงราคามีจำนวนเป็น 0
+func newFacets() *Facets {
+	facets := &Facets{
+		Categories: []CategoryFacet{},
+		Brands:     []BrandFacet{},
+		Sellers:    []SellerFacet{},
+	}
+	min := 0.0
+	for i := 0; i <= len(priceBucketEdges); i++ {
+		bucket := PriceBucket{Min: min}
+		if i < len(priceBucketEdges) {
+			max := priceBucketEdges[i]
+			bucket.Max = &max
+			min = max
+		}
+		facets.PriceBuckets = append(facets.PriceBuckets, bucket)
+	}
+	return facets
+}
+
+// listFacets นับ facet ของสินค้าที่ตรงกับตัวกรอง f ด้วย query เดียว
+func (pdb *PostgresDatabase) listFacets(ctx context.Context, from string, f *listingFilter) (*Facets, error) {
+	edges := ""
+	for i, edge := range priceBucketEdges {
+		if i > 0 {
+			edges += ","
+		}
+		edges += strconv.FormatFloat(edge, 'f', -1, 64)
+	}
+
+	rows, err := pdb.db.QueryContext(ctx, `
+		WITH matches AS (
+			SELECT p.category_id, COALESCE(c.name, '') AS category_name,
+			       COALESCE(p.brand, '') AS brand,
+			       p.seller_id, COALESCE(s.name, '') AS seller_name,
+			       width_bucket(p.price, ARRAY[`+edges+`]::numeric[]) AS price_bucket,
+			       COALESCE(i.quantity, 0) > 0 AS in_stock`+from+`
+			WHERE `+f.where()+`
+		)
+		SELECT 'category', category_id::text, category_name, COUNT(*) FROM matches GROUP BY category_id, category_name
+		UNION ALL
+		SELECT 'brand', brand, brand, COUNT(*) FROM matches WHERE brand <> '' GROUP BY brand
+		UNION ALL
+		SELECT 'seller', seller_id::text, seller_name, COUNT(*) FROM matches GROUP BY seller_id, seller_name
+		UNION ALL
+		SELECT 'price', price_bucket::text, '', COUNT(*) FROM matches GROUP BY price_bucket
+		UNION ALL
+		SELECT 'stock', in_stock::text, '', COUNT(*) FROM matches GROUP BY in_stock
+	`, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query facets: %v", err)
+	}
+	defer rows.Close()
+
+	facets := newFacets()
+	for rows.Next() {
+		var facet, key, label string
+		var count int
+		if err := rows.Scan(&facet, &key, &label, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan facet row: %v", err)
+		}
+
+		switch facet {
+		case "category":
+			id, _ := strconv.Atoi(key)
+			facets.Categories = append(facets.Categories, CategoryFacet{CategoryID: id, Name: label, Count: count})
+		case "brand":
+			facets.Brands = append(facets.Brands, BrandFacet{Brand: label, Count: count})
+		case "seller":
+			id, _ := strconv.Atoi(key)
+			facets.Sellers = append(facets.Sellers, SellerFacet{SellerID: id, Name: label, Count: count})
+		case "price":
+			// width_bucket คืน 0 สำหรับราคาที่ต่ำกว่าขอบแรก จึงตรงกับ index ของช่วงพอดี
+			if bucket, err := strconv.Atoi(key); err == nil && bucket >= 0 && bucket < len(facets.PriceBuckets) {
+				facets.PriceBuckets[bucket].Count = count
+			}
+		case "stock":
+			if key == "true" {
+				facets.Stock.InStock = count
+			} else {
+				facets.Stock.OutOfStock = count
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate facet rows: %v", err)
+	}
+
+	sort.Slice(facets.Categories, func(i, j int) bool {
+		a, b := facets.Categories[i], facets.Categories[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+	sort.Slice(facets.Brands, func(i, j int) bool {
+		a, b := facets.Brands[i], facets.Brands[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Brand < b.Brand)
+	})
+	sort.Slice(facets.Sellers, func(i, j int) bool {
+		a, b := facets.Sellers[i], facets.Sellers[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+
+	return facets, nil
+}
@@ -0,0 +1,457 @@
+// images.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidImage ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลรูปของสินค้าไม่ผ่านการตรวจสอบ
+var ErrInvalidImage = errors.New("invalid product image")
+
+// ErrImageNotFound ถูกส่งคืนเมื่อไม่พบรูป (หรือรูปไม่ใช่ของสินค้านั้น)
+var ErrImageNotFound = errors.New("product image not found")
+
+// จำนวนรูปสูงสุดในแกลเลอรีของสินค้าหนึ่งชิ้น
+const maxProductImages = 20
+
+// ProductImage รูปหนึ่งรูปในแกลเลอรีของสินค้า เรียงตาม Position (0 คือรูปปก)
+// รูปที่อัปโหลดมี thumbnail หลายขนาด ส่วนรูปที่เป็นลิงก์ภายนอก (image_url เดิม) ไม่มี
+type ProductImage struct {
+	ID          int               `json:"image_id"`
+	ProductID   int               `json:"product_id"`
+	URL         string            `json:"url"`
+	AltText     string            `json:"alt_text"`
+	Position    int               `json:"position"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+	ContentType string            `json:"content_type,omitempty"`
+	Width       int               `json:"width,omitempty"`
+	Height      int               `json:"height,omitempty"`
+	StorageKeys []string          `json:"-"` // ไฟล์ใน storage ที่ต้องลบตามเมื่อรูปถูกลบ
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// NewProductImage ข้อมูลรูปที่เก็บลง storage แล้ว (หรือลิงก์ภายนอก) สำหรับเพิ่มเข้าแกลเลอรี
+// Position เป็น nil คือต่อท้าย
+type NewProductImage struct {
+	URL         string
+	AltText     string
+	Position    *int
+	Thumbnails  map[string]string
+	StorageKeys []string
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// UpdateProductImage ข้อมูลสำหรับแก้รูป ฟิลด์ที่เป็น nil จะไม่ถูกแก้
+// การแก้ Position จะย้ายรูปไปตำแหน่งนั้นและเลื่อนรูปอื่นตาม
+type UpdateProductImage struct {
+	AltText  *string `json:"alt_text"`
+	Position *int    `json:"position"`
+}
+
+// Validate ตรวจสอบค่าของรูปใหม่
+func (img *NewProductImage) Validate() error {
+	if img.URL == "" || len(img.URL) > 255 {
+		return fmt.Errorf("%w: url is required and must be at most 255 characters", ErrInvalidImage)
+	}
+	if img.Thumbnails == nil {
+		img.Thumbnails = map[string]string{}
+	}
+	return UpdateProductImage{AltText: &img.AltText, Position: img.Position}.Validate()
+}
+
+// Validate ตรวจสอบเฉพาะฟิลด์ที่ระบุมา
+func (u UpdateProductImage) Validate() error {
+	if u.AltText != nil && len(*u.AltText) > 255 {
+		return fmt.Errorf("%w: alt_text must be at most 255 characters", ErrInvalidImage)
+	}
+	if u.Position != nil && *u.Position < 0 {
+		return fmt.Errorf("%w: position must not be negative", ErrInvalidImage)
+	}
+	return nil
+}
+
+// queryProductImages ดึงรูปในแกลเลอรีของสินค้าเรียงตามตำแหน่ง (imageID เป็น 0 คือทุกรูป)
+func (pdb *PostgresDatabase) queryProductImages(ctx context.Context, productID, imageID int) ([]ProductImage, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT image_id, product_id, url, alt_text, position, thumbnails, storage_keys,
+		       COALESCE(content_type, ''), COALESCE(width, 0), COALESCE(height, 0), created_at, updated_at
+		FROM product_images
+		WHERE product_id = $1 AND ($2 = 0 OR image_id = $2)
+		ORDER BY position, image_id
+	`, productID, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product images: %v", err)
+	}
+	defer rows.Close()
+
+	images := []ProductImage{}
+	for rows.Next() {
+		var img ProductImage
+		var thumbnails []byte
+		if err := rows.Scan(
+			&img.ID, &img.ProductID, &img.URL, &img.AltText, &img.Position, &thumbnails, pq.Array(&img.StorageKeys),
+			&img.ContentType, &img.Width, &img.Height, &img.CreatedAt, &img.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan product image: %v", err)
+		}
+		if err := json.Unmarshal(thumbnails, &img.Thumbnails); err != nil {
+			return nil, fmt.Errorf("failed to decode image thumbnails: %v", err)
+		}
+		images = append(images, img)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product images: %v", err)
+	}
+
+	return images, nil
+}
+
+// GetProductImages คืนแกลเลอรีรูปของสินค้าที่ยังขายอยู่ เรียงตามตำแหน่ง
+func (pdb *PostgresDatabase) GetProductImages(ctx context.Context, productID int) ([]ProductImage, error) {
+	var exists bool
+	err := pdb.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM products WHERE product_id = $1 AND deleted_at IS NULL)`, productID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check product: %v", err)
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+	return pdb.queryProductImages(ctx, productID, 0)
+}
+
+// getProductImage คืนรูปหนึ่งรูปของสินค้า
+func (pdb *PostgresDatabase) getProductImage(ctx context.Context, productID, imageID int) (ProductImage, error) {
+	images, err := pdb.queryProductImages(ctx, productID, imageID)
+	if err != nil {
+		return ProductImage{}, err
+	}
+	if len(images) == 0 {
+		return ProductImage{}, ErrImageNotFound
+	}
+	return images[0], nil
+}
+
+// lockGallery ล็อกแถวของสินค้าไว้จนจบ transaction เพื่อไม่ให้การแก้แกลเลอรีพร้อมกันทำลำดับรูปเพี้ยน
+// แล้วคืน image_id ทั้งหมดเรียงตามตำแหน่งปัจจุบัน
+func lockGallery(ctx context.Context, tx *sql.Tx, productID int) ([]int, error) {
+	var locked int
+	err := tx.QueryRowContext(ctx, `
+		SELECT product_id FROM products
+		WHERE product_id = $1 AND deleted_at IS NULL
+		FOR UPDATE`, productID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to lock product: %v", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT image_id FROM product_images
+		WHERE product_id = $1
+		ORDER BY position, image_id`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product images: %v", err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan product image: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product images: %v", err)
+	}
+	return ids, nil
+}
+
+// moveImage ย้าย imageID ใน ids ไปไว้ที่ position (ตำแหน่งที่เกินท้ายแกลเลอรีถือเป็นท้ายสุด)
+func moveImage(ids []int, imageID, position int) []int {
+	moved := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id != imageID {
+			moved = append(moved, id)
+		}
+	}
+	if position > len(moved) {
+		position = len(moved)
+	}
+	moved = append(moved[:position], append([]int{imageID}, moved[position:]...)...)
+	return moved
+}
+
+// saveGalleryOrder บันทึกตำแหน่งของรูปตามลำดับใน ids (0, 1, 2, ...)
+// แล้วคัดลอก URL ของรูปแรกไปที่ products.image_url ซึ่งหน้ารายการสินค้าใช้เป็นรูปปก
+func saveGalleryOrder(ctx context.Context, tx *sql.Tx, productID int, ids []int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE product_images pi
+		SET position = o.ord - 1
+		FROM unnest($2::int[]) WITH ORDINALITY AS o(image_id, ord)
+		WHERE pi.image_id = o.image_id AND pi.product_id = $1 AND pi.position <> o.ord - 1`,
+		productID, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to reorder product images: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE products p
+		SET image_url = cover.url
+		FROM (SELECT COALESCE((SELECT url FROM product_images WHERE product_id = $1
+		                       ORDER BY position, image_id LIMIT 1), '') AS url) cover
+		WHERE p.product_id = $1 AND p.image_url IS DISTINCT FROM cover.url`, productID)
+	if err != nil {
+		return fmt.Errorf("failed to update product cover image: %v", err)
+	}
+	return nil
+}
+
+// AddProductImage เพิ่มรูปเข้าแกลเลอรีของสินค้า ไม่เกิน maxProductImages รูป
+func (pdb *PostgresDatabase) AddProductImage(ctx context.Context, productID int, img NewProductImage) (ProductImage, error) {
+	if err := img.Validate(); err != nil {
+		return ProductImage{}, err
+	}
+
+	thumbnails, err := json.Marshal(img.Thumbnails)
+	if err != nil {
+		return ProductImage{}, fmt.Errorf("failed to encode image thumbnails: %v", err)
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductImage{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	ids, err := lockGallery(ctx, tx, productID)
+	if err != nil {
+		tx.Rollback()
+		return ProductImage{}, err
+	}
+	if len(ids) >= maxProductImages {
+		tx.Rollback()
+		return ProductImage{}, fmt.Errorf("%w: a product can have at most %d images", ErrInvalidImage, maxProductImages)
+	}
+
+	var imageID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO product_images (product_id, url, alt_text, position, thumbnails, storage_keys,
+		                            content_type, width, height)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6, NULLIF($7, ''), NULLIF($8, 0), NULLIF($9, 0))
+		RETURNING image_id`,
+		productID, img.URL, img.AltText, len(ids), string(thumbnails), pq.Array(img.StorageKeys),
+		img.ContentType, img.Width, img.Height,
+	).Scan(&imageID)
+	if err != nil {
+		tx.Rollback()
+		return ProductImage{}, fmt.Errorf("failed to add product image: %v", err)
+	}
+
+	position := len(ids)
+	if img.Position != nil {
+		position = *img.Position
+	}
+	if err := saveGalleryOrder(ctx, tx, productID, moveImage(append(ids, imageID), imageID, position)); err != nil {
+		tx.Rollback()
+		return ProductImage{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ProductImage{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.getProductImage(ctx, productID, imageID)
+}
+
+// UpdateProductImage แก้ alt text หรือย้ายตำแหน่งของรูป
+func (pdb *PostgresDatabase) UpdateProductImage(ctx context.Context, productID, imageID int, u UpdateProductImage) (ProductImage, error) {
+	if err := u.Validate(); err != nil {
+		return ProductImage{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductImage{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	ids, err := lockGallery(ctx, tx, productID)
+	if err != nil {
+		tx.Rollback()
+		return ProductImage{}, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE product_images SET alt_text = COALESCE($3, alt_text)
+		WHERE image_id = $1 AND product_id = $2`, imageID, productID, u.AltText)
+	if err != nil {
+		tx.Rollback()
+		return ProductImage{}, fmt.Errorf("failed to update product image: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return ProductImage{}, fmt.Errorf("failed to check updated product image: %v", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return ProductImage{}, ErrImageNotFound
+	}
+
+	if u.Position != nil {
+		if err := saveGalleryOrder(ctx, tx, productID, moveImage(ids, imageID, *u.Position)); err != nil {
+			tx.Rollback()
+			return ProductImage{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ProductImage{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.getProductImage(ctx, productID, imageID)
+}
+
+// ReorderProductImages จัดลำดับแกลเลอรีใหม่ทั้งหมด imageIDs ต้องมีรูปทุกรูปของสินค้าครบ ไม่ซ้ำกัน
+func (pdb *PostgresDatabase) ReorderProductImages(ctx context.Context, productID int, imageIDs []int) ([]ProductImage, error) {
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	ids, err := lockGallery(ctx, tx, productID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	current := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		current[id] = true
+	}
+	if len(imageIDs) != len(ids) {
+		tx.Rollback()
+		return nil, fmt.Errorf("%w: image_ids must list all %d images of the product", ErrInvalidImage, len(ids))
+	}
+	for _, id := range imageIDs {
+		if !current[id] {
+			tx.Rollback()
+			return nil, fmt.Errorf("%w: image %d is not in the gallery or is listed twice", ErrInvalidImage, id)
+		}
+		delete(current, id)
+	}
+
+	if err := saveGalleryOrder(ctx, tx, productID, imageIDs); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.queryProductImages(ctx, productID, 0)
+}
+
+// DeleteProductImage ลบรูปออกจากแกลเลอรีและคืนรูปที่ถูกลบ เพื่อให้ผู้เรียกลบไฟล์ใน StorageKeys ต่อ
+func (pdb *PostgresDatabase) DeleteProductImage(ctx context.Context, productID, imageID int) (ProductImage, error) {
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductImage{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	ids, err := lockGallery(ctx, tx, productID)
+	if err != nil {
+		tx.Rollback()
+		return ProductImage{}, err
+	}
+
+	img := ProductImage{ID: imageID, ProductID: productID}
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM product_images
+		WHERE image_id = $1 AND product_id = $2
+		RETURNING url, storage_keys`, imageID, productID).Scan(&img.URL, pq.Array(&img.StorageKeys))
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ProductImage{}, ErrImageNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return ProductImage{}, fmt.Errorf("failed to delete product image: %v", err)
+	}
+
+	remaining := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id != imageID {
+			remaining = append(remaining, id)
+		}
+	}
+	if err := saveGalleryOrder(ctx, tx, productID, remaining); err != nil {
+		tx.Rollback()
+		return ProductImage{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ProductImage{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return img, nil
+}
+
+// setExternalCover ใช้ image_url ที่ส่งมากับการสร้างหรือแก้สินค้าเป็นรูปปก (ลิงก์ภายนอก)
+// หากรูปปกเดิมเป็นลิงก์ภายนอกจะแทนที่ URL (หรือลบออกเมื่อ url ว่าง) มิฉะนั้นจะแทรกเป็นรูปแรก
+func setExternalCover(ctx context.Context, tx *sql.Tx, productID int, url string) error {
+	ids, err := lockGallery(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	var coverID int
+	var external bool
+	if len(ids) > 0 {
+		coverID = ids[0]
+		err := tx.QueryRowContext(ctx, `
+			SELECT cardinality(storage_keys) = 0 FROM product_images WHERE image_id = $1`, coverID).Scan(&external)
+		if err != nil {
+			return fmt.Errorf("failed to get cover image: %v", err)
+		}
+	}
+
+	switch {
+	case external && url == "":
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_images WHERE image_id = $1`, coverID); err != nil {
+			return fmt.Errorf("failed to delete cover image: %v", err)
+		}
+		ids = ids[1:]
+	case external:
+		if _, err := tx.ExecContext(ctx, `UPDATE product_images SET url = $2 WHERE image_id = $1`, coverID, url); err != nil {
+			return fmt.Errorf("failed to update cover image: %v", err)
+		}
+	case url != "":
+		if len(ids) >= maxProductImages {
+			return fmt.Errorf("%w: a product can have at most %d images", ErrInvalidImage, maxProductImages)
+		}
+		var imageID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO product_images (product_id, url) VALUES ($1, $2)
+			RETURNING image_id`, productID, url).Scan(&imageID)
+		if err != nil {
+			return fmt.Errorf("failed to add cover image: %v", err)
+		}
+		ids = append([]int{imageID}, ids...)
+	}
+
+	return saveGalleryOrder(ctx, tx, productID, ids)
+}
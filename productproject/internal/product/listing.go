@@ -0,0 +1,406 @@
+// listing.go
+package product
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultListingLimit จำนวนสินค้าต่อหน้าเมื่อไม่ได้ระบุ limit
+	DefaultListingLimit = 20
+	// MaxListingLimit จำนวนสินค้าสูงสุดต่อหน้า
+	MaxListingLimit = 100
+)
+
+// ErrInvalidListing ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อพารามิเตอร์การแสดงรายการสินค้าไม่ถูกต้อง
+var ErrInvalidListing = errors.New("invalid listing query")
+
+// ListingQuery เงื่อนไขการแสดงรายการสินค้า ใช้ร่วมกันทุก endpoint ที่แสดงรายการสินค้า
+type ListingQuery struct {
+	Limit  int    // จำนวนต่อหน้า (0 คือใช้ DefaultListingLimit)
+	Cursor string // next_cursor จากหน้าก่อนหน้า
+	Sort   string // newest, price_asc, price_desc, discount, name (ว่างคือเรียงตามรหัสสินค้า)
+
+	MinPrice    *float64
+	MaxPrice    *float64
+	Brand       string
+	SellerID    int
+	InStockOnly bool
+	Recommended bool
+
+	AgeMonths *int   // เหมาะกับเด็กอายุเท่านี้ (เดือน) สินค้าที่ไม่ได้ระบุช่วงอายุใช้ช่วงอายุของหมวดหมู่
+	Battery   string // ชนิดถ่าน หรือ none สำหรับสินค้าที่ไม่ต้องใช้ถ่าน
+	Material  string // วัสดุ (ค้นแบบบางส่วน ไม่สนตัวพิมพ์)
+
+	CategoryID int    // รวมสินค้าในหมวดหมู่ย่อยทุกระดับ
+	Search     string // ค้นหาจากชื่อสินค้า (ILIKE)
+
+	rankedIDs    []int64 // ผลจาก search index เรียงตามความเกี่ยวข้อง (ใช้กับ sort=relevance)
+	preferredIDs []int64 // สินค้าที่ตรงความสนใจเรียงตามความเกี่ยวข้อง ขึ้นก่อนสินค้าอื่น (ใช้กับ sort=interests)
+	withFacets   bool    // นับ facet ของสินค้าที่ตรงเงื่อนไขด้วย
+}
+
+// ProductPage สินค้าหนึ่งหน้า NextCursor ว่างเมื่อเป็นหน้าสุดท้าย
+// Total คือจำนวนสินค้าทั้งหมดที่ตรงเงื่อนไข (ไม่ขึ้นกับ cursor)
+// Facets มีเฉพาะผลค้นหา
+type ProductPage struct {
+	Items      []ProductItem `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	Total      int           `json:"total"`
+	Facets     *Facets       `json:"facets,omitempty"`
+}
+
+// listingSort คอลัมน์ที่ใช้เรียง ใช้ product_id เป็นตัวตัดสินเมื่อค่าเท่ากันเสมอ
+// เพื่อให้ cursor ชี้ตำแหน่งได้แน่นอน
+type listingSort struct {
+	column string // ว่างคือเรียงตาม product_id อย่างเดียว
+	cast   string // ชนิดข้อมูลของค่าใน cursor
+	desc   bool
+}
+
+// sortRelevance เรียงตามลำดับผลค้นหา ใช้ได้เฉพาะ SearchProducts
+const sortRelevance = "relevance"
+
+// sortInterests เรียงสินค้าที่ตรงความสนใจของเด็กขึ้นก่อน ใช้ได้เฉพาะ RecommendForChild
+const sortInterests = "interests"
+
+// unrankedPosition ตำแหน่งของสินค้าที่ไม่อยู่ใน preferredIDs (ต่อท้ายสินค้าที่ตรงความสนใจ)
+const unrankedPosition = 2147483647
+
+var listingSorts = map[string]listingSort{
+	"":           {},
+	"newest":     {column: "p.created_at", cast: "timestamptz", desc: true},
+	"price_asc":  {column: "p.price", cast: "numeric"},
+	"price_desc": {column: "p.price", cast: "numeric", desc: true},
+	"discount":   {column: "p.discount", cast: "int", desc: true},
+	"name":       {column: "p.name", cast: "text"},
+}
+
+// listingCursor ตำแหน่งของสินค้าตัวสุดท้ายในหน้าก่อนหน้า
+type listingCursor struct {
+	Sort string `json:"s,omitempty"` // cursor ใช้ได้กับการเรียงแบบเดิมเท่านั้น
+	Key  string `json:"k,omitempty"`
+	ID   int    `json:"id"`
+}
+
+func encodeListingCursor(cur listingCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeListingCursor ถอด cursor ของการเรียงแบบ sortName ค่าที่ใช้เรียงต้องเป็นชนิดเดียวกับคอลัมน์
+// เพื่อให้ cursor ที่ถูกแก้ได้ ErrInvalidListing แทนที่จะไปผิดพลาดในฐานข้อมูล
+func decodeListingCursor(s, sortName string, sort listingSort) (listingCursor, error) {
+	var cur listingCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &cur) != nil || cur.ID <= 0 {
+		return listingCursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidListing)
+	}
+	if cur.Sort != sortName {
+		return listingCursor{}, fmt.Errorf("%w: cursor does not match sort", ErrInvalidListing)
+	}
+	if sort.column == "" {
+		return cur, nil
+	}
+
+	switch sort.cast {
+	case "timestamptz":
+		_, err = time.Parse(time.RFC3339Nano, cur.Key)
+	case "numeric":
+		_, err = strconv.ParseFloat(cur.Key, 64)
+	case "int":
+		_, err = strconv.Atoi(cur.Key)
+	}
+	if err != nil {
+		return listingCursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidListing)
+	}
+	return cur, nil
+}
+
+// sortKey ค่าของคอลัมน์ที่ใช้เรียงสำหรับสร้าง cursor
+func (s listingSort) sortKey(p ProductItem) string {
+	switch s.column {
+	case "p.created_at":
+		return p.CreatedAt.Format(time.RFC3339Nano)
+	case "p.price":
+		return strconv.FormatFloat(p.Price, 'f', -1, 64)
+	case "p.discount":
+		return strconv.Itoa(p.Discount)
+	case "p.name":
+		return p.Name
+	}
+	return ""
+}
+
+// listingFilter สร้างเงื่อนไข WHERE และ argument ตาม ListingQuery
+// (สินค้าที่ถูกลบและสินค้าของร้านที่ปิดแล้วจะไม่ถูกแสดงเสมอ)
+type listingFilter struct {
+	conds     []string
+	args      []interface{}
+	ranked    string // placeholder ของ rankedIDs สำหรับเรียงตามความเกี่ยวข้อง
+	preferred string // placeholder ของ preferredIDs สำหรับเรียงตามความสนใจ
+}
+
+func (f *listingFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func (f *listingFilter) where() string {
+	return strings.Join(f.conds, " AND ")
+}
+
+func newListingFilter(q ListingQuery) *listingFilter {
+	f := &listingFilter{conds: []string{"p.deleted_at IS NULL", "s.deactivated_at IS NULL", "NOT " + productRecalled}}
+
+	if q.CategoryID > 0 {
+		f.conds = append(f.conds, `p.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT category_id FROM categories WHERE category_id = `+f.arg(q.CategoryID)+`
+				UNION
+				SELECT ch.category_id FROM categories ch JOIN subtree t ON ch.parent_id = t.category_id
+			)
+			SELECT category_id FROM subtree)`)
+	}
+	if q.rankedIDs != nil {
+		f.ranked = f.arg(pq.Array(q.rankedIDs)) + "::int[]"
+		f.conds = append(f.conds, "p.product_id = ANY("+f.ranked+")")
+	}
+	if q.preferredIDs != nil {
+		f.preferred = f.arg(pq.Array(q.preferredIDs)) + "::int[]"
+	}
+	if q.Search != "" {
+		f.conds = append(f.conds, "p.name ILIKE "+f.arg("%"+q.Search+"%"))
+	}
+	if q.MinPrice != nil {
+		f.conds = append(f.conds, "p.price >= "+f.arg(*q.MinPrice))
+	}
+	if q.MaxPrice != nil {
+		f.conds = append(f.conds, "p.price <= "+f.arg(*q.MaxPrice))
+	}
+	if q.Brand != "" {
+		f.conds = append(f.conds, "LOWER(p.brand) = LOWER("+f.arg(q.Brand)+")")
+	}
+	if q.SellerID > 0 {
+		f.conds = append(f.conds, "p.seller_id = "+f.arg(q.SellerID))
+	}
+	if q.InStockOnly {
+		f.conds = append(f.conds, "i.quantity > 0")
+	}
+	if q.Recommended {
+		f.conds = append(f.conds, "p.product_recommend = 'recommend'")
+	}
+	if q.AgeMonths != nil {
+		age := f.arg(*q.AgeMonths)
+		f.conds = append(f.conds, `CASE WHEN p.min_age_months IS NULL AND p.max_age_months IS NULL
+			THEN p.category_id IN (
+				SELECT category_id FROM category_age_bands
+				WHERE min_age_months <= `+age+` AND COALESCE(max_age_months, `+age+`) >= `+age+`)
+			ELSE COALESCE(p.min_age_months, 0) <= `+age+` AND COALESCE(p.max_age_months, `+age+`) >= `+age+`
+			END`)
+	}
+	if q.Battery != "" {
+		f.conds = append(f.conds, "p.battery_type = "+f.arg(q.Battery))
+	}
+	if q.Material != "" {
+		f.conds = append(f.conds, "p.material ILIKE "+f.arg("%"+q.Material+"%"))
+	}
+
+	return f
+}
+
+// validate ตรวจสอบและเติมค่าเริ่มต้นให้ ListingQuery
+func (q *ListingQuery) validate() (listingSort, error) {
+	sort, ok := listingSorts[q.Sort]
+	if q.Sort == sortRelevance {
+		if q.rankedIDs == nil {
+			return listingSort{}, fmt.Errorf("%w: sort relevance is only available when searching", ErrInvalidListing)
+		}
+		ok = true
+	}
+	if q.Sort == sortInterests {
+		if q.preferredIDs == nil {
+			return listingSort{}, fmt.Errorf("%w: sort interests is only available for child recommendations", ErrInvalidListing)
+		}
+		ok = true
+	}
+	if !ok {
+		return listingSort{}, fmt.Errorf("%w: sort must be one of relevance, newest, price_asc, price_desc, discount, name", ErrInvalidListing)
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultListingLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxListingLimit {
+		return listingSort{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListing, MaxListingLimit)
+	}
+	if (q.MinPrice != nil && *q.MinPrice < 0) || (q.MaxPrice != nil && *q.MaxPrice < 0) {
+		return listingSort{}, fmt.Errorf("%w: price range must not be negative", ErrInvalidListing)
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return listingSort{}, fmt.Errorf("%w: min_price must not be greater than max_price", ErrInvalidListing)
+	}
+	if q.AgeMonths != nil && (*q.AgeMonths < 0 || *q.AgeMonths > maxAgeMonths) {
+		return listingSort{}, fmt.Errorf("%w: age_months must be between 0 and %d", ErrInvalidListing, maxAgeMonths)
+	}
+	if q.Battery != "" {
+		battery, ok := normalizeBatteryType(q.Battery)
+		if !ok {
+			return listingSort{}, fmt.Errorf("%w: unknown battery type %q", ErrInvalidListing, q.Battery)
+		}
+		q.Battery = battery
+	}
+	return sort, nil
+}
+
+// ListProducts แสดงรายการสินค้าตามเงื่อนไข แบ่งหน้าด้วย cursor (keyset pagination)
+// เพื่อให้หน้าถัดไปไม่ซ้ำหรือข้ามสินค้าแม้จะมีสินค้าใหม่เพิ่มเข้ามาระหว่างเลื่อนดู
+func (pdb *PostgresDatabase) ListProducts(ctx context.Context, q ListingQuery) (ProductPage, error) {
+	sort, err := q.validate()
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	f := newListingFilter(q)
+	if q.Sort == sortRelevance {
+		sort = listingSort{column: "array_position(" + f.ranked + ", p.product_id)", cast: "int"}
+	}
+	if q.Sort == sortInterests {
+		sort = listingSort{column: fmt.Sprintf("COALESCE(array_position(%s, p.product_id), %d)", f.preferred, unrankedPosition), cast: "int"}
+	}
+	from := `
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.category_id
+		LEFT JOIN sellers s ON p.seller_id = s.seller_id
+		LEFT JOIN product_inventory i ON p.product_id = i.product_id`
+
+	page := ProductPage{Items: []ProductItem{}}
+	err = pdb.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from+` WHERE `+f.where(), f.args...).Scan(&page.Total)
+	if err != nil {
+		return ProductPage{}, fmt.Errorf("failed to count products: %v", err)
+	}
+
+	if q.withFacets {
+		if page.Facets, err = pdb.listFacets(ctx, from, f); err != nil {
+			return ProductPage{}, err
+		}
+	}
+
+	op, dir := ">", "ASC"
+	if sort.desc {
+		op, dir = "<", "DESC"
+	}
+
+	if q.Cursor != "" {
+		cur, err := decodeListingCursor(q.Cursor, q.Sort, sort)
+		if err != nil {
+			return ProductPage{}, err
+		}
+		if sort.column == "" {
+			f.conds = append(f.conds, "p.product_id "+op+" "+f.arg(cur.ID))
+		} else {
+			f.conds = append(f.conds, fmt.Sprintf("(%s, p.product_id) %s (%s::%s, %s)",
+				sort.column, op, f.arg(cur.Key), sort.cast, f.arg(cur.ID)))
+		}
+	}
+
+	orderBy := "p.product_id " + dir
+	if sort.column != "" {
+		orderBy = sort.column + " " + dir + ", " + orderBy
+	}
+
+	// ดึงเกินมาหนึ่งแถวเพื่อดูว่ายังมีหน้าถัดไปหรือไม่
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT p.product_id, p.name, p.description, p.brand, p.price,
+		       p.seller_id, p.discount, p.image_url, p.created_at, p.updated_at,
+		       c.category_id, c.name as category_name,
+		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
+		       i.quantity, i.updated_at as inventory_updated_at,
+		       `+attributeSelect+from+`
+		WHERE `+f.where()+`
+		ORDER BY `+orderBy+`
+		LIMIT `+f.arg(q.Limit+1), f.args...)
+	if err != nil {
+		return ProductPage{}, fmt.Errorf("failed to query products: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product ProductItem
+		var category Category
+		var seller Seller
+
+		err := rows.Scan(append([]interface{}{
+			&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
+			&product.SellerID, &product.Discount, &product.Image, &product.CreatedAt, &product.UpdatedAt,
+			&category.ID, &category.Name,
+			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
+			&product.Inventory.Quantity, &product.Inventory.UpdatedAt,
+		}, product.Attributes.scanTargets()...)...)
+		if err != nil {
+			return ProductPage{}, fmt.Errorf("failed to scan product row: %v", err)
+		}
+
+		product.Categories = category
+		product.Seller = seller
+		page.Items = append(page.Items, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return ProductPage{}, fmt.Errorf("failed to iterate product rows: %v", err)
+	}
+
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[q.Limit-1]
+		key := sort.sortKey(last)
+		if q.Sort == sortRelevance {
+			key = strconv.Itoa(rankPosition(q.rankedIDs, last.ID))
+		}
+		if q.Sort == sortInterests {
+			position := rankPosition(q.preferredIDs, last.ID)
+			if position == 0 {
+				position = unrankedPosition
+			}
+			key = strconv.Itoa(position)
+		}
+		page.NextCursor = encodeListingCursor(listingCursor{Sort: q.Sort, Key: key, ID: last.ID})
+	}
+
+	return page, nil
+}
+
+// rankPosition ตำแหน่ง (เริ่มที่ 1 แบบ array_position) ของสินค้าในผลค้นหา
+func rankPosition(ids []int64, productID int) int {
+	for i, id := range ids {
+		if id == int64(productID) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// AllProducts แสดงสินค้าทั้งหมดตามเงื่อนไขของ q
+func (pdb *PostgresDatabase) AllProducts(ctx context.Context, q ListingQuery) (ProductPage, error) {
+	return pdb.ListProducts(ctx, q)
+}
+
+// GetProductRecommend แสดงเฉพาะสินค้าแนะนำ
+func (pdb *PostgresDatabase) GetProductRecommend(ctx context.Context, q ListingQuery) (ProductPage, error) {
+	q.Recommended = true
+	return pdb.ListProducts(ctx, q)
+}
+
+// GetProductByCategory แสดงสินค้าในหมวดหมู่ที่ระบุรวมถึงหมวดหมู่ย่อยทุกระดับ
+func (pdb *PostgresDatabase) GetProductByCategory(ctx context.Context, categoryID int, q ListingQuery) (ProductPage, error) {
+	q.CategoryID = categoryID
+	return pdb.ListProducts(ctx, q)
+}
@@ -0,0 +1,162 @@
+package product
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestListingCursorRoundTrip cursor ที่สร้างจากสินค้าตัวสุดท้ายต้องถอดกลับได้ค่าเดิมในทุกการเรียง
+func TestListingCursorRoundTrip(t *testing.T) {
+	item := ProductItem{
+		ID:        42,
+		Name:      "ตุ๊กตาหมี \"Teddy\"",
+		Price:     199.5,
+		Discount:  15,
+		CreatedAt: time.Date(2026, 3, 1, 8, 30, 0, 123456789, time.UTC),
+	}
+
+	for name, sort := range listingSorts {
+		t.Run("sort="+name, func(t *testing.T) {
+			want := listingCursor{Sort: name, Key: sort.sortKey(item), ID: item.ID}
+			got, err := decodeListingCursor(encodeListingCursor(want), name, sort)
+			if err != nil {
+				t.Fatalf("decodeListingCursor: %v", err)
+			}
+			if got != want {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestListingCursorRanked cursor ของการเรียงตามผลค้นหาเก็บตำแหน่งเป็นตัวเลข
+func TestListingCursorRanked(t *testing.T) {
+	ranked := listingSort{column: "array_position($1::int[], p.product_id)", cast: "int"}
+	want := listingCursor{Sort: sortRelevance, Key: "3", ID: 7}
+
+	got, err := decodeListingCursor(encodeListingCursor(want), sortRelevance, ranked)
+	if err != nil {
+		t.Fatalf("decodeListingCursor: %v", err)
+	}
+	if got != want {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+// TestListingCursorInvalid cursor ที่เสียหรือถูกแก้ต้องได้ ErrInvalidListing
+func TestListingCursorInvalid(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+	}{
+		{"not base64", "%%%", ""},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"id":1}`)), ""},
+		{"not json", raw("id=1"), ""},
+		{"missing id", raw(`{"s":"name","k":"a"}`), "name"},
+		{"negative id", raw(`{"id":-5}`), ""},
+		{"id is a string", raw(`{"id":"5"}`), ""},
+		{"price key is not a number", raw(`{"s":"price_asc","k":"abc","id":1}`), "price_asc"},
+		{"price key is sql", raw(`{"s":"price_desc","k":"1); DROP TABLE products;--","id":1}`), "price_desc"},
+		{"discount key is not an integer", raw(`{"s":"discount","k":"1.5","id":1}`), "discount"},
+		{"newest key is not a time", raw(`{"s":"newest","k":"yesterday","id":1}`), "newest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeListingCursor(tt.cursor, tt.sort, listingSorts[tt.sort])
+			if !errors.Is(err, ErrInvalidListing) {
+				t.Errorf("decodeListingCursor(%q) = %v, want ErrInvalidListing", tt.cursor, err)
+			}
+		})
+	}
+}
+
+// TestListingCursorSortMismatch cursor ใช้ได้กับการเรียงแบบที่สร้างมันเท่านั้น
+func TestListingCursorSortMismatch(t *testing.T) {
+	tests := []struct {
+		from, to string
+	}{
+		{"price_asc", "price_desc"},
+		{"name", ""},
+		{"", "newest"},
+		{sortRelevance, "discount"},
+	}
+
+	for _, tt := range tests {
+		cursor := encodeListingCursor(listingCursor{Sort: tt.from, Key: "1", ID: 10})
+		_, err := decodeListingCursor(cursor, tt.to, listingSorts[tt.to])
+		if !errors.Is(err, ErrInvalidListing) {
+			t.Errorf("cursor from sort %q used with sort %q: err = %v, want ErrInvalidListing", tt.from, tt.to, err)
+		}
+	}
+}
+
+// TestListingSortKey ค่าใน cursor ต้องตรงกับรูปแบบที่ฐานข้อมูลแปลงกลับได้
+func TestListingSortKey(t *testing.T) {
+	item := ProductItem{
+		Name:      "Robot",
+		Price:     1234.5,
+		Discount:  20,
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC),
+	}
+
+	tests := map[string]string{
+		"":           "",
+		"newest":     "2026-01-02T03:04:05.0000006Z",
+		"price_asc":  "1234.5",
+		"price_desc": "1234.5",
+		"discount":   "20",
+		"name":       "Robot",
+	}
+	for name, want := range tests {
+		if got := listingSorts[name].sortKey(item); got != want {
+			t.Errorf("sortKey for sort %q = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestListingQueryValidate ตรวจการเรียงที่รองรับ ค่าเริ่มต้นของ limit และช่วงราคา
+func TestListingQueryValidate(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		q       ListingQuery
+		wantErr bool
+	}{
+		{"defaults", ListingQuery{}, false},
+		{"known sort", ListingQuery{Sort: "price_desc"}, false},
+		{"unknown sort", ListingQuery{Sort: "popular"}, true},
+		{"relevance outside search", ListingQuery{Sort: sortRelevance}, true},
+		{"relevance when searching", ListingQuery{Sort: sortRelevance, rankedIDs: []int64{}}, false},
+		{"interests outside recommendations", ListingQuery{Sort: sortInterests}, true},
+		{"limit too large", ListingQuery{Limit: MaxListingLimit + 1}, true},
+		{"negative limit", ListingQuery{Limit: -1}, true},
+		{"negative price", ListingQuery{MinPrice: price(-1)}, true},
+		{"min above max", ListingQuery{MinPrice: price(500), MaxPrice: price(100)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.q
+			_, err := q.validate()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidListing) {
+					t.Errorf("validate() = %v, want ErrInvalidListing", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() = %v, want nil", err)
+			}
+			if tt.q.Limit == 0 && q.Limit != DefaultListingLimit {
+				t.Errorf("Limit = %d, want default %d", q.Limit, DefaultListingLimit)
+			}
+		})
+	}
+}
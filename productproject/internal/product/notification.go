@@ -0,0 +1,77 @@
+// notification.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotificationNotFound ถูกส่งคืนเมื่อไม่พบการแจ้งเตือน (หรือไม่ใช่ของผู้ใช้คนนั้น)
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// จำนวนการแจ้งเตือนล่าสุดที่แสดงต่อครั้ง
+const maxNotifications = 100
+
+// Notification การแจ้งเตือนถึงผู้ใช้ Kind บอกชนิด เช่น "recall" ซึ่งมี RecallID ของการเรียกคืนด้วย
+type Notification struct {
+	ID        int64      `json:"notification_id"`
+	Kind      string     `json:"kind"`
+	RecallID  *int       `json:"recall_id,omitempty"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func scanNotification(row interface{ Scan(...interface{}) error }) (Notification, error) {
+	var n Notification
+	err := row.Scan(&n.ID, &n.Kind, &n.RecallID, &n.Title, &n.Message, &n.ReadAt, &n.CreatedAt)
+	return n, err
+}
+
+// GetNotifications แสดงการแจ้งเตือนล่าสุดของผู้ใช้ (ไม่เกิน maxNotifications รายการ)
+// unreadOnly เป็น true คือเฉพาะที่ยังไม่ได้อ่าน
+func (pdb *PostgresDatabase) GetNotifications(ctx context.Context, userID string, unreadOnly bool) ([]Notification, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT notification_id, kind, recall_id, title, message, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, notification_id DESC
+		LIMIT $3`, userID, unreadOnly, maxNotifications)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate notifications: %v", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationRead บันทึกว่าผู้ใช้อ่านการแจ้งเตือนแล้ว (เวลาที่อ่านครั้งแรกไม่ถูกเปลี่ยน)
+func (pdb *PostgresDatabase) MarkNotificationRead(ctx context.Context, userID string, notificationID int64) (Notification, error) {
+	n, err := scanNotification(pdb.db.QueryRowContext(ctx, `
+		UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE notification_id = $1 AND user_id = $2
+		RETURNING notification_id, kind, recall_id, title, message, read_at, created_at`, notificationID, userID))
+	if err == sql.ErrNoRows {
+		return Notification{}, ErrNotificationNotFound
+	} else if err != nil {
+		return Notification{}, fmt.Errorf("failed to mark notification as read: %v", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"productproject/internal/inventory"
+)
+
+// TestCreateOrderConcurrentLastUnit ยิงคำสั่งซื้อพร้อมกันหลายรายการไปที่สินค้าที่เหลือชิ้นเดียว
+// ต้องมีเพียงคำสั่งซื้อเดียวที่สำเร็จ ที่เหลือต้องได้ InsufficientStockError และสต็อกต้องไม่ติดลบ
+//
+// ต้องใช้ฐานข้อมูลที่สร้างจาก ecomdatabase/docker/init.sql เช่น
+// TEST_DATABASE_URL="host=localhost port=5435 user=ecommerce_user password=... dbname=ecommerce sslmode=disable"
+func TestCreateOrderConcurrentLastUnit(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	const buyers = 8
+
+	pdb, err := NewPostgresDatabase(connStr)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// สินค้าทดสอบที่เหลือ 1 ชิ้น (trigger สร้างตัวเลือกหลักพร้อมแถว inventory ให้จาก product_stock)
+	var productID int
+	err = pdb.db.QueryRowContext(ctx, `
+		INSERT INTO products (name, product_stock, price, product_recommend, seller_id, category_id)
+		VALUES ('concurrency test toy', 1, 100, 'notrecommend', 1, 1)
+		RETURNING product_id`).Scan(&productID)
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	var userIDs []string
+	defer func() {
+		cleanup := context.Background()
+		pdb.db.ExecContext(cleanup, `DELETE FROM orders WHERE order_id IN (SELECT order_id FROM order_items WHERE product_id = $1)`, productID)
+		pdb.db.ExecContext(cleanup, `DELETE FROM products WHERE product_id = $1`, productID)
+		for _, userID := range userIDs {
+			pdb.db.ExecContext(cleanup, `DELETE FROM users WHERE user_id = $1`, userID)
+		}
+	}()
+
+	// ผู้ซื้อแต่ละคนมีตะกร้าของตัวเองที่ใส่สินค้าชิ้นนี้ไว้ 1 ชิ้น
+	// แทรก cart_items ตรงๆ โดยไม่มีการจอง (เหมือนตะกร้าที่การจองหมดอายุไปแล้ว)
+	// เพราะ AddToCart จะไม่ยอมให้ผู้ใช้คนที่สองจองชิ้นสุดท้ายได้
+	cartItemIDs := make([]int, buyers)
+	for i := 0; i < buyers; i++ {
+		var userID string
+		tag := fmt.Sprintf("concurrency-%d-%d", productID, i)
+		err := pdb.db.QueryRowContext(ctx, `
+			INSERT INTO users (google_id, email, full_name)
+			VALUES ($1, $1 || '@example.com', $1)
+			RETURNING user_id`, tag).Scan(&userID)
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		userIDs = append(userIDs, userID)
+
+		err = pdb.db.QueryRowContext(ctx, `
+			INSERT INTO cart_items (user_id, product_id, variant_id, quantity, total_price)
+			VALUES ($1, $2, (SELECT variant_id FROM product_variants WHERE product_id = $2 AND is_default), 1, 100)
+			RETURNING cart_item_id`, userID, productID).Scan(&cartItemIDs[i])
+		if err != nil {
+			t.Fatalf("add to cart: %v", err)
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		start     = make(chan struct{})
+		errs      = make([]error, buyers)
+		successes = 0
+	)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			items := []CartItem{{CartItemID: cartItemIDs[i], ProductID: productID}}
+			_, _, errs[i] = pdb.CreateOrder(ctx, userIDs[i], items, 100)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, err := range errs {
+		var stockErr *inventory.InsufficientStockError
+		switch {
+		case err == nil:
+			successes++
+		case errors.As(err, &stockErr):
+			if len(stockErr.Items) != 1 || stockErr.Items[0].ProductID != productID || stockErr.Items[0].Available != 0 {
+				t.Errorf("buyer %d: unexpected shortage %+v", i, stockErr.Items)
+			}
+		default:
+			t.Errorf("buyer %d: unexpected error: %v", i, err)
+		}
+	}
+	if successes != 1 {
+		t.Fatalf("expected exactly 1 successful order, got %d", successes)
+	}
+
+	var inventoryQty, productStock int
+	err = pdb.db.QueryRowContext(ctx, `
+		SELECT i.quantity, p.product_stock
+		FROM products p JOIN product_inventory i ON i.product_id = p.product_id
+		WHERE p.product_id = $1`, productID).Scan(&inventoryQty, &productStock)
+	if err != nil {
+		t.Fatalf("read stock: %v", err)
+	}
+	if inventoryQty != 0 || productStock != 0 {
+		t.Fatalf("expected stock 0/0 after sale, got inventory=%d product_stock=%d", inventoryQty, productStock)
+	}
+}
@@ -3,12 +3,18 @@ package product
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 
 	// "log"
 
 	"time"
 
+	"productproject/internal/inventory"
+	"productproject/internal/safety"
+	"productproject/internal/search"
+
 	_ "github.com/lib/pq"
 )
 
@@ -28,9 +34,13 @@ type ProductItem struct {
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 
-	Categories Category  `json:"categories"` // หมวดหมู่ของสินค้า
-	Seller     Seller    `json:"seller"`     // ข้อมูลผู้ขาย
-	Inventory  Inventory `json:"inventory"`  // ข้อมูลของสินค้าคงคลัง
+	Attributes ToyAttributes  `json:"attributes"`         // ข้อมูลจำเพาะ (อายุ ถ่าน ขนาด น้ำหนัก วัสดุ)
+	Categories Category       `json:"categories"`         // หมวดหมู่ของสินค้า
+	Seller     Seller         `json:"seller"`             // ข้อมูลผู้ขาย
+	Inventory  Inventory      `json:"inventory"`          // ข้อมูลของสินค้าคงคลัง (รวมทุกตัวเลือก)
+	Variants   []Variant      `json:"variants,omitempty"` // ตัวเลือกของสินค้า (เฉพาะการดูสินค้าทีละชิ้น)
+	Gallery    []ProductImage `json:"gallery,omitempty"`  // รูปทั้งหมดของสินค้าเรียงตามลำดับ (เฉพาะการดูสินค้าทีละชิ้น)
+	Safety     *safety.Label  `json:"safety,omitempty"`   // ฉลากความปลอดภัย คำเตือน และเลขที่ มอก. (เฉพาะการดูสินค้าทีละชิ้น)
 }
 
 // Struct สำหรับข้อมูลหมวดหมู่
@@ -38,6 +48,11 @@ type Category struct {
 	ID          int    `json:"category_id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	ParentID    *int   `json:"parent_id,omitempty"` // หมวดหมู่แม่ (nil คือหมวดหมู่ระดับบนสุด)
+
+	// ช่วงอายุ (เดือน) ของหมวดหมู่ที่เป็นช่วงอายุ MaxAgeMonths เป็น nil คือไม่จำกัด
+	MinAgeMonths *int `json:"min_age_months,omitempty"`
+	MaxAgeMonths *int `json:"max_age_months,omitempty"`
 }
 
 // Struct สำหรับข้อมูลผู้ขาย
@@ -54,6 +69,8 @@ type Seller struct {
 // Struct สำหรับข้อมูลสินค้าคงคลัง
 type Inventory struct {
 	Quantity  int       `json:"quantity"`
+	Reserved  int       `json:"reserved"`  // จำนวนที่ถูกจองในตะกร้าและยังไม่หมดอายุ
+	Available int       `json:"available"` // จำนวนที่ยังซื้อได้ (quantity - reserved)
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
@@ -72,19 +89,20 @@ type Product struct {
 	Categories       Category  `json:"category"` // ข้อมูลหมวดหมู่ของสินค้า
 }
 
-type UpdateProduct struct {
-	Price  float64 `json:"price"`
-	Status string  `json:"status"`
-}
-
 type CartItem struct {
-	CartItemID int           `json:"cart_item_id"`
-	ProductID  int           `json:"product_id"`
-	Quantity   int           `json:"quantity"`
-	TotalPrice float64       `json:"total_price"`
-	AddedAt    time.Time     `json:"added_at"`
-	Status     string        `json:"status"`
-	Product    []ProductItem `json:"product"` // เปลี่ยนเป็น array ของ ProductItem
+	CartItemID    int           `json:"cart_item_id"`
+	ProductID     int           `json:"product_id"`
+	VariantID     int           `json:"variant_id"`
+	SKU           string        `json:"sku,omitempty"`
+	VariantName   string        `json:"variant_name,omitempty"`
+	Quantity      int           `json:"quantity"`
+	TotalPrice    float64       `json:"total_price"`
+	UnitPrice     float64       `json:"unit_price,omitempty"` // ราคาต่อชิ้น ณ เวลาสั่งซื้อ (เฉพาะรายการในคำสั่งซื้อ)
+	Discount      int           `json:"discount,omitempty"`   // ส่วนลด (%) ณ เวลาสั่งซื้อ (เฉพาะรายการในคำสั่งซื้อ)
+	AddedAt       time.Time     `json:"added_at"`
+	Status        string        `json:"status"`
+	ReservedUntil *time.Time    `json:"reserved_until,omitempty"` // เวลาที่การจองของรายการนี้หมดอายุ (ไม่มีหากไม่ได้จองไว้)
+	Product       []ProductItem `json:"product"`                  // เปลี่ยนเป็น array ของ ProductItem
 }
 
 type User struct {
@@ -106,37 +124,96 @@ type User struct {
 
 type Order struct {
 	OrderID     int        `json:"order_id"`
+	UserID      string     `json:"user_id"`
 	CartItems   []CartItem `json:"cart_item_id"`
 	TotalAmount float64    `json:"total_amount"`
 	OrderDate   time.Time  `json:"order_date"`
+	Shipments   []Shipment `json:"shipments,omitempty"` // การจัดส่งของแต่ละร้าน (เฉพาะเมื่อดึงคำสั่งซื้อทีละรายการ)
 }
 
+// ErrTotalMismatch ถูกส่งคืนเมื่อยอดรวมที่ client ส่งมาไม่ตรงกับยอดที่คำนวณจากราคาปัจจุบัน
+var ErrTotalMismatch = errors.New("total amount does not match current prices")
+
+// ErrInvalidOrder ถูกส่งคืนเมื่อข้อมูลคำสั่งซื้อไม่ถูกต้อง เช่น ไม่มีรายการ มีรายการซ้ำ หรือยอดรวมไม่มากกว่า 0
+var ErrInvalidOrder = errors.New("invalid order")
+
+// ErrCartItemNotFound ถูกส่งคืนเมื่อรายการไม่อยู่ในตะกร้าของผู้ใช้ หรือถูกสั่งซื้อไปแล้ว
+var ErrCartItemNotFound = errors.New("cart item not found")
+
 type EcommerceDatabase interface {
 	GetProduct(ctx context.Context, id string) (ProductItem, error)
-	GetProductRecommend(ctx context.Context) ([]ProductItem, error)
+	GetProductRecommend(ctx context.Context, q ListingQuery) (ProductPage, error)
 	GetNewProducts(ctx context.Context) ([]ProductItem, error)
-	SearchProducts(ctx context.Context, query string) ([]ProductItem, error)
-	AllProducts(ctx context.Context) ([]ProductItem, error)
+	SearchProducts(ctx context.Context, query string, q ListingQuery) (ProductPage, error)
+	SuggestProducts(ctx context.Context, prefix string, limit int) ([]search.Suggestion, error)
+	AllProducts(ctx context.Context, q ListingQuery) (ProductPage, error)
+	ListProducts(ctx context.Context, q ListingQuery) (ProductPage, error)
 	GetSeller(ctx context.Context, id string) (Seller, error)
-	GetProductByCategory(ctx context.Context, categoryID string) ([]ProductItem, error)
-	AddToCart(ctx context.Context, productID, quantity int) error
-	GetAllCartItems(ctx context.Context) ([]CartItem, error)
+	GetProductByCategory(ctx context.Context, categoryID int, q ListingQuery) (ProductPage, error)
+	AddToCart(ctx context.Context, userID string, productID, variantID, quantity int, acknowledgeSafety bool) ([]safety.Hazard, error)
+	GetAllCartItems(ctx context.Context, userID string) ([]CartItem, error)
 	GetUserByID(ctx context.Context, userID string) (*User, error)
-	UpdateCartItemQuantity(ctx context.Context, cartItemID string, quantity int) error
-	DeleteCartItem(ctx context.Context, cartItemID string) error
-	CreateOrder(ctx context.Context, cartItemID []CartItem, totalAmount float64) (int, error)
+	UpdateCartItemQuantity(ctx context.Context, userID, cartItemID string, quantity int) error
+	DeleteCartItem(ctx context.Context, userID, cartItemID string) error
+	CreateOrder(ctx context.Context, userID string, cartItemID []CartItem, expectedTotal float64) (int, float64, error)
 	GetOrders(ctx context.Context) ([]Order, error)
-	UpdateCartItemStatus(ctx context.Context, orderID int, sellerID int, status string) error
 	GetOrdersSort(ctx context.Context, status string) ([]Order, error)
-	GetCurrentCartItemStatus(ctx context.Context, orderID int, sellerID int) (string, error)
+	GetUserOrders(ctx context.Context, userID string) ([]Order, error)
+	GetUserOrder(ctx context.Context, userID string, orderID int) (Order, error)
+	GetSellerOrders(ctx context.Context, sellerID int, q SellerOrderQuery) ([]SellerOrder, error)
 	UpdateUserContact(ctx context.Context, userID string, displayName, address, phone string) error
+	CreateProduct(ctx context.Context, p NewProduct) (ProductItem, error)
+	UpdateProduct(ctx context.Context, productID int, u UpdateProduct, userID string) (ProductItem, error)
+	DeleteProduct(ctx context.Context, productID int) error
+	GetProductSellerID(ctx context.Context, productID int) (int, error)
+	GetSellerIDByUserID(ctx context.Context, userID string) (int, error)
+	RegisterSeller(ctx context.Context, userID string, s NewSeller) (Seller, error)
+	UpdateSeller(ctx context.Context, sellerID int, u UpdateSeller) (Seller, error)
+	DeactivateSeller(ctx context.Context, sellerID int) error
+	GetCategoryTree(ctx context.Context) ([]CategoryNode, error)
+	CreateCategory(ctx context.Context, c NewCategory) (Category, error)
+	UpdateCategory(ctx context.Context, categoryID int, u UpdateCategory) (Category, error)
+	DeleteCategory(ctx context.Context, categoryID int) error
+	GetVariants(ctx context.Context, productID int) ([]Variant, error)
+	CreateVariant(ctx context.Context, productID int, v NewVariant, userID string) (Variant, error)
+	UpdateVariant(ctx context.Context, productID, variantID int, u UpdateVariant, userID string) (Variant, error)
+	DeleteVariant(ctx context.Context, productID, variantID int, userID string) error
+	GetProductImages(ctx context.Context, productID int) ([]ProductImage, error)
+	AddProductImage(ctx context.Context, productID int, img NewProductImage) (ProductImage, error)
+	UpdateProductImage(ctx context.Context, productID, imageID int, u UpdateProductImage) (ProductImage, error)
+	ReorderProductImages(ctx context.Context, productID int, imageIDs []int) ([]ProductImage, error)
+	DeleteProductImage(ctx context.Context, productID, imageID int) (ProductImage, error)
+	GetChildProfiles(ctx context.Context, userID string) ([]ChildProfile, error)
+	CreateChildProfile(ctx context.Context, userID string, c NewChildProfile) (ChildProfile, error)
+	UpdateChildProfile(ctx context.Context, userID string, childID int, u UpdateChildProfile) (ChildProfile, error)
+	DeleteChildProfile(ctx context.Context, userID string, childID int) error
+	RecommendForChild(ctx context.Context, userID string, childID int, q ListingQuery) (ChildRecommendations, error)
+	CreateRecall(ctx context.Context, r NewRecall, userID string) (Recall, error)
+	GetRecalls(ctx context.Context, status string) ([]Recall, error)
+	GetRecall(ctx context.Context, recallID int) (Recall, error)
+	CloseRecall(ctx context.Context, recallID int) (Recall, error)
+	GetRecallReport(ctx context.Context, recallID int) (RecallReport, error)
+	GetNotifications(ctx context.Context, userID string, unreadOnly bool) ([]Notification, error)
+	MarkNotificationRead(ctx context.Context, userID string, notificationID int64) (Notification, error)
+	GetShipments(ctx context.Context, orderID int) ([]Shipment, error)
+	GetShipment(ctx context.Context, shipmentID int) (Shipment, error)
+	GetSellerShipment(ctx context.Context, orderID, sellerID int) (Shipment, error)
+	TransitionShipment(ctx context.Context, shipmentID int, t ShipmentTransition, actor, userID string) (Shipment, error)
+	CancelOrder(ctx context.Context, orderID, sellerID int, reason, actor, userID string) (CancelledOrder, error)
+	CreateReturn(ctx context.Context, userID string, r NewReturn) (ReturnRequest, error)
+	GetReturns(ctx context.Context, filter ReturnFilter) ([]ReturnRequest, error)
+	GetReturn(ctx context.Context, returnID int) (ReturnRequest, error)
+	ApproveReturn(ctx context.Context, returnID int, d ReturnDecision, actor, userID string) (ReturnRequest, error)
+	RejectReturn(ctx context.Context, returnID int, d ReturnDecision, userID string) (ReturnRequest, error)
 	Close() error
 	Ping() error
 	Reconnect(connStr string) error
 }
 
 type PostgresDatabase struct {
-	db *sql.DB
+	db             *sql.DB
+	reservationTTL time.Duration
+	searchIndex    *search.Index
 }
 
 func NewPostgresDatabase(connStr string) (*PostgresDatabase, error) {
@@ -156,7 +233,14 @@ func NewPostgresDatabase(connStr string) (*PostgresDatabase, error) {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	return &PostgresDatabase{db: db}, nil
+	return &PostgresDatabase{db: db, reservationTTL: inventory.DefaultReservationTTL, searchIndex: search.NewIndex()}, nil
+}
+
+// SetReservationTTL กำหนดระยะเวลาที่จองสินค้าในตะกร้าไว้ให้ผู้ใช้หลังการแก้ตะกร้าครั้งล่าสุด
+func (pdb *PostgresDatabase) SetReservationTTL(ttl time.Duration) {
+	if ttl > 0 {
+		pdb.reservationTTL = ttl
+	}
 }
 
 // ฟังก์ชัน GetSeller สำหรับดึงข้อมูลร้านค้าตาม seller_id
@@ -168,13 +252,13 @@ func (pdb *PostgresDatabase) GetSeller(ctx context.Context, sellerID string) (Se
 	err := pdb.db.QueryRowContext(ctx, `
 	SELECT seller_id, name, address, phone_number, email, description
 	FROM sellers
-	WHERE seller_id = $1
+	WHERE seller_id = $1 AND deactivated_at IS NULL
 	`, sellerID).Scan(
 		&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return Seller{}, fmt.Errorf("seller not found")
+			return Seller{}, ErrSellerNotFound
 		}
 		return Seller{}, fmt.Errorf("failed to get seller: %v", err)
 	}
@@ -185,7 +269,7 @@ func (pdb *PostgresDatabase) GetSeller(ctx context.Context, sellerID string) (Se
 		   p.discount, p.image_url, p.created_at, p.updated_at, c.category_id, c.name as category_name
 	FROM products p
 	LEFT JOIN categories c ON p.category_id = c.category_id
-	WHERE p.seller_id = $1
+	WHERE p.seller_id = $1 AND p.deleted_at IS NULL
 	`, sellerID)
 	if err != nil {
 		return Seller{}, fmt.Errorf("failed to get products for seller: %v", err)
@@ -227,16 +311,18 @@ func (pdb *PostgresDatabase) GetProduct(ctx context.Context, id string) (Product
 		SELECT p.product_id, p.name, p.description, p.brand, p.price, 
 		        p.seller_id, p.discount,p.image_url ,p.product_status,product_recommend,p.created_at, p.updated_at,
 		       c.category_id, c.name as category_name,
-		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description
+		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
+		       `+attributeSelect+`
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.category_id
 		LEFT JOIN sellers s ON p.seller_id = s.seller_id
-		WHERE p.product_id = $1
-	`, id).Scan(
+		WHERE p.product_id = $1 AND p.deleted_at IS NULL AND s.deactivated_at IS NULL
+	`, id).Scan(append([]interface{}{
 		&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
 		&product.SellerID, &product.Discount, &product.Image, &product.ProductStatus, &product.ProductRecommend, &product.CreatedAt, &product.UpdatedAt,
 		&category.ID, &category.Name,
-		&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description)
+		&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
+	}, product.Attributes.scanTargets()...)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -248,118 +334,41 @@ func (pdb *PostgresDatabase) GetProduct(ctx context.Context, id string) (Product
 	product.Categories = category
 	product.Seller = seller
 
-	// ดึงข้อมูล inventory
+	// ดึงข้อมูล inventory รวมทุกตัวเลือก พร้อมจำนวนที่ถูกจองในตะกร้า
 	err = pdb.db.QueryRowContext(ctx, `
-		SELECT quantity, updated_at
-		FROM inventory
-		WHERE product_id = $1
-	`, id).Scan(&product.Inventory.Quantity, &product.Inventory.UpdatedAt)
+		SELECT i.quantity, i.updated_at,
+		       COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r
+		                 WHERE r.product_id = i.product_id AND r.expires_at > CURRENT_TIMESTAMP), 0)
+		FROM product_inventory i
+		WHERE i.product_id = $1
+	`, id).Scan(&product.Inventory.Quantity, &product.Inventory.UpdatedAt, &product.Inventory.Reserved)
 
 	if err != nil && err != sql.ErrNoRows {
 		return ProductItem{}, fmt.Errorf("failed to get inventory: %v", err)
 	}
 
-	return product, nil
-}
-
-func (pdb *PostgresDatabase) GetProductRecommend(ctx context.Context) ([]ProductItem, error) {
-	var products []ProductItem
-
-	rows, err := pdb.db.QueryContext(ctx, `
-		SELECT p.product_id, p.name, p.description, p.brand, p.price, 
-		       p.seller_id, p.discount, p.image_url, p.created_at, p.updated_at,
-		       c.category_id, c.name as category_name,
-		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
-		       i.quantity, i.updated_at as inventory_updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.category_id
-		LEFT JOIN sellers s ON p.seller_id = s.seller_id
-		LEFT JOIN inventory i ON p.product_id = i.product_id
-		WHERE p.product_recommend = 'recommend'
-	`)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to query recommended products: %v", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var product ProductItem
-		var category Category
-		var seller Seller
-
-		err := rows.Scan(
-			&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
-			&product.SellerID, &product.Discount, &product.Image, &product.CreatedAt, &product.UpdatedAt,
-			&category.ID, &category.Name,
-			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
-			&product.Inventory.Quantity, &product.Inventory.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan product row: %v", err)
-		}
-
-		product.Categories = category
-		product.Seller = seller
-		products = append(products, product)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate product rows: %v", err)
+	product.Inventory.Available = product.Inventory.Quantity - product.Inventory.Reserved
+	if product.Inventory.Available < 0 {
+		product.Inventory.Available = 0
 	}
 
-	return products, nil
-}
-
-func (pdb *PostgresDatabase) GetProductByCategory(ctx context.Context, categoryID string) ([]ProductItem, error) {
-	var products []ProductItem
-
-	rows, err := pdb.db.QueryContext(ctx, `
-		SELECT p.product_id, p.name, p.description, p.brand, p.price, 
-		       p.seller_id, p.discount, p.image_url, p.created_at, p.updated_at,
-		       c.category_id, c.name as category_name,
-		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
-		       i.quantity, i.updated_at as inventory_updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.category_id
-		LEFT JOIN sellers s ON p.seller_id = s.seller_id
-		LEFT JOIN inventory i ON p.product_id = i.product_id
-		WHERE p.category_id = $1
-	`, categoryID)
-
+	product.Variants, err = pdb.GetVariants(ctx, product.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query products by category: %v", err)
+		return ProductItem{}, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var product ProductItem
-		var category Category
-		var seller Seller
-
-		err := rows.Scan(
-			&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
-			&product.SellerID, &product.Discount, &product.Image, &product.CreatedAt, &product.UpdatedAt,
-			&category.ID, &category.Name,
-			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
-			&product.Inventory.Quantity, &product.Inventory.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan product row: %v", err)
-		}
-
-		product.Categories = category
-		product.Seller = seller
-		products = append(products, product)
+	product.Gallery, err = pdb.queryProductImages(ctx, product.ID, 0)
+	if err != nil {
+		return ProductItem{}, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate product rows: %v", err)
+	label, _, err := querySafetyLabel(ctx, pdb.db, product.ID)
+	if err != nil {
+		return ProductItem{}, err
 	}
+	product.Safety = &label
 
-	return products, nil
+	return product, nil
 }
 
 func (pdb *PostgresDatabase) GetNewProducts(ctx context.Context) ([]ProductItem, error) {
@@ -370,11 +379,13 @@ func (pdb *PostgresDatabase) GetNewProducts(ctx context.Context) ([]ProductItem,
                p.seller_id, p.discount, p.image_url, p.created_at, p.updated_at,
                c.category_id, c.name as category_name,
                s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
-               i.quantity, i.updated_at as inventory_updated_at
+               i.quantity, i.updated_at as inventory_updated_at,
+               `+attributeSelect+`
         FROM products p
         LEFT JOIN categories c ON p.category_id = c.category_id
         LEFT JOIN sellers s ON p.seller_id = s.seller_id
-        LEFT JOIN inventory i ON p.product_id = i.product_id
+        LEFT JOIN product_inventory i ON p.product_id = i.product_id
+        WHERE p.deleted_at IS NULL AND s.deactivated_at IS NULL AND NOT `+productRecalled+`
         ORDER BY p.seller_id, p.created_at DESC
     `)
 
@@ -388,13 +399,13 @@ func (pdb *PostgresDatabase) GetNewProducts(ctx context.Context) ([]ProductItem,
 		var category Category
 		var seller Seller
 
-		err := rows.Scan(
+		err := rows.Scan(append([]interface{}{
 			&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
 			&product.SellerID, &product.Discount, &product.Image, &product.CreatedAt, &product.UpdatedAt,
 			&category.ID, &category.Name,
 			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
 			&product.Inventory.Quantity, &product.Inventory.UpdatedAt,
-		)
+		}, product.Attributes.scanTargets()...)...)
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan product row: %v", err)
@@ -412,191 +423,154 @@ func (pdb *PostgresDatabase) GetNewProducts(ctx context.Context) ([]ProductItem,
 	return products, nil
 }
 
-func (pdb *PostgresDatabase) AllProducts(ctx context.Context) ([]ProductItem, error) {
-	var products []ProductItem
-
-	// Query to fetch all products from the database
-	rows, err := pdb.db.QueryContext(ctx, `
-		SELECT p.product_id, p.name, p.description, p.brand, p.price, 
-		       p.seller_id, p.discount, p.image_url, p.created_at, p.updated_at,
-		       c.category_id, c.name as category_name,
-		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
-		       i.quantity, i.updated_at as inventory_updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.category_id
-		LEFT JOIN sellers s ON p.seller_id = s.seller_id
-		LEFT JOIN inventory i ON p.product_id = i.product_id ORDER BY product_id ASC;
-	`)
-
+// AddToCart เพิ่มตัวเลือกของสินค้าในตะกร้าของผู้ใช้และจองไว้ตามจำนวนในตะกร้า
+// variantID เป็น 0 คือตัวเลือกหลักของสินค้า หากไม่พบตัวเลือกจะคืน ErrVariantNotFound
+// หากจำนวนรวมในตะกร้าเกินกว่าที่ซื้อได้ (สต็อกหักการจองของผู้ใช้อื่น) จะคืน *inventory.InsufficientStockError
+// สินค้าที่มีความเสี่ยง (เช่นชิ้นส่วนเล็ก) จะคืนคำเตือนบนฉลากเสมอ และหากผู้ใช้มีลูกที่อายุต่ำกว่าอายุขั้นต่ำ
+// ต้องส่ง acknowledgeSafety มาด้วย มิฉะนั้นคืน *SafetyAcknowledgementError (เวลาที่ยืนยันถูกบันทึกไว้ในตะกร้า)
+// ตัวเลือกที่อยู่ระหว่างการเรียกคืนจะคืน ErrProductRecalled
+func (pdb *PostgresDatabase) AddToCart(ctx context.Context, userID string, productID, variantID, quantity int, acknowledgeSafety bool) ([]safety.Hazard, error) {
+	tx, err := pdb.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query all products: %v", err)
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var product ProductItem
-		var category Category
-		var seller Seller
 
-		// Scanning each row into the product struct and its related data
-		err := rows.Scan(
-			&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
-			&product.SellerID, &product.Discount, &product.Image, &product.CreatedAt, &product.UpdatedAt,
-			&category.ID, &category.Name,
-			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
-			&product.Inventory.Quantity, &product.Inventory.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan product row: %v", err)
-		}
-
-		// Add product with its category and seller info to the products slice
-		product.Categories = category
-		product.Seller = seller
-		products = append(products, product)
+	variantID, err = resolveVariant(ctx, tx, productID, variantID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
-	// Check for any errors that occurred during the iteration of rows
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate product rows: %v", err)
+	// ตรวจสอบว่ามีสินค้ารายการนี้อยู่ในฐานข้อมูลและดึงราคาของตัวเลือก
+	var name, sku string
+	var price float64
+	var recalled bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.name, v.sku, COALESCE(v.price, p.price), `+variantRecalled+`
+		FROM product_variants v
+		JOIN products p ON p.product_id = v.product_id
+		JOIN sellers s ON s.seller_id = p.seller_id
+		WHERE v.variant_id = $1 AND p.deleted_at IS NULL AND s.deactivated_at IS NULL`, variantID).Scan(&name, &sku, &price, &recalled)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to get product price: %v", err)
+	}
+	if recalled {
+		tx.Rollback()
+		return nil, fmt.Errorf("%w: %s (%s)", ErrProductRecalled, name, sku)
 	}
 
-	// Return the list of products
-	return products, nil
-}
-
-func (pdb *PostgresDatabase) SearchProducts(ctx context.Context, query string) ([]ProductItem, error) {
-	var products []ProductItem
-
-	// Corrected SQL query with placeholders for parameters
-	rows, err := pdb.db.QueryContext(ctx, `
-		SELECT p.product_id, p.name, p.description, p.brand, p.price, 
-		       p.seller_id, p.discount, p.image_url, p.created_at, p.updated_at,
-		       c.category_id, c.name as category_name,
-		       s.seller_id, s.name as seller_name, s.address, s.phone_number, s.email, s.description as seller_description,
-		       i.quantity, i.updated_at as inventory_updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.category_id
-		LEFT JOIN sellers s ON p.seller_id = s.seller_id
-		LEFT JOIN inventory i ON p.product_id = i.product_id
-		WHERE p.name ILIKE $1
-	`, "%"+query+"%") // Passing query parameter safely
-
+	label, minAge, atRisk, err := checkCartSafety(ctx, tx, userID, productID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search products: %v", err)
+		tx.Rollback()
+		return nil, err
+	}
+	if len(atRisk) > 0 && !acknowledgeSafety {
+		tx.Rollback()
+		return nil, &SafetyAcknowledgementError{
+			ProductID:    productID,
+			Name:         name,
+			MinAgeMonths: minAge,
+			Warnings:     label.Warnings,
+			Children:     atRisk,
+		}
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var product ProductItem
-		var category Category
-		var seller Seller
-		// You should also scan the additional fields into the appropriate structs if needed
-		err := rows.Scan(
-			&product.ID, &product.Name, &product.Description, &product.Brand, &product.Price,
-			&product.SellerID, &product.Discount, &product.Image, &product.CreatedAt, &product.UpdatedAt,
-			&category.ID, &category.Name,
-			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
-			&product.Inventory.Quantity, &product.Inventory.UpdatedAt,
-		)
+	// คำนวณ total_price
+	totalPrice := float64(quantity) * price
+
+	// ตรวจสอบว่าผู้ใช้มีตัวเลือกนี้อยู่ในตะกร้าที่ยังไม่ได้สั่งซื้อหรือไม่ ถ้ามีแล้วให้เพิ่มจำนวนสินค้าและอัปเดต total_price
+	var cartItemID, cartQuantity int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE cart_items
+		SET quantity = quantity + $1, total_price = total_price + $2
+		WHERE user_id = $3 AND variant_id = $4 AND added_to_cart = FALSE
+		RETURNING cart_item_id, quantity
+	`, quantity, totalPrice, userID, variantID).Scan(&cartItemID, &cartQuantity)
+	if err == sql.ErrNoRows {
+		// เพิ่มสินค้ารายการใหม่ในตะกร้าของผู้ใช้
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO cart_items (user_id, product_id, variant_id, quantity, total_price, added_at)
+			VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+			RETURNING cart_item_id, quantity
+		`, userID, productID, variantID, quantity, totalPrice).Scan(&cartItemID, &cartQuantity)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan product row: %v", err)
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to add product to cart: %v", err)
 		}
-		products = append(products, product)
+	} else if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update product quantity in cart: %v", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate product rows: %v", err)
+	if len(atRisk) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE cart_items SET safety_acknowledged_at = CURRENT_TIMESTAMP
+			WHERE cart_item_id = $1`, cartItemID)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to record safety acknowledgement: %v", err)
+		}
 	}
 
-	return products, nil
-}
-
-func (pdb *PostgresDatabase) AddToCart(ctx context.Context, productID, quantity int) error {
-	// ตรวจสอบว่ามีสินค้ารายการนี้อยู่ในฐานข้อมูลและดึงราคาของสินค้า
-	var price float64
-	err := pdb.db.QueryRowContext(ctx, `SELECT price FROM products WHERE product_id = $1`, productID).Scan(&price)
+	// จองสินค้าตามจำนวนทั้งหมดในตะกร้า
+	available, err := inventory.LockAvailable(ctx, tx, variantID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to get product price: %v", err)
+		tx.Rollback()
+		return nil, err
+	}
+	if cartQuantity > available {
+		tx.Rollback()
+		return nil, &inventory.InsufficientStockError{Items: []inventory.Shortage{{
+			ProductID: productID,
+			VariantID: variantID,
+			SKU:       sku,
+			Name:      name,
+			Requested: cartQuantity,
+			Available: available,
+		}}}
 	}
 
-	// คำนวณ total_price
-	totalPrice := float64(quantity) * price
-
-	// ตรวจสอบว่ามีสินค้านี้อยู่แล้วในตะกร้า ถ้ามีแล้วให้เพิ่มจำนวนสินค้าและอัปเดต total_price
-	var existsInCart bool
-	err = pdb.db.QueryRowContext(ctx, `
-        SELECT EXISTS(SELECT 1 FROM cart_items WHERE product_id = $1)
-    `, productID).Scan(&existsInCart)
+	err = inventory.Reserve(ctx, tx, inventory.Reservation{
+		CartItemID: cartItemID,
+		UserID:     userID,
+		ProductID:  productID,
+		VariantID:  variantID,
+		Quantity:   cartQuantity,
+	}, pdb.reservationTTL)
 	if err != nil {
-		return fmt.Errorf("failed to check if product exists in cart: %v", err)
+		tx.Rollback()
+		return nil, err
 	}
 
-	if existsInCart {
-		// ตรวจสอบว่า add_to_cart เป็น true หรือไม่
-		var addToCart bool
-		err = pdb.db.QueryRowContext(ctx, `
-			SELECT added_to_cart
-			FROM cart_items
-			WHERE product_id = $1
-		`, productID).Scan(&addToCart)
-		if err != nil {
-			return fmt.Errorf("failed to check add_to_cart status: %v", err)
-		}
-
-		if addToCart {
-			// สร้างตะกร้าใหม่หาก add_to_cart เป็น true
-			_, err = pdb.db.ExecContext(ctx, `
-				INSERT INTO cart_items (product_id, quantity, total_price, added_at)
-				VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
-			`, productID, quantity, totalPrice)
-			if err != nil {
-				return fmt.Errorf("failed to create a new cart: %v", err)
-			}
-		} else {
-			// อัปเดตจำนวนสินค้าที่มีอยู่ในตะกร้าเดิม
-			_, err = pdb.db.ExecContext(ctx, `
-				UPDATE cart_items
-				SET quantity = quantity + $1, total_price = total_price + $2
-				WHERE product_id = $3
-			`, quantity, totalPrice, productID)
-			if err != nil {
-				return fmt.Errorf("failed to update product quantity in cart: %v", err)
-			}
-		}
-	} else {
-		// เพิ่มสินค้ารายการใหม่ในตะกร้า
-		_, err = pdb.db.ExecContext(ctx, `
-			INSERT INTO cart_items (product_id, quantity, total_price, added_at)
-			VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
-		`, productID, quantity, totalPrice)
-		if err != nil {
-			return fmt.Errorf("failed to add product to cart: %v", err)
-		}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	return nil
+	return label.Warnings, nil
 }
 
-func (pdb *PostgresDatabase) GetAllCartItems(ctx context.Context) ([]CartItem, error) {
-	query := `SELECT ci.cart_item_id, ci.product_id, ci.quantity, ci.added_at, ci.status,
+func (pdb *PostgresDatabase) GetAllCartItems(ctx context.Context, userID string) ([]CartItem, error) {
+	query := `SELECT ci.cart_item_id, ci.product_id, ci.variant_id, v.sku, v.name, ci.quantity, ci.added_at, ci.status,
                       p.product_id, p.name, p.description, p.price, 
-                      (p.price * ci.quantity) AS total_price, 
+                      (COALESCE(v.price, p.price) * ci.quantity) AS total_price, 
                       p.product_status, p.product_recommend, p.discount, p.image_url, 
                       p.created_at, p.updated_at,
                       c.category_id, c.name AS category_name, c.description AS category_description,
                       s.seller_id, s.name AS seller_name, s.address, s.phone_number, s.email, s.description AS seller_description,
-                      i.quantity AS inventory_quantity, i.updated_at AS inventory_updated_at
+                      i.quantity AS inventory_quantity, i.updated_at AS inventory_updated_at,
+                      r.expires_at AS reserved_until
                FROM cart_items ci
                JOIN products p ON ci.product_id = p.product_id
+               JOIN product_variants v ON ci.variant_id = v.variant_id
                LEFT JOIN categories c ON p.category_id = c.category_id
                LEFT JOIN sellers s ON p.seller_id = s.seller_id
-               LEFT JOIN inventory i ON p.product_id = i.product_id
-               WHERE ci.added_to_cart = FALSE
+               LEFT JOIN inventory i ON ci.variant_id = i.variant_id
+               LEFT JOIN stock_reservations r ON r.cart_item_id = ci.cart_item_id AND r.expires_at > CURRENT_TIMESTAMP
+               WHERE ci.user_id = $1 AND ci.added_to_cart = FALSE
                ORDER BY ci.cart_item_id
 `
-	rows, err := pdb.db.QueryContext(ctx, query)
+	rows, err := pdb.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -614,13 +588,15 @@ func (pdb *PostgresDatabase) GetAllCartItems(ctx context.Context) ([]CartItem, e
 
 		// Scan data from the database
 		err := rows.Scan(
-			&cartItem.CartItemID, &cartItem.ProductID, &cartItem.Quantity, &cartItem.AddedAt, &cartItem.Status,
+			&cartItem.CartItemID, &cartItem.ProductID, &cartItem.VariantID, &cartItem.SKU, &cartItem.VariantName,
+			&cartItem.Quantity, &cartItem.AddedAt, &cartItem.Status,
 			&productItem.ID, &productItem.Name, &productItem.Description, &productItem.Price, &cartItem.TotalPrice,
 			&productItem.ProductStatus, &productItem.ProductRecommend, &productItem.Discount, &productItem.Image,
 			&productItem.CreatedAt, &productItem.UpdatedAt,
 			&category.ID, &category.Name, &category.Description,
 			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber, &seller.Email, &seller.Description,
 			&inventory.Quantity, &inventory.UpdatedAt,
+			&cartItem.ReservedUntil,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
@@ -646,47 +622,89 @@ func (pdb *PostgresDatabase) GetAllCartItems(ctx context.Context) ([]CartItem, e
 	return cartItems, nil
 }
 
-func (pdb *PostgresDatabase) UpdateCartItemQuantity(ctx context.Context, cartItemID string, quantity int) error {
-	// ตรวจสอบว่ามีรายการสินค้านี้ในตะกร้าหรือไม่
-	var existsInCart bool
-	err := pdb.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM cart_items WHERE cart_item_id = $1)`, cartItemID).Scan(&existsInCart)
+// UpdateCartItemQuantity แก้จำนวนสินค้าในตะกร้าและปรับการจองให้ตรงกัน
+// หากจำนวนที่ต้องการเกินกว่าที่ซื้อได้จะใช้จำนวนที่ซื้อได้มากที่สุดแทน
+func (pdb *PostgresDatabase) UpdateCartItemQuantity(ctx context.Context, userID, cartItemID string, quantity int) error {
+	tx, err := pdb.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to check if cart item exists: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 
-	if !existsInCart {
+	// ตรวจสอบว่ามีรายการสินค้านี้ในตะกร้าของผู้ใช้หรือไม่ และล็อกไว้จนจบ transaction
+	var itemID, productID, variantID int
+	var name, sku string
+	var price float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT ci.cart_item_id, ci.product_id, ci.variant_id, p.name, v.sku, COALESCE(v.price, p.price)
+		FROM cart_items ci
+		JOIN products p ON p.product_id = ci.product_id
+		JOIN product_variants v ON v.variant_id = ci.variant_id
+		WHERE ci.cart_item_id = $1 AND ci.user_id = $2 AND ci.added_to_cart = FALSE
+		FOR UPDATE OF ci`, cartItemID, userID).Scan(&itemID, &productID, &variantID, &name, &sku, &price)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
 		return fmt.Errorf("cart item with ID '%s' not found", cartItemID)
+	} else if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check if cart item exists: %v", err)
 	}
 
-	// ตรวจสอบสินค้าคงเหลือในตาราง inventory
-	var stockQuantity int
-	var price float64
-	err = pdb.db.QueryRowContext(ctx, `SELECT quantity, price FROM inventory i JOIN products p ON p.product_id = i.product_id WHERE i.product_id = (SELECT product_id FROM cart_items WHERE cart_item_id = $1)`, cartItemID).Scan(&stockQuantity, &price)
+	// ตรวจสอบจำนวนที่ซื้อได้ (สต็อกหักการจองของผู้ใช้อื่น)
+	available, err := inventory.LockAvailable(ctx, tx, variantID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to check stock and price for product in inventory: %v", err)
+		tx.Rollback()
+		return err
+	}
+	if available == 0 {
+		tx.Rollback()
+		return &inventory.InsufficientStockError{Items: []inventory.Shortage{{
+			ProductID: productID,
+			VariantID: variantID,
+			SKU:       sku,
+			Name:      name,
+			Requested: quantity,
+			Available: available,
+		}}}
 	}
 
-	// หากจำนวนที่ต้องการอัปเดตมากกว่าสินค้าคงเหลือให้ใช้จำนวนสินค้าคงเหลือมากที่สุด
-	if quantity > stockQuantity {
-		quantity = stockQuantity
+	// หากจำนวนที่ต้องการอัปเดตมากกว่าสินค้าที่ซื้อได้ให้ใช้จำนวนที่ซื้อได้มากที่สุด
+	if quantity > available {
+		quantity = available
 	}
 
 	// คำนวณราคาใหม่
 	totalPrice := price * float64(quantity)
 
 	// อัปเดตจำนวนสินค้าและราคาสินค้าในตะกร้า
-	_, err = pdb.db.ExecContext(ctx, `UPDATE cart_items SET quantity = $1, total_price = $2 WHERE cart_item_id = $3`, quantity, totalPrice, cartItemID)
+	_, err = tx.ExecContext(ctx, `UPDATE cart_items SET quantity = $1, total_price = $2 WHERE cart_item_id = $3 AND user_id = $4`, quantity, totalPrice, itemID, userID)
 	if err != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to update cart item quantity and price: %v", err)
 	}
 
+	err = inventory.Reserve(ctx, tx, inventory.Reservation{
+		CartItemID: itemID,
+		UserID:     userID,
+		ProductID:  productID,
+		VariantID:  variantID,
+		Quantity:   quantity,
+	}, pdb.reservationTTL)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
 	return nil
 }
 
-func (pdb *PostgresDatabase) DeleteCartItem(ctx context.Context, cartItemID string) error {
-	// ตรวจสอบการมีอยู่ของรายการในตะกร้าก่อนลบ
+func (pdb *PostgresDatabase) DeleteCartItem(ctx context.Context, userID, cartItemID string) error {
+	// ตรวจสอบการมีอยู่ของรายการในตะกร้าของผู้ใช้ก่อนลบ
 	var existsInCart bool
-	err := pdb.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM cart_items WHERE cart_item_id = $1 )`, cartItemID).Scan(&existsInCart)
+	err := pdb.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM cart_items WHERE cart_item_id = $1 AND user_id = $2 AND added_to_cart = FALSE)`, cartItemID, userID).Scan(&existsInCart)
 	if err != nil {
 		return fmt.Errorf("failed to check if cart item exists: %v", err)
 	}
@@ -696,8 +714,8 @@ func (pdb *PostgresDatabase) DeleteCartItem(ctx context.Context, cartItemID stri
 		return fmt.Errorf("cart item with ID '%s' not found for deletion", cartItemID)
 	}
 
-	// ลบสินค้าจากตะกร้าโดยใช้ cart_item_id
-	_, err = pdb.db.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_item_id = $1`, cartItemID)
+	// ลบสินค้าจากตะกร้าโดยใช้ cart_item_id (การจองของรายการจะถูกลบตาม foreign key)
+	_, err = pdb.db.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_item_id = $1 AND user_id = $2`, cartItemID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete cart item: %v", err)
 	}
@@ -705,6 +723,24 @@ func (pdb *PostgresDatabase) DeleteCartItem(ctx context.Context, cartItemID stri
 	return nil
 }
 
+// BeginTx, QueryContext, QueryRowContext และ ExecContext เปิดให้ subsystem อื่น (เช่น inventory)
+// ใช้การเชื่อมต่อเดียวกัน และยังใช้ได้หลัง Reconnect เปลี่ยน *sql.DB ภายใน
+func (pdb *PostgresDatabase) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return pdb.db.BeginTx(ctx, opts)
+}
+
+func (pdb *PostgresDatabase) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return pdb.db.QueryContext(ctx, query, args...)
+}
+
+func (pdb *PostgresDatabase) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return pdb.db.QueryRowContext(ctx, query, args...)
+}
+
+func (pdb *PostgresDatabase) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return pdb.db.ExecContext(ctx, query, args...)
+}
+
 func (pdb *PostgresDatabase) Close() error {
 	return pdb.db.Close()
 }
@@ -746,7 +782,7 @@ func (pdb *PostgresDatabase) GetUserByID(ctx context.Context, userID string) (*U
 
 	// คำสั่ง SQL ที่ดึงข้อมูลผู้ใช้จากฐานข้อมูล
 	err := pdb.db.QueryRowContext(ctx, `
-        SELECT user_id, google_id, email, display_name, address, phone, profile_picture_url,
+        SELECT user_id, google_id, email, COALESCE(display_name, ''), address, phone, COALESCE(profile_picture_url, ''),
                email_verified, last_login_at, status, role, created_at, updated_at
         FROM users
         WHERE user_id = $1
@@ -770,81 +806,167 @@ func (pdb *PostgresDatabase) GetUserByID(ctx context.Context, userID string) (*U
 	return &user, nil
 }
 
-func (pdb *PostgresDatabase) CreateOrder(ctx context.Context, cartItems []CartItem, totalAmount float64) (int, error) {
+// orderLine เก็บข้อมูลของแต่ละรายการ ณ เวลาสั่งซื้อ เพื่อใช้บันทึกลง order_items
+type orderLine struct {
+	cartItemID int
+	productID  int
+	variantID  int
+	sellerID   int
+	quantity   int
+	unitPrice  float64
+	discount   int
+	lineCents  int64
+}
+
+// CreateOrder สร้างคำสั่งซื้อจากรายการในตะกร้าของผู้ใช้ โดยคำนวณยอดรวมจากจำนวนสินค้า
+// ราคาปัจจุบันของตัวเลือก (หรือของสินค้าหากตัวเลือกไม่ได้กำหนดราคาเอง) และส่วนลดของสินค้าเอง expectedTotal คือยอดที่ผู้ใช้ยืนยัน
+// ต้องมากกว่า 0 (ไม่เช่นนั้นคืน ErrInvalidOrder) และหากไม่ตรงกับยอดที่คำนวณได้จะปฏิเสธคำสั่งซื้อด้วย ErrTotalMismatch
+// รายการที่ไม่อยู่ในตะกร้าของผู้ใช้คืน ErrCartItemNotFound รายการที่อยู่ระหว่างการเรียกคืนจะคืน ErrProductRecalled
+// คืนค่า order_id และยอดรวมที่บันทึก
+func (pdb *PostgresDatabase) CreateOrder(ctx context.Context, userID string, cartItems []CartItem, expectedTotal float64) (int, float64, error) {
 	var orderID int
 
-	// เริ่มต้น transaction
-	tx, err := pdb.db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	if len(cartItems) == 0 {
+		return 0, 0, fmt.Errorf("%w: no cart items", ErrInvalidOrder)
+	}
+	if expectedTotal <= 0 {
+		return 0, 0, fmt.Errorf("%w: total amount must be greater than 0", ErrInvalidOrder)
 	}
 
-	// คำสั่ง SQL สำหรับการสร้างคำสั่งซื้อ
-	stmt := `INSERT INTO orders (total_amount) 
-          VALUES ($1) RETURNING order_id`
-	err = tx.QueryRowContext(ctx, stmt, totalAmount).Scan(&orderID)
+	// เริ่มต้น transaction
+	tx, err := pdb.db.BeginTx(ctx, nil)
 	if err != nil {
-		tx.Rollback()
-		return 0, fmt.Errorf("failed to create order: %v", err)
+		return 0, 0, fmt.Errorf("failed to start transaction: %v", err)
 	}
 
-	// เพิ่ม CartItems ในคำสั่งซื้อ
+	// ดึงข้อมูลรายการในตะกร้าพร้อมราคาและส่วนลดปัจจุบัน แล้วคำนวณยอดรวมเป็นหน่วยสตางค์
+	lines := make([]orderLine, 0, len(cartItems))
+	seen := make(map[int]bool, len(cartItems))
+	var totalCents int64
 	for _, item := range cartItems {
 		// ตรวจสอบว่า ProductID ของแต่ละ CartItem ไม่เป็น 0 หรือค่าผิดปกติ
 		if item.ProductID <= 0 {
 			tx.Rollback()
-			return 0, fmt.Errorf("invalid product ID %d for cart item", item.ProductID)
+			return 0, 0, fmt.Errorf("%w: invalid product ID %d for cart item", ErrInvalidOrder, item.ProductID)
 		}
-
-		// ดึงข้อมูล product_id จาก cart_items
-		var productID int
-		err := tx.QueryRowContext(ctx, `SELECT product_id FROM cart_items WHERE cart_item_id = $1`, item.CartItemID).Scan(&productID)
-		if err != nil {
+		if seen[item.CartItemID] {
 			tx.Rollback()
-			return 0, fmt.Errorf("failed to fetch product_id for cart_item_id %d: %v", item.CartItemID, err)
+			return 0, 0, fmt.Errorf("%w: duplicate cart item %d", ErrInvalidOrder, item.CartItemID)
 		}
-
-		// ดึงข้อมูล seller_id ของสินค้า
-		var productSellerID int
-		err = tx.QueryRowContext(ctx, `SELECT seller_id FROM products WHERE product_id = $1`, productID).Scan(&productSellerID)
+		seen[item.CartItemID] = true
+
+		// ดึงข้อมูลเฉพาะรายการในตะกร้าของผู้ใช้ที่ยังไม่ได้สั่งซื้อ
+		line := orderLine{cartItemID: item.CartItemID}
+		var netPrice float64
+		var sku string
+		var recalled bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT ci.product_id, ci.variant_id, ci.quantity, p.seller_id, COALESCE(v.price, p.price), COALESCE(p.discount, 0),
+			       ROUND(COALESCE(v.price, p.price) * (100 - COALESCE(p.discount, 0)) / 100.0, 2) AS net_price,
+			       v.sku, `+variantRecalled+`
+			FROM cart_items ci
+			JOIN products p ON p.product_id = ci.product_id
+			JOIN product_variants v ON v.variant_id = ci.variant_id
+			WHERE ci.cart_item_id = $1 AND ci.user_id = $2 AND ci.added_to_cart = FALSE
+			FOR UPDATE OF ci`, item.CartItemID, userID).Scan(
+			&line.productID, &line.variantID, &line.quantity, &line.sellerID, &line.unitPrice, &line.discount, &netPrice,
+			&sku, &recalled,
+		)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
-			return 0, fmt.Errorf("no seller found for product_id %d", productID)
+			return 0, 0, fmt.Errorf("%w: cart item %d is not in your cart", ErrCartItemNotFound, item.CartItemID)
 		} else if err != nil {
 			tx.Rollback()
-			return 0, fmt.Errorf("failed to fetch seller ID for product %d: %v", productID, err)
+			return 0, 0, fmt.Errorf("failed to fetch cart item %d: %v", item.CartItemID, err)
+		}
+		// สินค้าที่ถูกเรียกคืนหลังจากเพิ่มลงตะกร้าแล้วสั่งซื้อไม่ได้
+		if recalled {
+			tx.Rollback()
+			return 0, 0, fmt.Errorf("%w: cart item %d (%s)", ErrProductRecalled, item.CartItemID, sku)
+		}
+
+		line.lineCents = int64(math.Round(netPrice*100)) * int64(line.quantity)
+		totalCents += line.lineCents
+		lines = append(lines, line)
+	}
+
+	totalAmount := float64(totalCents) / 100
+
+	// ปฏิเสธคำสั่งซื้อหากยอดที่ client คาดไว้ไม่ตรงกับยอดที่คำนวณได้
+	if int64(math.Round(expectedTotal*100)) != totalCents {
+		tx.Rollback()
+		return 0, totalAmount, fmt.Errorf("%w: expected %.2f, got %.2f", ErrTotalMismatch, expectedTotal, totalAmount)
+	}
+
+	// คำสั่ง SQL สำหรับการสร้างคำสั่งซื้อ
+	stmt := `INSERT INTO orders (user_id, total_amount) 
+          VALUES ($1, $2) RETURNING order_id`
+	err = tx.QueryRowContext(ctx, stmt, userID, totalAmount).Scan(&orderID)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to create order: %v", err)
+	}
+
+	// ล็อกและตัดสต็อกของทุกรายการภายใน transaction เดียวกัน
+	// สินค้าที่ผู้ใช้คนนี้จองไว้เองนับเป็นของที่ซื้อได้
+	requested := make(map[int]int)
+	for _, line := range lines {
+		requested[line.variantID] += line.quantity
+	}
+	if err := inventory.Decrement(ctx, tx, userID, requested, fmt.Sprintf("order:%d", orderID)); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	// เพิ่ม CartItems ในคำสั่งซื้อพร้อมบันทึกราคาและส่วนลด ณ เวลาสั่งซื้อ
+	for _, line := range lines {
+		lineTotal := float64(line.lineCents) / 100
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO order_items (order_id, cart_item_id, seller_id, product_id, variant_id, quantity, unit_price, discount, line_total)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			orderID, line.cartItemID, line.sellerID, line.productID, line.variantID, line.quantity, line.unitPrice, line.discount, lineTotal)
+		if err != nil {
+			tx.Rollback()
+			return 0, 0, fmt.Errorf("failed to add cart item to order: %v", err)
 		}
 
-		// คำสั่ง SQL สำหรับการเพิ่ม CartItem ลงใน order_items
-		_, err = tx.ExecContext(ctx, `INSERT INTO order_items (order_id, cart_item_id, seller_id) 
-                                    VALUES ($1, $2, $3)`, orderID, item.CartItemID, productSellerID)
+		// สินค้าถูกตัดสต็อกแล้ว ไม่ต้องจองไว้อีก
+		if err := inventory.ReleaseCartItem(ctx, tx, line.cartItemID); err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+
+		// อัปเดตสถานะ added_to_cart และยอดของรายการให้ตรงกับที่สั่งซื้อ
+		_, err = tx.ExecContext(ctx, `
+			UPDATE cart_items SET added_to_cart = TRUE, total_price = $1
+			WHERE cart_item_id = $2 AND user_id = $3`, lineTotal, line.cartItemID, userID)
 		if err != nil {
 			tx.Rollback()
-			return 0, fmt.Errorf("failed to add cart item to order: %v", err)
+			return 0, 0, fmt.Errorf("failed to update cart items: %v", err)
 		}
 	}
 
-	// คำสั่ง SQL สำหรับการอัปเดตสถานะ added_to_cart
-	_, err = tx.ExecContext(ctx, `UPDATE cart_items SET added_to_cart = TRUE WHERE added_to_cart = FALSE`)
-	if err != nil {
+	// สร้างการจัดส่งของแต่ละร้านในคำสั่งซื้อ
+	if err := createShipments(ctx, tx, orderID, userID); err != nil {
 		tx.Rollback()
-		return 0, fmt.Errorf("failed to update cart items: %v", err)
+		return 0, 0, err
 	}
 
 	// ยืนยันการทำธุรกรรม
 	err = tx.Commit()
 	if err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+		return 0, 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	return orderID, nil
+	return orderID, totalAmount, nil
 }
 
-func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
-	query := `
+// orderSelect คอลัมน์ของคำสั่งซื้อและรายการตามลำดับที่ queryOrders อ่าน (หนึ่งแถวต่อหนึ่งรายการ)
+const orderSelect = `
         SELECT 
-            o.order_id, o.total_amount, o.order_date, 
-            COALESCE(ci.cart_item_id, 0) AS cart_item_id, ci.product_id, ci.quantity, ci.total_price, ci.added_at, ci.status,
+            o.order_id, COALESCE(o.user_id::text, ''), o.total_amount, o.order_date, 
+            COALESCE(ci.cart_item_id, 0) AS cart_item_id, oi.product_id, oi.variant_id, COALESCE(v.sku, ''), COALESCE(v.name, ''), oi.quantity, oi.line_total, oi.unit_price, oi.discount, ci.added_at, ci.status,
             p.product_id, p.name AS product_name, p.description AS product_description, 
             p.price, p.product_status, p.product_recommend, p.discount, p.image_url, 
             c.category_id, COALESCE(c.name, 'No Category') AS category_name,
@@ -855,20 +977,26 @@ func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
         FROM orders o
         LEFT JOIN order_items oi ON o.order_id = oi.order_id
         LEFT JOIN cart_items ci ON oi.cart_item_id = ci.cart_item_id
-        LEFT JOIN products p ON ci.product_id = p.product_id
+        LEFT JOIN products p ON oi.product_id = p.product_id
+        LEFT JOIN product_variants v ON oi.variant_id = v.variant_id
         LEFT JOIN categories c ON p.category_id = c.category_id
         LEFT JOIN sellers s ON p.seller_id = s.seller_id
-        LEFT JOIN inventory i ON p.product_id = i.product_id
-        ORDER BY o.order_id DESC, ci.cart_item_id DESC;
+        LEFT JOIN inventory i ON oi.variant_id = i.variant_id
     `
 
-	rows, err := pdb.db.QueryContext(ctx, query)
+// queryOrders ดึงคำสั่งซื้อตามเงื่อนไข where (เช่น "WHERE o.user_id = $1") แล้วรวมรายการของแต่ละคำสั่งซื้อ
+// เรียงจากคำสั่งซื้อล่าสุด
+func (pdb *PostgresDatabase) queryOrders(ctx context.Context, where string, args ...interface{}) ([]Order, error) {
+	query := orderSelect + where + ` ORDER BY o.order_id DESC, ci.cart_item_id DESC;`
+
+	rows, err := pdb.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch orders: %w", err)
 	}
 	defer rows.Close()
 
 	ordersMap := make(map[int]*Order)
+	var orderIDs []int
 	for rows.Next() {
 		var orderID int
 		var order Order
@@ -878,8 +1006,8 @@ func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
 		var seller Seller
 
 		err := rows.Scan(
-			&orderID, &order.TotalAmount, &order.OrderDate,
-			&cartItem.CartItemID, &cartItem.ProductID, &cartItem.Quantity, &cartItem.TotalPrice, &cartItem.AddedAt, &cartItem.Status,
+			&orderID, &order.UserID, &order.TotalAmount, &order.OrderDate,
+			&cartItem.CartItemID, &cartItem.ProductID, &cartItem.VariantID, &cartItem.SKU, &cartItem.VariantName, &cartItem.Quantity, &cartItem.TotalPrice, &cartItem.UnitPrice, &cartItem.Discount, &cartItem.AddedAt, &cartItem.Status,
 			&productItem.ID, &productItem.Name, &productItem.Description, &productItem.Price,
 			&productItem.ProductStatus, &productItem.ProductRecommend, &productItem.Discount, &productItem.Image,
 			&category.ID, &category.Name,
@@ -890,15 +1018,6 @@ func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		// ตรวจสอบการใช้ Null หรือค่าที่อาจเป็น NULL
-		if cartItem.CartItemID == 0 {
-			cartItem.CartItemID = 0 // หรือสามารถปรับให้เป็นค่า default อื่นๆ ตามต้องการ
-		}
-
-		if productItem.ID == 0 {
-			productItem.ID = 0 // หรือค่า default
-		}
-
 		// สร้าง ProductItem และเพิ่มข้อมูล
 		productItem.Categories = category
 		productItem.Seller = seller
@@ -915,6 +1034,7 @@ func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
 			order.OrderID = orderID
 			order.CartItems = []CartItem{cartItem}
 			ordersMap[orderID] = &order
+			orderIDs = append(orderIDs, orderID)
 		}
 	}
 
@@ -923,29 +1043,42 @@ func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	// Convert map to slice
-	var orders []Order
-	for _, order := range ordersMap {
-		orders = append(orders, *order)
+	// เรียงตามลำดับที่ได้จากฐานข้อมูล (คำสั่งซื้อล่าสุดก่อน)
+	orders := []Order{}
+	for _, orderID := range orderIDs {
+		orders = append(orders, *ordersMap[orderID])
 	}
 
 	return orders, nil
 }
 
-func (pdb *PostgresDatabase) UpdateCartItemStatus(ctx context.Context, orderID int, sellerID int, status string) error {
-	// อัปเดตสถานะในตาราง cart_items โดยอ้างอิงจาก order_id และ seller_id
-	_, err := pdb.db.ExecContext(ctx, `
-        UPDATE cart_items 
-        SET status = $1
-        WHERE cart_item_id IN (
-            SELECT cart_item_id 
-            FROM order_items 
-            WHERE order_id = $2 AND seller_id = $3
-        )`, status, orderID, sellerID)
+// GetOrders คืนคำสั่งซื้อทั้งหมดในระบบ (สำหรับผู้ดูแลระบบ)
+func (pdb *PostgresDatabase) GetOrders(ctx context.Context) ([]Order, error) {
+	return pdb.queryOrders(ctx, "")
+}
+
+// GetUserOrders คืนคำสั่งซื้อของผู้ซื้อ userID เรียงจากล่าสุด
+func (pdb *PostgresDatabase) GetUserOrders(ctx context.Context, userID string) ([]Order, error) {
+	return pdb.queryOrders(ctx, `WHERE o.user_id = $1`, userID)
+}
+
+// GetUserOrder คืนคำสั่งซื้อหนึ่งรายการของผู้ซื้อ userID พร้อมการจัดส่งของแต่ละร้าน
+// คืน ErrOrderNotFound เมื่อไม่พบหรือเป็นคำสั่งซื้อของผู้อื่น
+func (pdb *PostgresDatabase) GetUserOrder(ctx context.Context, userID string, orderID int) (Order, error) {
+	orders, err := pdb.queryOrders(ctx, `WHERE o.user_id = $1 AND o.order_id = $2`, userID, orderID)
 	if err != nil {
-		return fmt.Errorf("failed to update cart item status: %v", err)
+		return Order{}, err
 	}
-	return nil
+	if len(orders) == 0 {
+		return Order{}, ErrOrderNotFound
+	}
+
+	order := orders[0]
+	order.Shipments, err = pdb.GetShipments(ctx, orderID)
+	if err != nil {
+		return Order{}, err
+	}
+	return order, nil
 }
 
 func (pdb *PostgresDatabase) UpdateUserContact(ctx context.Context, userID string, displayName, address, phone string) error {
@@ -965,113 +1098,13 @@ func (pdb *PostgresDatabase) UpdateUserContact(ctx context.Context, userID strin
 	return nil
 }
 
+// GetOrdersSort คืนคำสั่งซื้อทั้งหมดเฉพาะรายการที่มีสถานะ status (สำหรับผู้ดูแลระบบ)
 func (pdb *PostgresDatabase) GetOrdersSort(ctx context.Context, status string) ([]Order, error) {
-	query := `
-        SELECT 
-            o.order_id, o.total_amount, o.order_date, 
-            COALESCE(ci.cart_item_id, 0) AS cart_item_id, ci.product_id, ci.quantity, ci.total_price, ci.added_at, ci.status,
-            p.product_id, p.name AS product_name, p.description AS product_description, 
-            p.price, p.product_status, p.product_recommend, p.discount, p.image_url, 
-            c.category_id, COALESCE(c.name, 'No Category') AS category_name,
-            s.seller_id, COALESCE(s.name, 'Unknown Seller') AS seller_name,
-            COALESCE(s.address, 'No Address') AS seller_address,
-            COALESCE(s.phone_number, 'No Phone') AS seller_phone,
-            COALESCE(i.quantity, 0) AS inventory_quantity
-        FROM orders o
-        LEFT JOIN order_items oi ON o.order_id = oi.order_id
-        LEFT JOIN cart_items ci ON oi.cart_item_id = ci.cart_item_id
-        LEFT JOIN products p ON ci.product_id = p.product_id
-        LEFT JOIN categories c ON p.category_id = c.category_id
-        LEFT JOIN sellers s ON p.seller_id = s.seller_id
-        LEFT JOIN inventory i ON p.product_id = i.product_id
-    `
-
 	// กรองคำสั่งซื้อที่มีสถานะที่ตรงกับที่ผู้ใช้ระบุ
-	if status != "" {
-		query += ` WHERE ci.status = $1`
-	}
-
-	// เพิ่มการจัดเรียงผลลัพธ์
-	query += ` ORDER BY o.order_id DESC, ci.cart_item_id DESC;`
-
-	// เรียกใช้คำสั่ง SQL พร้อมค่าพารามิเตอร์
-	rows, err := pdb.db.QueryContext(ctx, query, status)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch orders: %w", err)
-	}
-	defer rows.Close()
-
-	ordersMap := make(map[int]*Order)
-	for rows.Next() {
-		var orderID int
-		var order Order
-		var cartItem CartItem
-		var productItem ProductItem
-		var category Category
-		var seller Seller
-
-		err := rows.Scan(
-			&orderID, &order.TotalAmount, &order.OrderDate,
-			&cartItem.CartItemID, &cartItem.ProductID, &cartItem.Quantity, &cartItem.TotalPrice, &cartItem.AddedAt, &cartItem.Status,
-			&productItem.ID, &productItem.Name, &productItem.Description, &productItem.Price,
-			&productItem.ProductStatus, &productItem.ProductRecommend, &productItem.Discount, &productItem.Image,
-			&category.ID, &category.Name,
-			&seller.ID, &seller.Name, &seller.Address, &seller.PhoneNumber,
-			&productItem.Inventory.Quantity,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		// สร้าง ProductItem และเพิ่มข้อมูล
-		productItem.Categories = category
-		productItem.Seller = seller
-
-		// เพิ่ม ProductItem ใน CartItem
-		cartItem.Product = []ProductItem{productItem}
-
-		// ตรวจสอบว่า Order มีอยู่ใน Map หรือยัง
-		if existingOrder, exists := ordersMap[orderID]; exists {
-			// ถ้ามี order นี้อยู่แล้ว ให้เพิ่ม cartItem ใหม่ใน CartItems
-			existingOrder.CartItems = append(existingOrder.CartItems, cartItem)
-		} else {
-			// ถ้ายังไม่มี ให้สร้าง Order ใหม่และเพิ่ม CartItems ใหม่
-			order.OrderID = orderID
-			order.CartItems = []CartItem{cartItem}
-			ordersMap[orderID] = &order
-		}
+	if status == "" {
+		return pdb.queryOrders(ctx, "")
 	}
-
-	// ตรวจสอบข้อผิดพลาดขณะ iterate rows
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	var orders []Order
-	for _, order := range ordersMap {
-		orders = append(orders, *order)
-	}
-
-	return orders, nil
-}
-
-func (pdb *PostgresDatabase) GetCurrentCartItemStatus(ctx context.Context, orderID int, sellerID int) (string, error) {
-	var currentStatus string
-	err := pdb.db.QueryRowContext(ctx, `
-        SELECT ci.status
-        FROM cart_items ci
-        JOIN order_items oi ON ci.cart_item_id = oi.cart_item_id
-        WHERE oi.order_id = $1 AND oi.seller_id = $2
-        LIMIT 1`, orderID, sellerID).Scan(&currentStatus)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("no cart items found for the given order and seller")
-		}
-		return "", fmt.Errorf("failed to retrieve cart item status: %v", err)
-	}
-
-	return currentStatus, nil
+	return pdb.queryOrders(ctx, `WHERE ci.status = $1`, status)
 }
 
 type Store struct {
@@ -1086,70 +1119,254 @@ func (s *Store) GetProduct(ctx context.Context, id string) (ProductItem, error)
 	return s.db.GetProduct(ctx, id)
 }
 
-func (s *Store) AllProducts(ctx context.Context) ([]ProductItem, error) {
-	return s.db.AllProducts(ctx)
+func (s *Store) AllProducts(ctx context.Context, q ListingQuery) (ProductPage, error) {
+	return s.db.AllProducts(ctx, q)
 }
 
-func (s *Store) GetProductRecommend(ctx context.Context) ([]ProductItem, error) {
-	return s.db.GetProductRecommend(ctx)
+func (s *Store) ListProducts(ctx context.Context, q ListingQuery) (ProductPage, error) {
+	return s.db.ListProducts(ctx, q)
+}
+
+func (s *Store) GetProductRecommend(ctx context.Context, q ListingQuery) (ProductPage, error) {
+	return s.db.GetProductRecommend(ctx, q)
 }
 
 func (s *Store) GetNewProducts(ctx context.Context) ([]ProductItem, error) {
 	return s.db.GetNewProducts(ctx)
 }
 
-func (s *Store) SearchProducts(ctx context.Context, query string) ([]ProductItem, error) {
-	return s.db.SearchProducts(ctx, query)
+func (s *Store) SearchProducts(ctx context.Context, query string, q ListingQuery) (ProductPage, error) {
+	return s.db.SearchProducts(ctx, query, q)
+}
+
+func (s *Store) SuggestProducts(ctx context.Context, prefix string, limit int) ([]search.Suggestion, error) {
+	return s.db.SuggestProducts(ctx, prefix, limit)
 }
 
 func (s *Store) GetSeller(ctx context.Context, id string) (Seller, error) {
 	return s.db.GetSeller(ctx, id)
 }
 
-func (s *Store) GetProductByCategory(ctx context.Context, categoryID string) ([]ProductItem, error) {
-	return s.db.GetProductByCategory(ctx, categoryID)
+func (s *Store) GetProductByCategory(ctx context.Context, categoryID int, q ListingQuery) (ProductPage, error) {
+	return s.db.GetProductByCategory(ctx, categoryID, q)
 }
 
-func (s *Store) AddToCart(ctx context.Context, productID, quantity int) error {
-	return s.db.AddToCart(ctx, productID, quantity)
+func (s *Store) AddToCart(ctx context.Context, userID string, productID, variantID, quantity int, acknowledgeSafety bool) ([]safety.Hazard, error) {
+	return s.db.AddToCart(ctx, userID, productID, variantID, quantity, acknowledgeSafety)
 }
 
-func (s *Store) GetAllCartItems(ctx context.Context) ([]CartItem, error) {
-	return s.db.GetAllCartItems(ctx)
+func (s *Store) GetAllCartItems(ctx context.Context, userID string) ([]CartItem, error) {
+	return s.db.GetAllCartItems(ctx, userID)
 }
 
-func (s *Store) UpdateCartItemQuantity(ctx context.Context, cartItemID string, quantity int) error {
-	return s.db.UpdateCartItemQuantity(ctx, cartItemID, quantity)
+func (s *Store) UpdateCartItemQuantity(ctx context.Context, userID, cartItemID string, quantity int) error {
+	return s.db.UpdateCartItemQuantity(ctx, userID, cartItemID, quantity)
 }
 
-func (s *Store) DeleteCartItem(ctx context.Context, cartItemID string) error {
-	return s.db.DeleteCartItem(ctx, cartItemID)
+func (s *Store) DeleteCartItem(ctx context.Context, userID, cartItemID string) error {
+	return s.db.DeleteCartItem(ctx, userID, cartItemID)
 }
 
-func (s *Store) CreateOrder(ctx context.Context, cartItemID []CartItem, totalAmount float64) (int, error) {
-	return s.db.CreateOrder(ctx, cartItemID, totalAmount)
+func (s *Store) CreateOrder(ctx context.Context, userID string, cartItemID []CartItem, expectedTotal float64) (int, float64, error) {
+	return s.db.CreateOrder(ctx, userID, cartItemID, expectedTotal)
 }
 
 func (s *Store) GetOrders(ctx context.Context) ([]Order, error) {
 	return s.db.GetOrders(ctx)
 }
 
-func (s *Store) UpdateCartItemStatus(ctx context.Context, orderID int, sellerID int, status string) error {
-	return s.db.UpdateCartItemStatus(ctx, orderID, sellerID, status)
-}
-
 func (s *Store) GetOrdersSort(ctx context.Context, status string) ([]Order, error) {
 	return s.db.GetOrdersSort(ctx, status)
 }
 
-func (s *Store) GetCurrentCartItemStatus(ctx context.Context, orderID int, sellerID int) (string, error) {
-	return s.db.GetCurrentCartItemStatus(ctx, orderID, sellerID)
+func (s *Store) GetUserOrders(ctx context.Context, userID string) ([]Order, error) {
+	return s.db.GetUserOrders(ctx, userID)
+}
+
+func (s *Store) GetUserOrder(ctx context.Context, userID string, orderID int) (Order, error) {
+	return s.db.GetUserOrder(ctx, userID, orderID)
+}
+
+func (s *Store) GetSellerOrders(ctx context.Context, sellerID int, q SellerOrderQuery) ([]SellerOrder, error) {
+	return s.db.GetSellerOrders(ctx, sellerID, q)
 }
 
 func (s *Store) UpdateUserContact(ctx context.Context, userID string, displayName, address, phone string) error {
 	return s.db.UpdateUserContact(ctx, userID, displayName, address, phone)
 }
 
+func (s *Store) CreateProduct(ctx context.Context, p NewProduct) (ProductItem, error) {
+	return s.db.CreateProduct(ctx, p)
+}
+
+func (s *Store) UpdateProduct(ctx context.Context, productID int, u UpdateProduct, userID string) (ProductItem, error) {
+	return s.db.UpdateProduct(ctx, productID, u, userID)
+}
+
+func (s *Store) DeleteProduct(ctx context.Context, productID int) error {
+	return s.db.DeleteProduct(ctx, productID)
+}
+
+func (s *Store) GetProductSellerID(ctx context.Context, productID int) (int, error) {
+	return s.db.GetProductSellerID(ctx, productID)
+}
+
+func (s *Store) GetSellerIDByUserID(ctx context.Context, userID string) (int, error) {
+	return s.db.GetSellerIDByUserID(ctx, userID)
+}
+
+func (s *Store) RegisterSeller(ctx context.Context, userID string, seller NewSeller) (Seller, error) {
+	return s.db.RegisterSeller(ctx, userID, seller)
+}
+
+func (s *Store) UpdateSeller(ctx context.Context, sellerID int, u UpdateSeller) (Seller, error) {
+	return s.db.UpdateSeller(ctx, sellerID, u)
+}
+
+func (s *Store) DeactivateSeller(ctx context.Context, sellerID int) error {
+	return s.db.DeactivateSeller(ctx, sellerID)
+}
+
+func (s *Store) GetCategoryTree(ctx context.Context) ([]CategoryNode, error) {
+	return s.db.GetCategoryTree(ctx)
+}
+
+func (s *Store) CreateCategory(ctx context.Context, c NewCategory) (Category, error) {
+	return s.db.CreateCategory(ctx, c)
+}
+
+func (s *Store) UpdateCategory(ctx context.Context, categoryID int, u UpdateCategory) (Category, error) {
+	return s.db.UpdateCategory(ctx, categoryID, u)
+}
+
+func (s *Store) DeleteCategory(ctx context.Context, categoryID int) error {
+	return s.db.DeleteCategory(ctx, categoryID)
+}
+
+func (s *Store) GetVariants(ctx context.Context, productID int) ([]Variant, error) {
+	return s.db.GetVariants(ctx, productID)
+}
+
+func (s *Store) CreateVariant(ctx context.Context, productID int, v NewVariant, userID string) (Variant, error) {
+	return s.db.CreateVariant(ctx, productID, v, userID)
+}
+
+func (s *Store) UpdateVariant(ctx context.Context, productID, variantID int, u UpdateVariant, userID string) (Variant, error) {
+	return s.db.UpdateVariant(ctx, productID, variantID, u, userID)
+}
+
+func (s *Store) DeleteVariant(ctx context.Context, productID, variantID int, userID string) error {
+	return s.db.DeleteVariant(ctx, productID, variantID, userID)
+}
+
+func (s *Store) GetProductImages(ctx context.Context, productID int) ([]ProductImage, error) {
+	return s.db.GetProductImages(ctx, productID)
+}
+
+func (s *Store) AddProductImage(ctx context.Context, productID int, img NewProductImage) (ProductImage, error) {
+	return s.db.AddProductImage(ctx, productID, img)
+}
+
+func (s *Store) UpdateProductImage(ctx context.Context, productID, imageID int, u UpdateProductImage) (ProductImage, error) {
+	return s.db.UpdateProductImage(ctx, productID, imageID, u)
+}
+
+func (s *Store) ReorderProductImages(ctx context.Context, productID int, imageIDs []int) ([]ProductImage, error) {
+	return s.db.ReorderProductImages(ctx, productID, imageIDs)
+}
+
+func (s *Store) DeleteProductImage(ctx context.Context, productID, imageID int) (ProductImage, error) {
+	return s.db.DeleteProductImage(ctx, productID, imageID)
+}
+
+func (s *Store) GetChildProfiles(ctx context.Context, userID string) ([]ChildProfile, error) {
+	return s.db.GetChildProfiles(ctx, userID)
+}
+
+func (s *Store) CreateChildProfile(ctx context.Context, userID string, c NewChildProfile) (ChildProfile, error) {
+	return s.db.CreateChildProfile(ctx, userID, c)
+}
+
+func (s *Store) UpdateChildProfile(ctx context.Context, userID string, childID int, u UpdateChildProfile) (ChildProfile, error) {
+	return s.db.UpdateChildProfile(ctx, userID, childID, u)
+}
+
+func (s *Store) DeleteChildProfile(ctx context.Context, userID string, childID int) error {
+	return s.db.DeleteChildProfile(ctx, userID, childID)
+}
+
+func (s *Store) RecommendForChild(ctx context.Context, userID string, childID int, q ListingQuery) (ChildRecommendations, error) {
+	return s.db.RecommendForChild(ctx, userID, childID, q)
+}
+
+func (s *Store) CreateRecall(ctx context.Context, r NewRecall, userID string) (Recall, error) {
+	return s.db.CreateRecall(ctx, r, userID)
+}
+
+func (s *Store) GetRecalls(ctx context.Context, status string) ([]Recall, error) {
+	return s.db.GetRecalls(ctx, status)
+}
+
+func (s *Store) GetRecall(ctx context.Context, recallID int) (Recall, error) {
+	return s.db.GetRecall(ctx, recallID)
+}
+
+func (s *Store) CloseRecall(ctx context.Context, recallID int) (Recall, error) {
+	return s.db.CloseRecall(ctx, recallID)
+}
+
+func (s *Store) GetRecallReport(ctx context.Context, recallID int) (RecallReport, error) {
+	return s.db.GetRecallReport(ctx, recallID)
+}
+
+func (s *Store) GetNotifications(ctx context.Context, userID string, unreadOnly bool) ([]Notification, error) {
+	return s.db.GetNotifications(ctx, userID, unreadOnly)
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, userID string, notificationID int64) (Notification, error) {
+	return s.db.MarkNotificationRead(ctx, userID, notificationID)
+}
+
+func (s *Store) GetShipments(ctx context.Context, orderID int) ([]Shipment, error) {
+	return s.db.GetShipments(ctx, orderID)
+}
+
+func (s *Store) GetShipment(ctx context.Context, shipmentID int) (Shipment, error) {
+	return s.db.GetShipment(ctx, shipmentID)
+}
+
+func (s *Store) GetSellerShipment(ctx context.Context, orderID, sellerID int) (Shipment, error) {
+	return s.db.GetSellerShipment(ctx, orderID, sellerID)
+}
+
+func (s *Store) TransitionShipment(ctx context.Context, shipmentID int, t ShipmentTransition, actor, userID string) (Shipment, error) {
+	return s.db.TransitionShipment(ctx, shipmentID, t, actor, userID)
+}
+
+func (s *Store) CancelOrder(ctx context.Context, orderID, sellerID int, reason, actor, userID string) (CancelledOrder, error) {
+	return s.db.CancelOrder(ctx, orderID, sellerID, reason, actor, userID)
+}
+
+func (s *Store) CreateReturn(ctx context.Context, userID string, r NewReturn) (ReturnRequest, error) {
+	return s.db.CreateReturn(ctx, userID, r)
+}
+
+func (s *Store) GetReturns(ctx context.Context, filter ReturnFilter) ([]ReturnRequest, error) {
+	return s.db.GetReturns(ctx, filter)
+}
+
+func (s *Store) GetReturn(ctx context.Context, returnID int) (ReturnRequest, error) {
+	return s.db.GetReturn(ctx, returnID)
+}
+
+func (s *Store) ApproveReturn(ctx context.Context, returnID int, d ReturnDecision, actor, userID string) (ReturnRequest, error) {
+	return s.db.ApproveReturn(ctx, returnID, d, actor, userID)
+}
+
+func (s *Store) RejectReturn(ctx context.Context, returnID int, d ReturnDecision, userID string) (ReturnRequest, error) {
+	return s.db.RejectReturn(ctx, returnID, d, userID)
+}
+
 func (s *Store) Close() error {
 	return s.db.Close()
 }
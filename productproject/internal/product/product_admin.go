@@ -0,0 +1,399 @@
+// product_admin.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"productproject/internal/safety"
+)
+
+// ErrInvalidProduct ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลสินค้าที่จะสร้างหรือแก้ไม่ผ่านการตรวจสอบ
+var ErrInvalidProduct = errors.New("invalid product")
+
+// ErrProductNotFound ถูกส่งคืนเมื่อไม่พบสินค้า (หรือสินค้าถูกลบไปแล้ว)
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrSellerNotLinked ถูกส่งคืนเมื่อบัญชีผู้ใช้ยังไม่ได้ผูกกับร้านค้าที่เปิดอยู่
+var ErrSellerNotLinked = errors.New("user is not linked to an active seller")
+
+// ราคาสูงสุดที่คอลัมน์ NUMERIC(10, 2) เก็บได้
+const maxProductPrice = 99999999.99
+
+// NewProduct ข้อมูลสำหรับสร้างสินค้าใหม่
+type NewProduct struct {
+	Name             string        `json:"name"`
+	Description      string        `json:"description"`
+	Brand            string        `json:"brand"`
+	Price            float64       `json:"price"`
+	Discount         int           `json:"discount"`
+	Stock            int           `json:"stock"` // จำนวนเริ่มต้นใน inventory ของตัวเลือกหลัก
+	ProductRecommend string        `json:"product_recommend"`
+	Image            string        `json:"image_url"`
+	SellerID         int           `json:"seller_id"`
+	CategoryID       int           `json:"category_id"`
+	Attributes       ToyAttributes `json:"attributes"`
+	Safety           safety.Label  `json:"safety"`
+}
+
+// UpdateProduct ข้อมูลสำหรับแก้สินค้า ฟิลด์ที่เป็น nil จะไม่ถูกแก้ (ใช้กับ PATCH)
+// การแก้ Stock จะบันทึกส่วนต่างลง stock_movements เป็น adjustment และใช้ได้เฉพาะสินค้าที่มีตัวเลือกเดียว
+// Attributes แก้เฉพาะฟิลด์ที่ระบุ ส่วน ClearAttributes คือชื่อฟิลด์ที่ต้องการล้างค่า (เช่น "battery_type")
+// Safety แทนที่ฉลากความปลอดภัยทั้งฉลาก
+// UnchangedVariantStock ใช้กับ PUT: สินค้าที่มีหลายตัวเลือกรับ Stock ได้เมื่อเท่ากับสต็อกรวมปัจจุบันของทุกตัวเลือก
+// (ส่งค่าเดิมกลับมา) ค่าอื่นถูกปฏิเสธเพราะสต็อกต้องแก้ที่แต่ละตัวเลือก
+type UpdateProduct struct {
+	Name                  *string        `json:"name"`
+	Description           *string        `json:"description"`
+	Brand                 *string        `json:"brand"`
+	Price                 *float64       `json:"price"`
+	Discount              *int           `json:"discount"`
+	Stock                 *int           `json:"stock"`
+	ProductRecommend      *string        `json:"product_recommend"`
+	Image                 *string        `json:"image_url"`
+	SellerID              *int           `json:"seller_id"`
+	CategoryID            *int           `json:"category_id"`
+	Attributes            *ToyAttributes `json:"attributes"`
+	ClearAttributes       []string       `json:"clear_attributes"`
+	Safety                *safety.Label  `json:"safety"`
+	UnchangedVariantStock bool           `json:"-"`
+}
+
+// Validate ตรวจสอบค่าของสินค้าใหม่ (ไม่รวมการมีอยู่ของหมวดหมู่และผู้ขาย ซึ่งตรวจในฐานข้อมูล)
+func (p *NewProduct) Validate() error {
+	if p.ProductRecommend == "" {
+		p.ProductRecommend = "notrecommend"
+	}
+	return UpdateProduct{
+		Name:             &p.Name,
+		Price:            &p.Price,
+		Discount:         &p.Discount,
+		Stock:            &p.Stock,
+		ProductRecommend: &p.ProductRecommend,
+		SellerID:         &p.SellerID,
+		CategoryID:       &p.CategoryID,
+		Attributes:       &p.Attributes,
+		Safety:           &p.Safety,
+	}.Validate()
+}
+
+// Validate ตรวจสอบเฉพาะฟิลด์ที่ระบุมา
+func (u UpdateProduct) Validate() error {
+	if u.Name != nil {
+		name := strings.TrimSpace(*u.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("%w: name is required and must be at most 255 characters", ErrInvalidProduct)
+		}
+	}
+	if u.Brand != nil && len(*u.Brand) > 255 {
+		return fmt.Errorf("%w: brand must be at most 255 characters", ErrInvalidProduct)
+	}
+	if u.Image != nil && len(*u.Image) > 255 {
+		return fmt.Errorf("%w: image_url must be at most 255 characters", ErrInvalidProduct)
+	}
+	if u.Price != nil && (*u.Price <= 0 || *u.Price > maxProductPrice) {
+		return fmt.Errorf("%w: price must be greater than 0 and at most %.2f", ErrInvalidProduct, maxProductPrice)
+	}
+	if u.Discount != nil && (*u.Discount < 0 || *u.Discount > 100) {
+		return fmt.Errorf("%w: discount must be between 0 and 100", ErrInvalidProduct)
+	}
+	if u.Stock != nil && *u.Stock < 0 {
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidProduct)
+	}
+	if u.ProductRecommend != nil && *u.ProductRecommend != "recommend" && *u.ProductRecommend != "notrecommend" {
+		return fmt.Errorf("%w: product_recommend must be recommend or notrecommend", ErrInvalidProduct)
+	}
+	if u.SellerID != nil && *u.SellerID <= 0 {
+		return fmt.Errorf("%w: seller_id is required", ErrInvalidProduct)
+	}
+	if u.CategoryID != nil && *u.CategoryID <= 0 {
+		return fmt.Errorf("%w: category_id is required", ErrInvalidProduct)
+	}
+	if u.Attributes != nil {
+		if err := u.Attributes.Validate(); err != nil {
+			return err
+		}
+	}
+	if u.Safety != nil {
+		if err := u.Safety.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateClearAttributes(u.Attributes, u.ClearAttributes)
+}
+
+// checkProductRefs ตรวจสอบว่าหมวดหมู่และผู้ขายที่อ้างถึงมีอยู่จริง (ค่า 0 คือไม่ต้องตรวจ)
+func checkProductRefs(ctx context.Context, tx *sql.Tx, sellerID, categoryID int) error {
+	var exists bool
+	if categoryID > 0 {
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM categories WHERE category_id = $1)`, categoryID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check category: %v", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: category %d does not exist", ErrInvalidProduct, categoryID)
+		}
+	}
+	if sellerID > 0 {
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM sellers WHERE seller_id = $1 AND deactivated_at IS NULL)`, sellerID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check seller: %v", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: seller %d does not exist or is deactivated", ErrInvalidProduct, sellerID)
+		}
+	}
+	return nil
+}
+
+// GetSellerIDByUserID คืน seller_id ของร้านที่ผูกกับบัญชีผู้ใช้ (เฉพาะร้านที่ยังเปิดอยู่)
+func (pdb *PostgresDatabase) GetSellerIDByUserID(ctx context.Context, userID string) (int, error) {
+	var sellerID int
+	err := pdb.db.QueryRowContext(ctx, `SELECT seller_id FROM sellers WHERE user_id = $1 AND deactivated_at IS NULL`, userID).Scan(&sellerID)
+	if err == sql.ErrNoRows {
+		return 0, ErrSellerNotLinked
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get seller for user: %v", err)
+	}
+	return sellerID, nil
+}
+
+// GetProductSellerID คืน seller_id เจ้าของสินค้า ใช้ตรวจสิทธิ์ก่อนแก้หรือลบสินค้า
+func (pdb *PostgresDatabase) GetProductSellerID(ctx context.Context, productID int) (int, error) {
+	var sellerID int
+	err := pdb.db.QueryRowContext(ctx, `
+		SELECT seller_id FROM products
+		WHERE product_id = $1 AND deleted_at IS NULL`, productID).Scan(&sellerID)
+	if err == sql.ErrNoRows {
+		return 0, ErrProductNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get product seller: %v", err)
+	}
+	return sellerID, nil
+}
+
+// CreateProduct สร้างสินค้าใหม่ ตัวเลือกหลัก แถว inventory และยอดยกมาใน stock_movements
+// ถูกสร้างโดย trigger create_default_variant_for_product จาก product_stock
+func (pdb *PostgresDatabase) CreateProduct(ctx context.Context, p NewProduct) (ProductItem, error) {
+	if err := p.Validate(); err != nil {
+		return ProductItem{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductItem{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	if err := checkProductRefs(ctx, tx, p.SellerID, p.CategoryID); err != nil {
+		tx.Rollback()
+		return ProductItem{}, err
+	}
+
+	var productID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO products (name, description, brand, price, discount, product_stock,
+		                      product_recommend, image_url, seller_id, category_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING product_id`,
+		strings.TrimSpace(p.Name), p.Description, p.Brand, p.Price, p.Discount, p.Stock,
+		p.ProductRecommend, p.Image, p.SellerID, p.CategoryID,
+	).Scan(&productID)
+	if err != nil {
+		tx.Rollback()
+		return ProductItem{}, fmt.Errorf("failed to create product: %v", err)
+	}
+
+	if p.Image != "" {
+		if err := setExternalCover(ctx, tx, productID, p.Image); err != nil {
+			tx.Rollback()
+			return ProductItem{}, err
+		}
+	}
+
+	if err := updateAttributes(ctx, tx, productID, &p.Attributes, nil); err != nil {
+		tx.Rollback()
+		return ProductItem{}, err
+	}
+
+	if err := updateSafety(ctx, tx, productID, &p.Safety); err != nil {
+		tx.Rollback()
+		return ProductItem{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ProductItem{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	pdb.reindexProduct(ctx, productID)
+	return pdb.GetProduct(ctx, fmt.Sprint(productID))
+}
+
+// UpdateProduct แก้เฉพาะฟิลด์ที่ระบุใน u หาก image_url เปลี่ยนจะแทนรูปปกที่เป็นลิงก์ภายนอกในแกลเลอรี
+// (ดู setExternalCover) หากระบุ Stock จะปรับ inventory ของตัวเลือกหลักให้เท่ากับค่าใหม่
+// พร้อมบันทึก movement แบบ adjustment ในนามของ userID สินค้าที่มีหลายตัวเลือกต้องแก้สต็อกที่ตัวเลือกแทน
+func (pdb *PostgresDatabase) UpdateProduct(ctx context.Context, productID int, u UpdateProduct, userID string) (ProductItem, error) {
+	if err := u.Validate(); err != nil {
+		return ProductItem{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductItem{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var sellerID, categoryID int
+	if u.SellerID != nil {
+		sellerID = *u.SellerID
+	}
+	if u.CategoryID != nil {
+		categoryID = *u.CategoryID
+	}
+	if err := checkProductRefs(ctx, tx, sellerID, categoryID); err != nil {
+		tx.Rollback()
+		return ProductItem{}, err
+	}
+
+	var name *string
+	if u.Name != nil {
+		trimmed := strings.TrimSpace(*u.Name)
+		name = &trimmed
+	}
+
+	// ฟิลด์ที่เป็น NULL จะคงค่าเดิมไว้
+	result, err := tx.ExecContext(ctx, `
+		UPDATE products SET
+			name = COALESCE($1, name),
+			description = COALESCE($2, description),
+			brand = COALESCE($3, brand),
+			price = COALESCE($4, price),
+			discount = COALESCE($5, discount),
+			product_recommend = COALESCE($6::product_recommend, product_recommend),
+			image_url = COALESCE($7, image_url),
+			seller_id = COALESCE($8, seller_id),
+			category_id = COALESCE($9, category_id)
+		WHERE product_id = $10 AND deleted_at IS NULL`,
+		name, u.Description, u.Brand, u.Price, u.Discount, u.ProductRecommend,
+		u.Image, u.SellerID, u.CategoryID, productID)
+	if err != nil {
+		tx.Rollback()
+		return ProductItem{}, fmt.Errorf("failed to update product: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return ProductItem{}, fmt.Errorf("failed to check updated product: %v", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return ProductItem{}, ErrProductNotFound
+	}
+
+	if u.Image != nil {
+		if err := setExternalCover(ctx, tx, productID, *u.Image); err != nil {
+			tx.Rollback()
+			return ProductItem{}, err
+		}
+	}
+
+	if u.Attributes != nil || len(u.ClearAttributes) > 0 {
+		if err := updateAttributes(ctx, tx, productID, u.Attributes, u.ClearAttributes); err != nil {
+			tx.Rollback()
+			return ProductItem{}, err
+		}
+	}
+
+	// ตรวจฉลากกับอายุขั้นต่ำใหม่ด้วยเมื่อแก้เฉพาะข้อมูลจำเพาะ
+	if u.Safety != nil || u.Attributes != nil || len(u.ClearAttributes) > 0 {
+		if err := updateSafety(ctx, tx, productID, u.Safety); err != nil {
+			tx.Rollback()
+			return ProductItem{}, err
+		}
+	}
+
+	if u.Stock != nil {
+		var variants int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM product_variants
+			WHERE product_id = $1 AND deleted_at IS NULL`, productID).Scan(&variants)
+		if err != nil {
+			tx.Rollback()
+			return ProductItem{}, fmt.Errorf("failed to count variants: %v", err)
+		}
+		switch {
+		case variants > 1 && u.UnchangedVariantStock:
+			var total int
+			err := tx.QueryRowContext(ctx, `
+				SELECT COALESCE((SELECT quantity FROM product_inventory WHERE product_id = $1), 0)`, productID).Scan(&total)
+			if err != nil {
+				tx.Rollback()
+				return ProductItem{}, fmt.Errorf("failed to fetch stock: %v", err)
+			}
+			if *u.Stock != total {
+				tx.Rollback()
+				return ProductItem{}, fmt.Errorf("%w: product has %d variants with %d in stock, set stock on each variant instead", ErrInvalidProduct, variants, total)
+			}
+		case variants > 1:
+			tx.Rollback()
+			return ProductItem{}, fmt.Errorf("%w: product has %d variants, set stock on each variant instead", ErrInvalidProduct, variants)
+		default:
+			variantID, err := resolveVariant(ctx, tx, productID, 0)
+			if err != nil {
+				tx.Rollback()
+				return ProductItem{}, err
+			}
+			if err := setVariantStock(ctx, tx, variantID, *u.Stock, "stock set by product update", userID); err != nil {
+				tx.Rollback()
+				return ProductItem{}, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ProductItem{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	pdb.reindexProduct(ctx, productID)
+	return pdb.GetProduct(ctx, fmt.Sprint(productID))
+}
+
+// DeleteProduct ลบสินค้าแบบ soft delete สินค้าจะไม่แสดงและสั่งซื้อไม่ได้อีก
+// แต่ประวัติคำสั่งซื้อและสต็อกยังอยู่ครบ รายการในตะกร้าที่ยังไม่สั่งซื้อ (และการจอง) จะถูกลบออก
+func (pdb *PostgresDatabase) DeleteProduct(ctx context.Context, productID int) error {
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE products SET deleted_at = CURRENT_TIMESTAMP
+		WHERE product_id = $1 AND deleted_at IS NULL`, productID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete product: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check deleted product: %v", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return ErrProductNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM cart_items WHERE product_id = $1 AND added_to_cart = FALSE`, productID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to remove product from carts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	pdb.searchIndex.Remove(productID)
+	return nil
+}
@@ -0,0 +1,361 @@
+// recall.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidRecall ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลการเรียกคืนไม่ผ่านการตรวจสอบ
+var ErrInvalidRecall = errors.New("invalid recall")
+
+// ErrRecallNotFound ถูกส่งคืนเมื่อไม่พบการเรียกคืน
+var ErrRecallNotFound = errors.New("recall not found")
+
+// ErrProductRecalled ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อเพิ่มลงตะกร้าหรือสั่งซื้อตัวเลือกที่อยู่ระหว่างการเรียกคืน
+var ErrProductRecalled = errors.New("product has been recalled")
+
+// สถานะของการเรียกคืน
+const (
+	RecallActive = "active"
+	RecallClosed = "closed"
+)
+
+// ความยาวสูงสุดของเหตุผลและคำแนะนำสำหรับลูกค้า
+const maxRecallText = 2000
+
+// productRecalled เงื่อนไข SQL ว่าสินค้า p ถูกเรียกคืนทุกตัวเลือกอยู่ (ซ่อนจากหน้ารายการและการค้นหา)
+const productRecalled = `EXISTS (
+	SELECT 1 FROM product_recalls rc
+	WHERE rc.product_id = p.product_id AND rc.status = 'active' AND rc.variant_ids = '{}')`
+
+// variantRecalled เงื่อนไข SQL ว่าตัวเลือก v ถูกเรียกคืนอยู่ ทั้งแบบทุกตัวเลือกและเฉพาะตัวเลือกนั้น
+// ช่วงวันที่ขายของการเรียกคืนไม่มีผล เพราะไม่รู้ว่าสต็อกที่เหลือมาจากล็อตไหน
+const variantRecalled = `EXISTS (
+	SELECT 1 FROM product_recalls rc
+	WHERE rc.product_id = v.product_id AND rc.status = 'active'
+	  AND (rc.variant_ids = '{}' OR v.variant_id = ANY(rc.variant_ids)))`
+
+// recallCoversOrderItem เงื่อนไข SQL ว่ารายการสั่งซื้อ oi ของคำสั่งซื้อ o อยู่ในขอบเขตของการเรียกคืน r
+const recallCoversOrderItem = `oi.product_id = r.product_id
+	AND (r.variant_ids = '{}' OR oi.variant_id = ANY(r.variant_ids))
+	AND (r.sold_from IS NULL OR o.order_date >= r.sold_from)
+	AND (r.sold_until IS NULL OR o.order_date <= r.sold_until)`
+
+// Recall การเรียกคืนสินค้า VariantIDs ว่างคือทุกตัวเลือกของสินค้า
+// SoldFrom / SoldUntil จำกัดล็อตที่ถูกเรียกคืนตามวันที่สั่งซื้อ ใช้เลือกลูกค้าที่ต้องได้รับแจ้ง
+type Recall struct {
+	ID                int        `json:"recall_id"`
+	ProductID         int        `json:"product_id"`
+	ProductName       string     `json:"product_name"`
+	VariantIDs        []int64    `json:"variant_ids"`
+	SoldFrom          *time.Time `json:"sold_from"`
+	SoldUntil         *time.Time `json:"sold_until"`
+	Reason            string     `json:"reason"`
+	Instructions      string     `json:"instructions"`
+	Status            string     `json:"status"`
+	CustomersNotified int        `json:"customers_notified"`
+	CreatedBy         *string    `json:"created_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	ClosedAt          *time.Time `json:"closed_at"`
+}
+
+// NewRecall ข้อมูลสำหรับประกาศเรียกคืนสินค้า
+type NewRecall struct {
+	ProductID    int        `json:"product_id"`
+	VariantIDs   []int64    `json:"variant_ids"`
+	SoldFrom     *time.Time `json:"sold_from"`
+	SoldUntil    *time.Time `json:"sold_until"`
+	Reason       string     `json:"reason"`
+	Instructions string     `json:"instructions"`
+}
+
+// RecallReportRow รายการสั่งซื้อหนึ่งรายการที่อยู่ในขอบเขตของการเรียกคืน พร้อมข้อมูลติดต่อลูกค้า
+type RecallReportRow struct {
+	OrderID    int        `json:"order_id"`
+	OrderDate  time.Time  `json:"order_date"`
+	UserID     string     `json:"user_id"`
+	FullName   string     `json:"full_name"`
+	Email      string     `json:"email"`
+	Phone      *string    `json:"phone"`
+	Address    *string    `json:"address"`
+	VariantID  int        `json:"variant_id"`
+	SKU        string     `json:"sku"`
+	Quantity   int        `json:"quantity"`
+	NotifiedAt *time.Time `json:"notified_at"`
+	ReadAt     *time.Time `json:"read_at"`
+}
+
+// RecallReport รายงานการเรียกคืน: ทุกรายการสั่งซื้อที่ได้รับผลกระทบ เรียงตามวันที่สั่งซื้อ
+type RecallReport struct {
+	Recall Recall            `json:"recall"`
+	Items  []RecallReportRow `json:"items"`
+}
+
+// Validate ตรวจสอบข้อมูลการเรียกคืน ตัดช่องว่าง และเรียงรหัสตัวเลือกโดยตัดรายการซ้ำ
+func (r *NewRecall) Validate() error {
+	if r.ProductID <= 0 {
+		return fmt.Errorf("%w: product_id is required", ErrInvalidRecall)
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" || len([]rune(r.Reason)) > maxRecallText {
+		return fmt.Errorf("%w: reason must be non-empty and at most %d characters", ErrInvalidRecall, maxRecallText)
+	}
+	r.Instructions = strings.TrimSpace(r.Instructions)
+	if len([]rune(r.Instructions)) > maxRecallText {
+		return fmt.Errorf("%w: instructions must be at most %d characters", ErrInvalidRecall, maxRecallText)
+	}
+	for _, id := range r.VariantIDs {
+		if id <= 0 {
+			return fmt.Errorf("%w: invalid variant id %d", ErrInvalidRecall, id)
+		}
+	}
+	slices.Sort(r.VariantIDs)
+	r.VariantIDs = slices.Compact(r.VariantIDs)
+	if r.VariantIDs == nil {
+		r.VariantIDs = []int64{}
+	}
+	if r.SoldFrom != nil && r.SoldUntil != nil && r.SoldUntil.Before(*r.SoldFrom) {
+		return fmt.Errorf("%w: sold_until must not be before sold_from", ErrInvalidRecall)
+	}
+	return nil
+}
+
+// notificationMessage ข้อความแจ้งลูกค้า: เหตุผลของการเรียกคืนตามด้วยคำแนะนำ (ถ้ามี)
+func (r NewRecall) notificationMessage() string {
+	if r.Instructions == "" {
+		return r.Reason
+	}
+	return r.Reason + "\n\n" + r.Instructions
+}
+
+// queryRecall ดึงการเรียกคืนหนึ่งรายการพร้อมจำนวนลูกค้าที่ได้รับแจ้ง
+func queryRecall(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}, recallID int) (Recall, error) {
+	recall, err := scanRecall(q.QueryRowContext(ctx, recallSelect+`
+		WHERE r.recall_id = $1`, recallID))
+	if err == sql.ErrNoRows {
+		return Recall{}, ErrRecallNotFound
+	} else if err != nil {
+		return Recall{}, fmt.Errorf("failed to get recall: %v", err)
+	}
+	return recall, nil
+}
+
+// recallSelect คอลัมน์ของการเรียกคืนตามลำดับที่ scanRecall อ่าน
+const recallSelect = `
+	SELECT r.recall_id, r.product_id, p.name, r.variant_ids, r.sold_from, r.sold_until,
+	       r.reason, COALESCE(r.instructions, ''), r.status,
+	       (SELECT COUNT(*) FROM notifications n WHERE n.recall_id = r.recall_id),
+	       r.created_by, r.created_at, r.updated_at, r.closed_at
+	FROM product_recalls r
+	JOIN products p ON p.product_id = r.product_id`
+
+func scanRecall(row interface{ Scan(...interface{}) error }) (Recall, error) {
+	var recall Recall
+	err := row.Scan(&recall.ID, &recall.ProductID, &recall.ProductName, pq.Array(&recall.VariantIDs),
+		&recall.SoldFrom, &recall.SoldUntil, &recall.Reason, &recall.Instructions, &recall.Status,
+		&recall.CustomersNotified, &recall.CreatedBy, &recall.CreatedAt, &recall.UpdatedAt, &recall.ClosedAt)
+	if err != nil {
+		return Recall{}, err
+	}
+	if recall.VariantIDs == nil {
+		recall.VariantIDs = []int64{}
+	}
+	return recall, nil
+}
+
+// CreateRecall ประกาศเรียกคืนสินค้า (ทั้งสินค้าหรือเฉพาะบางตัวเลือก) แล้วสร้างการแจ้งเตือนให้ลูกค้าทุกคน
+// ที่เคยสั่งซื้อสินค้าในขอบเขตของการเรียกคืนตาม order_items ภายใน transaction เดียวกัน
+// สินค้าที่ถูกลบไปแล้วก็เรียกคืนได้ เพราะลูกค้ายังมีสินค้าที่ซื้อไปอยู่
+func (pdb *PostgresDatabase) CreateRecall(ctx context.Context, r NewRecall, userID string) (Recall, error) {
+	if err := r.Validate(); err != nil {
+		return Recall{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Recall{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var name string
+	err = tx.QueryRowContext(ctx, `
+		SELECT name FROM products WHERE product_id = $1 FOR UPDATE`, r.ProductID).Scan(&name)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return Recall{}, ErrProductNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return Recall{}, fmt.Errorf("failed to get product: %v", err)
+	}
+
+	// ตัวเลือกต้องเป็นของสินค้านี้ (รวมตัวเลือกที่ถูกลบแล้วซึ่งอาจเคยขายไป)
+	if len(r.VariantIDs) > 0 {
+		var count int
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM product_variants
+			WHERE product_id = $1 AND variant_id = ANY($2)`, r.ProductID, pq.Array(r.VariantIDs)).Scan(&count)
+		if err != nil {
+			tx.Rollback()
+			return Recall{}, fmt.Errorf("failed to check recalled variants: %v", err)
+		}
+		if count != len(r.VariantIDs) {
+			tx.Rollback()
+			return Recall{}, fmt.Errorf("%w: variant_ids must belong to product %d", ErrInvalidRecall, r.ProductID)
+		}
+	}
+
+	var instructions *string
+	if r.Instructions != "" {
+		instructions = &r.Instructions
+	}
+	var createdBy *string
+	if userID != "" {
+		createdBy = &userID
+	}
+
+	var recallID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO product_recalls (product_id, variant_ids, sold_from, sold_until, reason, instructions, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING recall_id`,
+		r.ProductID, pq.Array(r.VariantIDs), r.SoldFrom, r.SoldUntil, r.Reason, instructions, createdBy).Scan(&recallID)
+	if err != nil {
+		tx.Rollback()
+		return Recall{}, fmt.Errorf("failed to create recall: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, kind, recall_id, title, message)
+		SELECT DISTINCT o.user_id, 'recall', r.recall_id, $2, $3
+		FROM product_recalls r
+		JOIN order_items oi ON oi.product_id = r.product_id
+		JOIN orders o ON o.order_id = oi.order_id
+		WHERE r.recall_id = $1 AND o.user_id IS NOT NULL AND `+recallCoversOrderItem+`
+		ON CONFLICT (user_id, recall_id) DO NOTHING`,
+		recallID, "ประกาศเรียกคืนสินค้า: "+name, r.notificationMessage())
+	if err != nil {
+		tx.Rollback()
+		return Recall{}, fmt.Errorf("failed to notify customers: %v", err)
+	}
+
+	recall, err := queryRecall(ctx, tx, recallID)
+	if err != nil {
+		tx.Rollback()
+		return Recall{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Recall{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	pdb.reindexProduct(ctx, r.ProductID)
+	return recall, nil
+}
+
+// GetRecalls แสดงการเรียกคืนทั้งหมด ล่าสุดก่อน status ว่างคือทุกสถานะ
+func (pdb *PostgresDatabase) GetRecalls(ctx context.Context, status string) ([]Recall, error) {
+	if status != "" && status != RecallActive && status != RecallClosed {
+		return nil, fmt.Errorf("%w: status must be %q or %q", ErrInvalidRecall, RecallActive, RecallClosed)
+	}
+
+	rows, err := pdb.db.QueryContext(ctx, recallSelect+`
+		WHERE ($1 = '' OR r.status = $1)
+		ORDER BY r.created_at DESC, r.recall_id DESC`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recalls: %v", err)
+	}
+	defer rows.Close()
+
+	recalls := []Recall{}
+	for rows.Next() {
+		recall, err := scanRecall(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recall: %v", err)
+		}
+		recalls = append(recalls, recall)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate recalls: %v", err)
+	}
+
+	return recalls, nil
+}
+
+// GetRecall แสดงการเรียกคืนหนึ่งรายการ
+func (pdb *PostgresDatabase) GetRecall(ctx context.Context, recallID int) (Recall, error) {
+	return queryRecall(ctx, pdb.db, recallID)
+}
+
+// CloseRecall ปิดการเรียกคืน สินค้ากลับมาแสดงและขายได้ (การแจ้งเตือนที่ส่งไปแล้วยังอยู่)
+func (pdb *PostgresDatabase) CloseRecall(ctx context.Context, recallID int) (Recall, error) {
+	var productID int
+	err := pdb.db.QueryRowContext(ctx, `
+		UPDATE product_recalls SET status = 'closed', closed_at = CURRENT_TIMESTAMP
+		WHERE recall_id = $1 AND status = 'active'
+		RETURNING product_id`, recallID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		// แยกระหว่างไม่พบกับปิดไปแล้ว
+		if _, err := queryRecall(ctx, pdb.db, recallID); err != nil {
+			return Recall{}, err
+		}
+		return Recall{}, fmt.Errorf("%w: recall %d is already closed", ErrInvalidRecall, recallID)
+	} else if err != nil {
+		return Recall{}, fmt.Errorf("failed to close recall: %v", err)
+	}
+
+	pdb.reindexProduct(ctx, productID)
+	return queryRecall(ctx, pdb.db, recallID)
+}
+
+// GetRecallReport รายงานทุกรายการสั่งซื้อที่อยู่ในขอบเขตของการเรียกคืน พร้อมข้อมูลติดต่อลูกค้า
+// และเวลาที่แจ้งเตือน/ลูกค้าเปิดอ่าน
+func (pdb *PostgresDatabase) GetRecallReport(ctx context.Context, recallID int) (RecallReport, error) {
+	recall, err := queryRecall(ctx, pdb.db, recallID)
+	if err != nil {
+		return RecallReport{}, err
+	}
+
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT o.order_id, o.order_date, u.user_id, u.full_name, u.email, u.phone, u.address,
+		       oi.variant_id, v.sku, oi.quantity, n.created_at, n.read_at
+		FROM product_recalls r
+		JOIN order_items oi ON oi.product_id = r.product_id
+		JOIN orders o ON o.order_id = oi.order_id
+		JOIN users u ON u.user_id = o.user_id
+		JOIN product_variants v ON v.variant_id = oi.variant_id
+		LEFT JOIN notifications n ON n.recall_id = r.recall_id AND n.user_id = u.user_id
+		WHERE r.recall_id = $1 AND `+recallCoversOrderItem+`
+		ORDER BY o.order_date, oi.order_item_id`, recallID)
+	if err != nil {
+		return RecallReport{}, fmt.Errorf("failed to query recall report: %v", err)
+	}
+	defer rows.Close()
+
+	report := RecallReport{Recall: recall, Items: []RecallReportRow{}}
+	for rows.Next() {
+		var item RecallReportRow
+		err := rows.Scan(&item.OrderID, &item.OrderDate, &item.UserID, &item.FullName, &item.Email, &item.Phone, &item.Address,
+			&item.VariantID, &item.SKU, &item.Quantity, &item.NotifiedAt, &item.ReadAt)
+		if err != nil {
+			return RecallReport{}, fmt.Errorf("failed to scan recall report row: %v", err)
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return RecallReport{}, fmt.Errorf("failed to iterate recall report: %v", err)
+	}
+
+	return report, nil
+}
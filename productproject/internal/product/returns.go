@@ -0,0 +1,643 @@
+// returns.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"productproject/internal/fulfillment"
+	"productproject/internal/inventory"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidReturn ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อคำขอคืนสินค้าหรือการตัดสินไม่ผ่านการตรวจสอบ
+var ErrInvalidReturn = errors.New("invalid return request")
+
+// ErrReturnNotFound ถูกส่งคืนเมื่อไม่พบคำขอคืนสินค้า
+var ErrReturnNotFound = errors.New("return request not found")
+
+// ErrReturnDecided ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่ออนุมัติหรือปฏิเสธคำขอที่ถูกตัดสินไปแล้ว
+var ErrReturnDecided = errors.New("return request already decided")
+
+// สถานะของคำขอคืนสินค้า
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+)
+
+// วิธีจัดการสินค้าที่อนุมัติให้คืน
+const (
+	DispositionRestock  = "restock"   // คืนเข้าคลังเพื่อขายต่อ
+	DispositionWriteOff = "write_off" // รับคืนแล้วตัดทิ้ง (เสียหายหรือขายต่อไม่ได้)
+)
+
+// จำนวนรูปประกอบสูงสุดต่อคำขอ และความยาวสูงสุดของเหตุผลและหมายเหตุ
+const (
+	MaxReturnPhotos = 5
+	maxReturnText   = 2000
+)
+
+// ReturnRequest คำขอคืนสินค้าของการจัดส่งหนึ่งรายการ Refund มีเมื่อคำขอถูกอนุมัติแล้ว
+type ReturnRequest struct {
+	ID           int           `json:"return_id"`
+	OrderID      int           `json:"order_id"`
+	ShipmentID   int           `json:"shipment_id"`
+	SellerID     int           `json:"seller_id"`
+	UserID       string        `json:"user_id"`
+	Status       string        `json:"status"`
+	Reason       string        `json:"reason"`
+	DecisionNote string        `json:"decision_note"`
+	DecidedBy    *string       `json:"decided_by"`
+	DecidedAt    *time.Time    `json:"decided_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Items        []ReturnItem  `json:"items"`
+	Photos       []ReturnPhoto `json:"photos"`
+	Refund       *Refund       `json:"refund"`
+}
+
+// ReturnItem รายการที่ขอคืน Amount คือยอดคืนเงินของจำนวนที่คืน (ตามราคาหลังส่วนลด ณ เวลาสั่งซื้อ)
+type ReturnItem struct {
+	ID          int     `json:"return_item_id"`
+	OrderItemID int     `json:"order_item_id"`
+	CartItemID  int     `json:"cart_item_id"`
+	ProductID   int     `json:"product_id"`
+	VariantID   int     `json:"variant_id"`
+	ProductName string  `json:"product_name"`
+	SKU         string  `json:"sku"`
+	Quantity    int     `json:"quantity"`
+	Amount      float64 `json:"amount"`
+	Disposition *string `json:"disposition"`
+}
+
+// ReturnPhoto รูปประกอบคำขอคืนสินค้า
+type ReturnPhoto struct {
+	ID          int               `json:"photo_id"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+	StorageKeys []string          `json:"-"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// Refund การคืนเงินของคำขอที่อนุมัติ อ้างอิงคำสั่งซื้อซึ่งเป็นการชำระเงินต้นทาง
+type Refund struct {
+	ID        int       `json:"refund_id"`
+	OrderID   int       `json:"order_id"`
+	Amount    float64   `json:"amount"`
+	CreatedBy *string   `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewReturn ข้อมูลสำหรับเปิดคำขอคืนสินค้า ทุกรายการต้องอยู่ในการจัดส่งเดียวกัน
+// Photos ถูกเก็บลง storage โดยผู้เรียกก่อนแล้ว
+type NewReturn struct {
+	OrderID int             `json:"order_id"`
+	Reason  string          `json:"reason"`
+	Items   []NewReturnItem `json:"items"`
+	Photos  []ReturnPhoto   `json:"-"`
+}
+
+// NewReturnItem รายการที่ขอคืน อ้างอิงด้วย cart_item_id เดียวกับที่แสดงในคำสั่งซื้อ
+type NewReturnItem struct {
+	CartItemID int `json:"cart_item_id"`
+	Quantity   int `json:"quantity"`
+}
+
+// ReturnDecision การอนุมัติหรือปฏิเสธคำขอ Dispositions ระบุวิธีจัดการต่อ return_item_id
+// (รายการที่ไม่ได้ระบุถือเป็น restock) ใช้เฉพาะตอนอนุมัติ
+type ReturnDecision struct {
+	Note         string         `json:"note"`
+	Dispositions map[int]string `json:"dispositions"`
+}
+
+// ReturnFilter ขอบเขตของรายการคำขอ ค่าว่างคือไม่กรอง
+type ReturnFilter struct {
+	UserID   string
+	SellerID int
+	Status   string
+}
+
+// Validate ตรวจสอบคำขอ ตัดช่องว่าง และรวมรายการซ้ำของ cart_item_id เดียวกัน
+func (r *NewReturn) Validate() error {
+	if r.OrderID <= 0 {
+		return fmt.Errorf("%w: order_id is required", ErrInvalidReturn)
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" || len([]rune(r.Reason)) > maxReturnText {
+		return fmt.Errorf("%w: reason must be non-empty and at most %d characters", ErrInvalidReturn, maxReturnText)
+	}
+	if len(r.Items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidReturn)
+	}
+	merged := make(map[int]int, len(r.Items))
+	items := make([]NewReturnItem, 0, len(r.Items))
+	for _, item := range r.Items {
+		if item.CartItemID <= 0 || item.Quantity <= 0 {
+			return fmt.Errorf("%w: each item needs a cart_item_id and a positive quantity", ErrInvalidReturn)
+		}
+		if i, ok := merged[item.CartItemID]; ok {
+			items[i].Quantity += item.Quantity
+			continue
+		}
+		merged[item.CartItemID] = len(items)
+		items = append(items, item)
+	}
+	r.Items = items
+	if len(r.Photos) > MaxReturnPhotos {
+		return fmt.Errorf("%w: at most %d photos are allowed", ErrInvalidReturn, MaxReturnPhotos)
+	}
+	for _, photo := range r.Photos {
+		if photo.URL == "" || len(photo.URL) > 255 {
+			return fmt.Errorf("%w: photo url is required and must be at most 255 characters", ErrInvalidReturn)
+		}
+	}
+	return nil
+}
+
+// Validate ตรวจสอบหมายเหตุและวิธีจัดการสินค้า requireNote ใช้ตอนปฏิเสธซึ่งต้องแจ้งเหตุผลผู้ซื้อ
+func (d *ReturnDecision) Validate(requireNote bool) error {
+	d.Note = strings.TrimSpace(d.Note)
+	if requireNote && d.Note == "" {
+		return fmt.Errorf("%w: note is required when rejecting a return", ErrInvalidReturn)
+	}
+	if len([]rune(d.Note)) > maxReturnText {
+		return fmt.Errorf("%w: note must be at most %d characters", ErrInvalidReturn, maxReturnText)
+	}
+	for id, disposition := range d.Dispositions {
+		if disposition != DispositionRestock && disposition != DispositionWriteOff {
+			return fmt.Errorf("%w: disposition of item %d must be %s or %s",
+				ErrInvalidReturn, id, DispositionRestock, DispositionWriteOff)
+		}
+	}
+	return nil
+}
+
+// CreateReturn เปิดคำขอคืนสินค้าของผู้ซื้อ userID สำหรับรายการในการจัดส่งที่ส่งถึงแล้ว (delivered หรือ received)
+// จำนวนที่ขอคืนรวมกับคำขอก่อนหน้าที่ยังไม่ถูกปฏิเสธต้องไม่เกินจำนวนที่สั่งซื้อ
+func (pdb *PostgresDatabase) CreateReturn(ctx context.Context, userID string, r NewReturn) (ReturnRequest, error) {
+	if err := r.Validate(); err != nil {
+		return ReturnRequest{}, err
+	}
+
+	cartItemIDs := make([]int, len(r.Items))
+	for i, item := range r.Items {
+		cartItemIDs[i] = item.CartItemID
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	// ล็อกการจัดส่งของรายการที่ขอคืน คำขอพร้อมกันของการจัดส่งเดียวกันจะได้ไม่คืนเกินจำนวน
+	rows, err := tx.QueryContext(ctx, `
+		SELECT sh.shipment_id, sh.seller_id, sh.status
+		FROM shipments sh
+		JOIN orders o ON o.order_id = sh.order_id
+		WHERE sh.order_id = $1 AND o.user_id = $3 AND EXISTS (
+			SELECT 1 FROM order_items oi
+			WHERE oi.order_id = sh.order_id AND oi.seller_id = sh.seller_id AND oi.cart_item_id = ANY($2))
+		ORDER BY sh.shipment_id
+		FOR UPDATE OF sh`, r.OrderID, pq.Array(cartItemIDs), userID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to lock shipment: %v", err)
+	}
+	var shipments []lockedShipment
+	for rows.Next() {
+		sh := lockedShipment{orderID: r.OrderID}
+		if err := rows.Scan(&sh.id, &sh.sellerID, &sh.status); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("failed to scan shipment: %v", err)
+		}
+		shipments = append(shipments, sh)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to iterate shipments: %v", err)
+	}
+	switch {
+	case len(shipments) == 0:
+		tx.Rollback()
+		return ReturnRequest{}, ErrOrderNotFound
+	case len(shipments) > 1:
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("%w: items from different sellers need separate return requests", ErrInvalidReturn)
+	}
+	shipment := shipments[0]
+	if shipment.status != fulfillment.Delivered && shipment.status != fulfillment.Received {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("%w: shipment is %s, items can be returned once delivered",
+			ErrInvalidReturn, shipment.status)
+	}
+
+	// จำนวนที่ยังคืนได้ของแต่ละรายการ (หักคำขอที่รออนุมัติหรืออนุมัติแล้ว)
+	rows, err = tx.QueryContext(ctx, `
+		SELECT oi.cart_item_id, oi.order_item_id,
+		       oi.quantity - COALESCE((
+		           SELECT SUM(ri.quantity) FROM return_items ri
+		           JOIN return_requests rr ON rr.return_id = ri.return_id
+		           WHERE ri.order_item_id = oi.order_item_id AND rr.status <> 'rejected'), 0)
+		FROM order_items oi
+		WHERE oi.order_id = $1 AND oi.seller_id = $2 AND oi.cart_item_id = ANY($3)`,
+		r.OrderID, shipment.sellerID, pq.Array(cartItemIDs))
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to query returnable items: %v", err)
+	}
+	orderItemID := make(map[int]int, len(r.Items))
+	returnable := make(map[int]int, len(r.Items))
+	for rows.Next() {
+		var cartItemID, id, remaining int
+		if err := rows.Scan(&cartItemID, &id, &remaining); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("failed to scan returnable item: %v", err)
+		}
+		orderItemID[cartItemID] = id
+		returnable[cartItemID] = remaining
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to iterate returnable items: %v", err)
+	}
+	for _, item := range r.Items {
+		if _, ok := orderItemID[item.CartItemID]; !ok {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("%w: cart item %d is not part of order %d", ErrInvalidReturn, item.CartItemID, r.OrderID)
+		}
+		if item.Quantity > returnable[item.CartItemID] {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("%w: only %d of cart item %d can still be returned",
+				ErrInvalidReturn, max(returnable[item.CartItemID], 0), item.CartItemID)
+		}
+	}
+
+	var returnID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO return_requests (order_id, shipment_id, seller_id, user_id, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING return_id`, r.OrderID, shipment.id, shipment.sellerID, userID, r.Reason).Scan(&returnID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to create return request: %v", err)
+	}
+
+	for _, item := range r.Items {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO return_items (return_id, order_item_id, quantity)
+			VALUES ($1, $2, $3)`, returnID, orderItemID[item.CartItemID], item.Quantity)
+		if err != nil {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("failed to add return item: %v", err)
+		}
+	}
+
+	for _, photo := range r.Photos {
+		thumbnails, err := json.Marshal(photo.Thumbnails)
+		if err != nil {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("failed to encode photo thumbnails: %v", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO return_photos (return_id, url, thumbnails, storage_keys)
+			VALUES ($1, $2, $3, $4)`, returnID, photo.URL, thumbnails, pq.Array(photo.StorageKeys))
+		if err != nil {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("failed to add return photo: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.GetReturn(ctx, returnID)
+}
+
+// returnSelect คอลัมน์ของคำขอคืนสินค้าตามลำดับที่ scanReturn อ่าน
+const returnSelect = `
+	SELECT rr.return_id, rr.order_id, rr.shipment_id, rr.seller_id, rr.user_id, rr.status, rr.reason,
+	       COALESCE(rr.decision_note, ''), rr.decided_by, rr.decided_at, rr.created_at, rr.updated_at
+	FROM return_requests rr`
+
+func scanReturn(row interface{ Scan(...interface{}) error }) (ReturnRequest, error) {
+	var r ReturnRequest
+	err := row.Scan(&r.ID, &r.OrderID, &r.ShipmentID, &r.SellerID, &r.UserID, &r.Status, &r.Reason,
+		&r.DecisionNote, &r.DecidedBy, &r.DecidedAt, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// GetReturns แสดงคำขอคืนสินค้าตามขอบเขตใน filter (ไม่รวมรายการและรูป) ล่าสุดก่อน
+func (pdb *PostgresDatabase) GetReturns(ctx context.Context, filter ReturnFilter) ([]ReturnRequest, error) {
+	var userID *string
+	if filter.UserID != "" {
+		userID = &filter.UserID
+	}
+	rows, err := pdb.db.QueryContext(ctx, returnSelect+`
+		WHERE ($1::uuid IS NULL OR rr.user_id = $1)
+		  AND ($2 = 0 OR rr.seller_id = $2)
+		  AND ($3 = '' OR rr.status = $3)
+		ORDER BY rr.created_at DESC, rr.return_id DESC`, userID, filter.SellerID, filter.Status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query return requests: %v", err)
+	}
+	defer rows.Close()
+
+	returns := []ReturnRequest{}
+	for rows.Next() {
+		r, err := scanReturn(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan return request: %v", err)
+		}
+		returns = append(returns, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate return requests: %v", err)
+	}
+
+	return returns, nil
+}
+
+// GetReturn แสดงคำขอคืนสินค้าพร้อมรายการ รูปประกอบ และการคืนเงิน (ถ้าอนุมัติแล้ว)
+func (pdb *PostgresDatabase) GetReturn(ctx context.Context, returnID int) (ReturnRequest, error) {
+	r, err := scanReturn(pdb.db.QueryRowContext(ctx, returnSelect+`
+		WHERE rr.return_id = $1`, returnID))
+	if err == sql.ErrNoRows {
+		return ReturnRequest{}, ErrReturnNotFound
+	} else if err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to get return request: %v", err)
+	}
+
+	if r.Items, err = queryReturnItems(ctx, pdb.db, returnID); err != nil {
+		return ReturnRequest{}, err
+	}
+	if r.Photos, err = pdb.queryReturnPhotos(ctx, returnID); err != nil {
+		return ReturnRequest{}, err
+	}
+
+	var refund Refund
+	err = pdb.db.QueryRowContext(ctx, `
+		SELECT refund_id, order_id, amount, created_by, created_at
+		FROM refunds WHERE return_id = $1`, returnID).Scan(
+		&refund.ID, &refund.OrderID, &refund.Amount, &refund.CreatedBy, &refund.CreatedAt)
+	if err == nil {
+		r.Refund = &refund
+	} else if err != sql.ErrNoRows {
+		return ReturnRequest{}, fmt.Errorf("failed to get refund: %v", err)
+	}
+
+	return r, nil
+}
+
+// queryer คือ *sql.DB หรือ *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryReturnItems ดึงรายการของคำขอพร้อมยอดคืนเงินต่อรายการ ใช้ได้ทั้งใน transaction และนอก transaction
+func queryReturnItems(ctx context.Context, q queryer, returnID int) ([]ReturnItem, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT ri.return_item_id, ri.order_item_id, oi.cart_item_id, oi.product_id, oi.variant_id,
+		       COALESCE(p.name, ''), COALESCE(v.sku, ''), ri.quantity,
+		       ROUND(oi.line_total * ri.quantity / oi.quantity, 2), ri.disposition
+		FROM return_items ri
+		JOIN order_items oi ON oi.order_item_id = ri.order_item_id
+		LEFT JOIN products p ON p.product_id = oi.product_id
+		LEFT JOIN product_variants v ON v.variant_id = oi.variant_id
+		WHERE ri.return_id = $1
+		ORDER BY ri.return_item_id`, returnID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query return items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []ReturnItem{}
+	for rows.Next() {
+		var item ReturnItem
+		if err := rows.Scan(&item.ID, &item.OrderItemID, &item.CartItemID, &item.ProductID, &item.VariantID,
+			&item.ProductName, &item.SKU, &item.Quantity, &item.Amount, &item.Disposition); err != nil {
+			return nil, fmt.Errorf("failed to scan return item: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate return items: %v", err)
+	}
+
+	return items, nil
+}
+
+func (pdb *PostgresDatabase) queryReturnPhotos(ctx context.Context, returnID int) ([]ReturnPhoto, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT photo_id, url, thumbnails, storage_keys, created_at
+		FROM return_photos
+		WHERE return_id = $1
+		ORDER BY photo_id`, returnID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query return photos: %v", err)
+	}
+	defer rows.Close()
+
+	photos := []ReturnPhoto{}
+	for rows.Next() {
+		var photo ReturnPhoto
+		var thumbnails []byte
+		if err := rows.Scan(&photo.ID, &photo.URL, &thumbnails, pq.Array(&photo.StorageKeys), &photo.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan return photo: %v", err)
+		}
+		if err := json.Unmarshal(thumbnails, &photo.Thumbnails); err != nil {
+			return nil, fmt.Errorf("failed to decode photo thumbnails: %v", err)
+		}
+		photos = append(photos, photo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate return photos: %v", err)
+	}
+
+	return photos, nil
+}
+
+// lockPendingReturn ล็อกคำขอที่ยังรอตัดสินภายใน transaction ของผู้เรียก
+func lockPendingReturn(ctx context.Context, tx *sql.Tx, returnID int) (ReturnRequest, error) {
+	r, err := scanReturn(tx.QueryRowContext(ctx, returnSelect+`
+		WHERE rr.return_id = $1
+		FOR UPDATE`, returnID))
+	if err == sql.ErrNoRows {
+		return ReturnRequest{}, ErrReturnNotFound
+	} else if err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to get return request: %v", err)
+	}
+	if r.Status != ReturnRequested {
+		return ReturnRequest{}, fmt.Errorf("%w: return %d is %s", ErrReturnDecided, returnID, r.Status)
+	}
+	return r, nil
+}
+
+// ApproveReturn อนุมัติคำขอคืนสินค้าโดย actor (seller หรือ admin) ภายใน transaction เดียว:
+// บันทึกวิธีจัดการของแต่ละรายการ คืนเข้าคลังหรือตัดทิ้งใน stock_movements สร้างรายการคืนเงิน
+// และเปลี่ยนการจัดส่งเป็น returned เมื่อทุกรายการในการจัดส่งถูกคืนครบแล้ว
+func (pdb *PostgresDatabase) ApproveReturn(ctx context.Context, returnID int, d ReturnDecision, actor, userID string) (ReturnRequest, error) {
+	if err := d.Validate(false); err != nil {
+		return ReturnRequest{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	r, err := lockPendingReturn(ctx, tx, returnID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, err
+	}
+
+	items, err := queryReturnItems(ctx, tx, returnID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, err
+	}
+	known := make(map[int]bool, len(items))
+	for _, item := range items {
+		known[item.ID] = true
+	}
+	for id := range d.Dispositions {
+		if !known[id] {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("%w: return item %d is not part of return %d", ErrInvalidReturn, id, returnID)
+		}
+	}
+
+	restock := make(map[int]int)
+	writeOff := make(map[int]int)
+	var refund float64
+	for _, item := range items {
+		disposition := d.Dispositions[item.ID]
+		if disposition == "" {
+			disposition = DispositionRestock
+		}
+		if disposition == DispositionWriteOff {
+			writeOff[item.VariantID] += item.Quantity
+		} else {
+			restock[item.VariantID] += item.Quantity
+		}
+		refund += item.Amount
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE return_items SET disposition = $2 WHERE return_item_id = $1`, item.ID, disposition)
+		if err != nil {
+			tx.Rollback()
+			return ReturnRequest{}, fmt.Errorf("failed to update return item: %v", err)
+		}
+	}
+
+	template := inventory.Movement{
+		Reason:    inventory.ReasonReturn,
+		Reference: fmt.Sprintf("return:%d", returnID),
+		Note:      d.Note,
+		CreatedBy: &userID,
+	}
+	if err := inventory.Restore(ctx, tx, restock, template); err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, err
+	}
+	if err := inventory.WriteOff(ctx, tx, writeOff, template); err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refunds (return_id, order_id, amount, created_by)
+		VALUES ($1, $2, ROUND($3::numeric, 2), $4)`, returnID, r.OrderID, refund, userID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to create refund: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE return_requests
+		SET status = $2, decision_note = NULLIF($3, ''), decided_by = $4, decided_at = CURRENT_TIMESTAMP
+		WHERE return_id = $1`, returnID, ReturnApproved, d.Note, userID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to approve return request: %v", err)
+	}
+
+	// การจัดส่งถูกคืนครบเมื่อทุกรายการถูกอนุมัติให้คืนเต็มจำนวนแล้ว
+	shipment := lockedShipment{id: r.ShipmentID, orderID: r.OrderID, sellerID: r.SellerID}
+	var fullyReturned bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT sh.status, NOT EXISTS (
+			SELECT 1 FROM order_items oi
+			WHERE oi.order_id = sh.order_id AND oi.seller_id = sh.seller_id
+			  AND oi.quantity > COALESCE((
+			      SELECT SUM(ri.quantity) FROM return_items ri
+			      JOIN return_requests rr ON rr.return_id = ri.return_id
+			      WHERE ri.order_item_id = oi.order_item_id AND rr.status = 'approved'), 0))
+		FROM shipments sh
+		WHERE sh.shipment_id = $1
+		FOR UPDATE OF sh`, shipment.id).Scan(&shipment.status, &fullyReturned)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to check shipment: %v", err)
+	}
+	if fullyReturned && shipment.status != fulfillment.Returned {
+		transition := ShipmentTransition{Status: fulfillment.Returned, Note: fmt.Sprintf("return %d approved", returnID)}
+		if err := applyShipmentTransition(ctx, tx, shipment, transition, actor, userID); err != nil {
+			tx.Rollback()
+			return ReturnRequest{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.GetReturn(ctx, returnID)
+}
+
+// RejectReturn ปฏิเสธคำขอคืนสินค้าพร้อมเหตุผล จำนวนที่ขอคืนในคำขอนี้กลับมาขอคืนใหม่ได้
+func (pdb *PostgresDatabase) RejectReturn(ctx context.Context, returnID int, d ReturnDecision, userID string) (ReturnRequest, error) {
+	if err := d.Validate(true); err != nil {
+		return ReturnRequest{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	if _, err := lockPendingReturn(ctx, tx, returnID); err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE return_requests
+		SET status = $2, decision_note = $3, decided_by = $4, decided_at = CURRENT_TIMESTAMP
+		WHERE return_id = $1`, returnID, ReturnRejected, d.Note, userID)
+	if err != nil {
+		tx.Rollback()
+		return ReturnRequest{}, fmt.Errorf("failed to reject return request: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ReturnRequest{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.GetReturn(ctx, returnID)
+}
@@ -0,0 +1,130 @@
+// safety.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"productproject/internal/safety"
+
+	"github.com/lib/pq"
+)
+
+// ChildAtRisk เด็กในโปรไฟล์ของผู้ใช้ที่อายุยังไม่ถึงอายุขั้นต่ำของสินค้าที่มีความเสี่ยง
+type ChildAtRisk struct {
+	ChildID   int    `json:"child_id"`
+	Name      string `json:"name"`
+	AgeMonths int    `json:"age_months"`
+}
+
+// SafetyAcknowledgementError ถูกส่งคืนเมื่อเพิ่มสินค้าที่มีความเสี่ยงลงตะกร้าของผู้ใช้ที่มีลูกอายุต่ำกว่าอายุขั้นต่ำ
+// โดยยังไม่ได้ยืนยันว่ารับทราบคำเตือน
+type SafetyAcknowledgementError struct {
+	ProductID    int             `json:"product_id"`
+	Name         string          `json:"name"`
+	MinAgeMonths int             `json:"min_age_months"`
+	Warnings     []safety.Hazard `json:"warnings"`
+	Children     []ChildAtRisk   `json:"children"`
+}
+
+func (e *SafetyAcknowledgementError) Error() string {
+	names := make([]string, len(e.Children))
+	for i, child := range e.Children {
+		names[i] = child.Name
+	}
+	return fmt.Sprintf("product %d is not suitable for children under %d months (%s): safety acknowledgement required",
+		e.ProductID, e.MinAgeMonths, strings.Join(names, ", "))
+}
+
+// querySafetyLabel ดึงฉลากความปลอดภัยและอายุขั้นต่ำที่ระบุไว้ของสินค้า
+func querySafetyLabel(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}, productID int) (safety.Label, *int, error) {
+	var label safety.Label
+	var minAge *int
+	err := q.QueryRowContext(ctx, `
+		SELECT hazards, COALESCE(tis_number, ''), certifications, min_age_months
+		FROM products
+		WHERE product_id = $1 AND deleted_at IS NULL`, productID).Scan(
+		pq.Array(&label.Hazards), &label.TISNumber, pq.Array(&label.Certifications), &minAge)
+	if err == sql.ErrNoRows {
+		return safety.Label{}, nil, ErrProductNotFound
+	} else if err != nil {
+		return safety.Label{}, nil, fmt.Errorf("failed to get safety label: %v", err)
+	}
+	label.Resolve()
+	return label, minAge, nil
+}
+
+// updateSafety บันทึกฉลากความปลอดภัย (label เป็น nil คือไม่แก้) แล้วตรวจว่าอายุขั้นต่ำของสินค้า
+// ไม่ต่ำกว่าอายุที่ความเสี่ยงบนฉลากกำหนด เช่นสินค้าที่มีชิ้นส่วนเล็กต้องไม่ระบุว่าเหมาะกับเด็กต่ำกว่า 3 ปี
+func updateSafety(ctx context.Context, tx *sql.Tx, productID int, label *safety.Label) error {
+	if label != nil {
+		var tisNumber *string
+		if label.TISNumber != "" {
+			tisNumber = &label.TISNumber
+		}
+		_, err := tx.ExecContext(ctx, `
+			UPDATE products SET hazards = $2, tis_number = $3, certifications = $4
+			WHERE product_id = $1`, productID, pq.Array(label.Hazards), tisNumber, pq.Array(label.Certifications))
+		if err != nil {
+			return fmt.Errorf("failed to update safety label: %v", err)
+		}
+	}
+
+	current, minAge, err := querySafetyLabel(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+	if required := current.MinAgeMonths(); minAge != nil && *minAge < required {
+		return fmt.Errorf("%w: min_age_months must be at least %d for hazards %s",
+			ErrInvalidProduct, required, strings.Join(current.Hazards, ", "))
+	}
+	return nil
+}
+
+// checkCartSafety ตรวจสินค้าที่กำลังเพิ่มลงตะกร้ากับโปรไฟล์ลูกของผู้ใช้
+// คืนฉลากของสินค้า อายุขั้นต่ำ (อายุที่มากกว่าระหว่างความเสี่ยงบนฉลากกับ min_age_months ของสินค้า)
+// และเด็กที่อายุยังไม่ถึง ซึ่งมีได้เฉพาะสินค้าที่มีความเสี่ยงเท่านั้น
+func checkCartSafety(ctx context.Context, tx *sql.Tx, userID string, productID int) (safety.Label, int, []ChildAtRisk, error) {
+	label, productMinAge, err := querySafetyLabel(ctx, tx, productID)
+	if err != nil {
+		return safety.Label{}, 0, nil, err
+	}
+	minAge := label.AcknowledgementAge(productMinAge)
+	if minAge == 0 {
+		return label, 0, nil, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT child_id, name, birth_month FROM child_profiles
+		WHERE user_id = $1
+		ORDER BY child_id`, userID)
+	if err != nil {
+		return safety.Label{}, 0, nil, fmt.Errorf("failed to query child profiles: %v", err)
+	}
+	defer rows.Close()
+
+	var atRisk []ChildAtRisk
+	now := time.Now()
+	for rows.Next() {
+		var child ChildAtRisk
+		var birthMonth time.Time
+		if err := rows.Scan(&child.ChildID, &child.Name, &birthMonth); err != nil {
+			return safety.Label{}, 0, nil, fmt.Errorf("failed to scan child profile: %v", err)
+		}
+		child.AgeMonths = max(0, ageInMonths(birthMonth, now))
+		if label.RequiresAcknowledgement(child.AgeMonths, productMinAge) {
+			atRisk = append(atRisk, child)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safety.Label{}, 0, nil, fmt.Errorf("failed to iterate child profiles: %v", err)
+	}
+
+	return label, minAge, atRisk, nil
+}
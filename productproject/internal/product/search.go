@@ -0,0 +1,140 @@
+// search.go
+package product
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"productproject/internal/search"
+)
+
+// maxSearchHits จำนวนผลค้นหาสูงสุดที่ส่งต่อไปกรองและแบ่งหน้าใน ListProducts
+const maxSearchHits = 1000
+
+// maxSuggestions จำนวนคำแนะนำสูงสุดต่อครั้ง
+const maxSuggestions = 20
+
+// ช่วงเวลาของยอดขายที่ใช้วัดความนิยมของสินค้าในคำแนะนำ
+const popularityWindow = "90 days"
+
+// loadSearchDocuments ดึงข้อมูลสินค้าที่ยังขายอยู่ (ไม่รวมสินค้าที่ถูกเรียกคืนทั้งชิ้น) สำหรับทำ index (productID เป็น 0 คือทุกชิ้น)
+// ชื่อหมวดหมู่รวมชื่อหมวดหมู่แม่ทุกระดับ ความนิยมคือจำนวนชิ้นที่ขายได้ในช่วง popularityWindow
+func (pdb *PostgresDatabase) loadSearchDocuments(ctx context.Context, productID int) ([]search.Document, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		WITH RECURSIVE category_path AS (
+			SELECT category_id AS leaf_id, name, parent_id FROM categories
+			UNION ALL
+			SELECT cp.leaf_id, c.name, c.parent_id
+			FROM categories c JOIN category_path cp ON c.category_id = cp.parent_id
+		)
+		SELECT p.product_id, p.name, COALESCE(p.description, ''), COALESCE(p.brand, ''),
+		       COALESCE((SELECT string_agg(cp.name, ' ') FROM category_path cp WHERE cp.leaf_id = p.category_id), ''),
+		       p.category_id, COALESCE(c.name, ''),
+		       COALESCE(s.name, ''), COALESCE(sales.sold, 0)
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.category_id
+		LEFT JOIN sellers s ON p.seller_id = s.seller_id
+		LEFT JOIN (
+			SELECT oi.product_id, SUM(oi.quantity) AS sold
+			FROM order_items oi
+			JOIN orders o ON o.order_id = oi.order_id
+			WHERE o.order_date >= CURRENT_TIMESTAMP - INTERVAL '`+popularityWindow+`'
+			GROUP BY oi.product_id
+		) sales ON sales.product_id = p.product_id
+		WHERE p.deleted_at IS NULL AND s.deactivated_at IS NULL AND NOT `+productRecalled+`
+		  AND ($1 = 0 OR p.product_id = $1)
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products for search index: %v", err)
+	}
+	defer rows.Close()
+
+	var docs []search.Document
+	for rows.Next() {
+		var doc search.Document
+		if err := rows.Scan(&doc.ProductID, &doc.Name, &doc.Description, &doc.Brand, &doc.Category,
+			&doc.CategoryID, &doc.CategoryName, &doc.Seller, &doc.Popularity); err != nil {
+			return nil, fmt.Errorf("failed to scan product for search index: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate products for search index: %v", err)
+	}
+
+	return docs, nil
+}
+
+// RefreshSearchIndex สร้าง index สำหรับค้นหาสินค้าใหม่ทั้งหมดจากฐานข้อมูล
+func (pdb *PostgresDatabase) RefreshSearchIndex(ctx context.Context) error {
+	docs, err := pdb.loadSearchDocuments(ctx, 0)
+	if err != nil {
+		return err
+	}
+	pdb.searchIndex.Replace(docs)
+	return nil
+}
+
+// reindexProduct อัปเดต index ของสินค้าหนึ่งชิ้นหลังถูกแก้ไข
+// หากล้มเหลวจะแค่ log ไว้ index จะถูกแก้ให้ถูกต้องในรอบ RefreshSearchIndex ถัดไป
+func (pdb *PostgresDatabase) reindexProduct(ctx context.Context, productID int) {
+	docs, err := pdb.loadSearchDocuments(ctx, productID)
+	if err != nil {
+		log.Printf("Failed to update search index for product %d: %v", productID, err)
+		return
+	}
+	if len(docs) == 0 {
+		pdb.searchIndex.Remove(productID)
+		return
+	}
+	pdb.searchIndex.Put(docs[0])
+}
+
+// refreshSearchIndexAfter สร้าง index ใหม่หลังการเปลี่ยนแปลงที่กระทบสินค้าหลายชิ้น
+// (เช่นปิดร้านหรือเปลี่ยนชื่อหมวดหมู่) หากล้มเหลวจะแค่ log ไว้
+func (pdb *PostgresDatabase) refreshSearchIndexAfter(ctx context.Context, change string) {
+	if err := pdb.RefreshSearchIndex(ctx); err != nil {
+		log.Printf("Failed to refresh search index after %s: %v", change, err)
+	}
+}
+
+// SearchProducts ค้นหาสินค้าจากชื่อ คำอธิบาย แบรนด์ หมวดหมู่ และชื่อร้าน
+// เรียงตามความเกี่ยวข้องเป็นค่าเริ่มต้น (sort=relevance) และใช้ตัวกรองกับการแบ่งหน้าแบบเดียวกับ ListProducts
+// ผลลัพธ์มี facet ของสินค้าทั้งหมดที่ตรงกับคำค้นและตัวกรองด้วย
+// ระหว่างที่ index ยังสร้างไม่เสร็จจะค้นหาจากชื่อสินค้าด้วย ILIKE แทน
+func (pdb *PostgresDatabase) SearchProducts(ctx context.Context, query string, q ListingQuery) (ProductPage, error) {
+	q.withFacets = true
+	if !pdb.searchIndex.Ready() {
+		if q.Sort == sortRelevance {
+			q.Sort = ""
+		}
+		q.Search = query
+		return pdb.ListProducts(ctx, q)
+	}
+
+	hits := pdb.searchIndex.Search(query, maxSearchHits)
+	if len(hits) == 0 {
+		return ProductPage{Items: []ProductItem{}, Facets: newFacets()}, nil
+	}
+
+	q.rankedIDs = make([]int64, len(hits))
+	for i, hit := range hits {
+		q.rankedIDs[i] = int64(hit.ProductID)
+	}
+	if q.Sort == "" {
+		q.Sort = sortRelevance
+	}
+
+	return pdb.ListProducts(ctx, q)
+}
+
+// SuggestProducts แนะนำชื่อสินค้า แบรนด์ และหมวดหมู่ที่ขึ้นต้นด้วย prefix สำหรับช่องค้นหา
+// อ่านจาก index ในหน่วยความจำเท่านั้น (ไม่เรียกฐานข้อมูล) ระหว่างที่ index ยังไม่พร้อมจะคืนรายการว่าง
+func (pdb *PostgresDatabase) SuggestProducts(ctx context.Context, prefix string, limit int) ([]search.Suggestion, error) {
+	if limit <= 0 || limit > maxSuggestions {
+		limit = maxSuggestions
+	}
+	return pdb.searchIndex.Suggest(prefix, limit), nil
+}
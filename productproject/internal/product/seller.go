@@ -0,0 +1,230 @@
+// seller.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidSeller ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลร้านค้าไม่ผ่านการตรวจสอบ
+var ErrInvalidSeller = errors.New("invalid seller")
+
+// ErrSellerExists ถูกส่งคืนเมื่อผู้ใช้มีร้านอยู่แล้วหรือชื่อร้านถูกใช้ไปแล้ว
+var ErrSellerExists = errors.New("seller already exists")
+
+// ErrSellerNotFound ถูกส่งคืนเมื่อไม่พบร้านค้า (หรือร้านถูกปิดไปแล้ว)
+var ErrSellerNotFound = errors.New("seller not found")
+
+// เบอร์โทรไทย 10 หลักขึ้นต้นด้วย 0 ตรงกับ CHECK ของ sellers.phone_number
+var thaiPhonePattern = regexp.MustCompile(`^0[0-9]{9}$`)
+
+// NewSeller ข้อมูลสำหรับลงทะเบียนร้านค้า
+type NewSeller struct {
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	PhoneNumber string `json:"phone_number"`
+	Email       string `json:"email"`
+	Description string `json:"description"`
+}
+
+// UpdateSeller ข้อมูลร้านค้าที่เจ้าของแก้ได้ ฟิลด์ที่เป็น nil จะไม่ถูกแก้
+type UpdateSeller struct {
+	Address     *string `json:"address"`
+	PhoneNumber *string `json:"phone_number"`
+	Email       *string `json:"email"`
+	Description *string `json:"description"`
+}
+
+// Validate ตรวจสอบข้อมูลร้านค้าใหม่
+func (s NewSeller) Validate() error {
+	name := strings.TrimSpace(s.Name)
+	if name == "" || len(name) > 255 {
+		return fmt.Errorf("%w: name is required and must be at most 255 characters", ErrInvalidSeller)
+	}
+	if s.PhoneNumber == "" {
+		return fmt.Errorf("%w: phone_number is required", ErrInvalidSeller)
+	}
+	return UpdateSeller{
+		Address:     &s.Address,
+		PhoneNumber: &s.PhoneNumber,
+		Email:       &s.Email,
+	}.Validate()
+}
+
+// Validate ตรวจสอบเฉพาะฟิลด์ที่ระบุมา ลบเบอร์โทรออกไม่ได้ ส่วน email เว้นว่างได้
+func (u UpdateSeller) Validate() error {
+	if u.Address != nil && len(*u.Address) > 255 {
+		return fmt.Errorf("%w: address must be at most 255 characters", ErrInvalidSeller)
+	}
+	if u.PhoneNumber != nil && !thaiPhonePattern.MatchString(*u.PhoneNumber) {
+		return fmt.Errorf("%w: phone_number must be a 10-digit Thai number starting with 0", ErrInvalidSeller)
+	}
+	if u.Email != nil && *u.Email != "" {
+		addr, err := mail.ParseAddress(*u.Email)
+		if err != nil || addr.Address != *u.Email || len(*u.Email) > 50 {
+			return fmt.Errorf("%w: email must be a valid address of at most 50 characters", ErrInvalidSeller)
+		}
+	}
+	return nil
+}
+
+// RegisterSeller สร้างร้านค้าใหม่ ผูกกับบัญชี userID และเปลี่ยน role ของบัญชีเป็น seller
+// (ผู้ดูแลระบบยังคง role admin) บัญชีหนึ่งมีร้านได้ร้านเดียว
+func (pdb *PostgresDatabase) RegisterSeller(ctx context.Context, userID string, s NewSeller) (Seller, error) {
+	if err := s.Validate(); err != nil {
+		return Seller{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Seller{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	// ล็อกแถวผู้ใช้ไว้ กันการลงทะเบียนซ้ำพร้อมกัน
+	var role string
+	err = tx.QueryRowContext(ctx, `SELECT role FROM users WHERE user_id = $1 FOR UPDATE`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("%w: user %s does not exist", ErrInvalidSeller, userID)
+	} else if err != nil {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM sellers WHERE user_id = $1)`, userID).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("failed to check seller: %v", err)
+	}
+	if exists {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("%w: user already has a shop", ErrSellerExists)
+	}
+
+	name := strings.TrimSpace(s.Name)
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM sellers WHERE LOWER(name) = LOWER($1))`, name).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("failed to check seller name: %v", err)
+	}
+	if exists {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("%w: shop name %q is already taken", ErrSellerExists, name)
+	}
+
+	seller := Seller{Name: name, Address: s.Address, PhoneNumber: s.PhoneNumber, Email: s.Email, Description: s.Description}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO sellers (name, address, phone_number, email, description, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING seller_id`,
+		seller.Name, seller.Address, seller.PhoneNumber, seller.Email, seller.Description, userID,
+	).Scan(&seller.ID)
+	if isUniqueViolation(err) {
+		// อีกบัญชีลงทะเบียนชื่อเดียวกันพร้อมกัน ฐานข้อมูลจึงปฏิเสธด้วย idx_sellers_name_lower
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("%w: shop name %q is already taken", ErrSellerExists, name)
+	} else if err != nil {
+		tx.Rollback()
+		return Seller{}, fmt.Errorf("failed to create seller: %v", err)
+	}
+
+	if role == "customer" {
+		_, err = tx.ExecContext(ctx, `UPDATE users SET role = 'seller' WHERE user_id = $1`, userID)
+		if err != nil {
+			tx.Rollback()
+			return Seller{}, fmt.Errorf("failed to update user role: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Seller{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return seller, nil
+}
+
+// UpdateSeller แก้ข้อมูลติดต่อของร้านเฉพาะฟิลด์ที่ระบุ
+func (pdb *PostgresDatabase) UpdateSeller(ctx context.Context, sellerID int, u UpdateSeller) (Seller, error) {
+	if err := u.Validate(); err != nil {
+		return Seller{}, err
+	}
+
+	result, err := pdb.db.ExecContext(ctx, `
+		UPDATE sellers SET
+			address = COALESCE($1, address),
+			phone_number = COALESCE($2, phone_number),
+			email = COALESCE($3, email),
+			description = COALESCE($4, description)
+		WHERE seller_id = $5 AND deactivated_at IS NULL`,
+		u.Address, u.PhoneNumber, u.Email, u.Description, sellerID)
+	if err != nil {
+		return Seller{}, fmt.Errorf("failed to update seller: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return Seller{}, fmt.Errorf("failed to check updated seller: %v", err)
+	}
+	if rowsAffected == 0 {
+		return Seller{}, ErrSellerNotFound
+	}
+
+	return pdb.GetSeller(ctx, fmt.Sprint(sellerID))
+}
+
+// DeactivateSeller ปิดร้าน สินค้าของร้านจะไม่แสดงและถูกนำออกจากตะกร้าที่ยังไม่สั่งซื้อ
+// คำสั่งซื้อเดิมยังอยู่ครบ
+func (pdb *PostgresDatabase) DeactivateSeller(ctx context.Context, sellerID int) error {
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE sellers SET deactivated_at = CURRENT_TIMESTAMP
+		WHERE seller_id = $1 AND deactivated_at IS NULL`, sellerID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to deactivate seller: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check deactivated seller: %v", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return ErrSellerNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM cart_items ci
+		USING products p
+		WHERE p.product_id = ci.product_id AND p.seller_id = $1 AND ci.added_to_cart = FALSE`, sellerID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to remove seller products from carts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	pdb.refreshSearchIndexAfter(ctx, "seller deactivation")
+	return nil
+}
+
+// isUniqueViolation ตรวจว่า err มาจาก unique constraint หรือ unique index ของ PostgreSQL (SQLSTATE 23505)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,146 @@
+// seller_orders.go
+package product
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// จำนวนคำสั่งซื้อต่อหน้าของกล่องคำสั่งซื้อผู้ขาย
+const (
+	DefaultSellerOrderLimit = 50
+	MaxSellerOrderLimit     = 200
+)
+
+// SellerOrder คำสั่งซื้อในมุมของร้านหนึ่งร้าน: การจัดส่งของร้าน ที่อยู่จัดส่งของผู้ซื้อ
+// และเฉพาะรายการของร้านนั้น (ไม่เห็นรายการของร้านอื่นในคำสั่งซื้อเดียวกัน)
+type SellerOrder struct {
+	OrderID        int               `json:"order_id"`
+	OrderDate      time.Time         `json:"order_date"`
+	ShipmentID     int               `json:"shipment_id"`
+	Status         string            `json:"status"`
+	Carrier        string            `json:"carrier"`
+	TrackingNumber string            `json:"tracking_number"`
+	Buyer          SellerOrderBuyer  `json:"buyer"`
+	Total          float64           `json:"total"` // ยอดรวมของรายการของร้านนี้
+	Items          []SellerOrderItem `json:"items"`
+}
+
+// SellerOrderBuyer ข้อมูลผู้ซื้อที่ร้านต้องใช้จัดส่ง
+type SellerOrderBuyer struct {
+	UserID  string  `json:"user_id"`
+	Name    string  `json:"name"`
+	Email   string  `json:"email"`
+	Phone   *string `json:"phone"`
+	Address *string `json:"address"`
+}
+
+// SellerOrderItem รายการของร้านในคำสั่งซื้อ ราคาและส่วนลด ณ เวลาสั่งซื้อ
+type SellerOrderItem struct {
+	OrderItemID int     `json:"order_item_id"`
+	CartItemID  int     `json:"cart_item_id"`
+	ProductID   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	VariantID   int     `json:"variant_id"`
+	SKU         string  `json:"sku"`
+	VariantName string  `json:"variant_name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Discount    int     `json:"discount"`
+	LineTotal   float64 `json:"line_total"`
+}
+
+// SellerOrderQuery ตัวกรองของกล่องคำสั่งซื้อ Status ว่างคือทุกสถานะ
+// From / To เป็นช่วงวันที่สั่งซื้อ [From, To) ค่า nil คือไม่จำกัด
+type SellerOrderQuery struct {
+	Status string
+	From   *time.Time
+	To     *time.Time
+	Limit  int // 0 คือใช้ DefaultSellerOrderLimit
+	Offset int
+}
+
+// GetSellerOrders คืนคำสั่งซื้อที่มีรายการของร้าน sellerID เรียงจากล่าสุด พร้อมที่อยู่จัดส่งของผู้ซื้อ
+func (pdb *PostgresDatabase) GetSellerOrders(ctx context.Context, sellerID int, q SellerOrderQuery) ([]SellerOrder, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSellerOrderLimit
+	}
+	q.Limit = min(q.Limit, MaxSellerOrderLimit)
+
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT o.order_id, o.order_date, sh.shipment_id, sh.status,
+		       COALESCE(sh.carrier, ''), COALESCE(sh.tracking_number, ''),
+		       COALESCE(u.user_id::text, ''), COALESCE(NULLIF(u.display_name, ''), u.full_name, ''),
+		       COALESCE(u.email, ''), u.phone, u.address
+		FROM shipments sh
+		JOIN orders o ON o.order_id = sh.order_id
+		LEFT JOIN users u ON u.user_id = o.user_id
+		WHERE sh.seller_id = $1
+		  AND ($2 = '' OR sh.status = $2)
+		  AND ($3::timestamptz IS NULL OR o.order_date >= $3)
+		  AND ($4::timestamptz IS NULL OR o.order_date < $4)
+		ORDER BY o.order_date DESC, o.order_id DESC
+		LIMIT $5 OFFSET $6`, sellerID, q.Status, q.From, q.To, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seller orders: %v", err)
+	}
+	defer rows.Close()
+
+	orders := []SellerOrder{}
+	index := make(map[int]int)
+	var orderIDs []int64
+	for rows.Next() {
+		var o SellerOrder
+		if err := rows.Scan(&o.OrderID, &o.OrderDate, &o.ShipmentID, &o.Status, &o.Carrier, &o.TrackingNumber,
+			&o.Buyer.UserID, &o.Buyer.Name, &o.Buyer.Email, &o.Buyer.Phone, &o.Buyer.Address); err != nil {
+			return nil, fmt.Errorf("failed to scan seller order: %v", err)
+		}
+		o.Items = []SellerOrderItem{}
+		index[o.OrderID] = len(orders)
+		orders = append(orders, o)
+		orderIDs = append(orderIDs, int64(o.OrderID))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate seller orders: %v", err)
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	// ดึงเฉพาะรายการของร้านนี้ในคำสั่งซื้อของหน้านี้
+	itemRows, err := pdb.db.QueryContext(ctx, `
+		SELECT oi.order_id, oi.order_item_id, oi.cart_item_id, oi.product_id, COALESCE(p.name, ''),
+		       oi.variant_id, COALESCE(v.sku, ''), COALESCE(v.name, ''),
+		       oi.quantity, oi.unit_price, oi.discount, oi.line_total
+		FROM order_items oi
+		LEFT JOIN products p ON p.product_id = oi.product_id
+		LEFT JOIN product_variants v ON v.variant_id = oi.variant_id
+		WHERE oi.seller_id = $1 AND oi.order_id = ANY($2)
+		ORDER BY oi.order_id, oi.order_item_id`, sellerID, pq.Array(orderIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seller order items: %v", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var orderID int
+		var item SellerOrderItem
+		if err := itemRows.Scan(&orderID, &item.OrderItemID, &item.CartItemID, &item.ProductID, &item.ProductName,
+			&item.VariantID, &item.SKU, &item.VariantName,
+			&item.Quantity, &item.UnitPrice, &item.Discount, &item.LineTotal); err != nil {
+			return nil, fmt.Errorf("failed to scan seller order item: %v", err)
+		}
+		o := &orders[index[orderID]]
+		o.Items = append(o.Items, item)
+		o.Total = math.Round((o.Total+item.LineTotal)*100) / 100
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate seller order items: %v", err)
+	}
+
+	return orders, nil
+}
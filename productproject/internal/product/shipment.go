@@ -0,0 +1,315 @@
+// shipment.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"productproject/internal/fulfillment"
+)
+
+// ErrInvalidShipment ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลการเปลี่ยนสถานะการจัดส่งไม่ผ่านการตรวจสอบ
+var ErrInvalidShipment = errors.New("invalid shipment update")
+
+// ErrShipmentNotFound ถูกส่งคืนเมื่อไม่พบการจัดส่ง
+var ErrShipmentNotFound = errors.New("shipment not found")
+
+// statusColumn คอลัมน์ที่เก็บเวลาที่การจัดส่งเข้าสู่แต่ละสถานะ
+var statusColumn = map[string]string{
+	fulfillment.Processing: "processing_at",
+	fulfillment.Packed:     "packed_at",
+	fulfillment.Shipping:   "shipping_at",
+	fulfillment.Delivered:  "delivered_at",
+	fulfillment.Received:   "received_at",
+	fulfillment.Cancelled:  "cancelled_at",
+	fulfillment.Returned:   "returned_at",
+}
+
+// Shipment การจัดส่งของร้านหนึ่งในคำสั่งซื้อ พร้อมเวลาที่เข้าสู่แต่ละสถานะ (nil คือยังไม่เคยเข้า)
+// CustomerID คือผู้ใช้ที่สั่งซื้อ History มีเฉพาะเมื่อดึงการจัดส่งทีละรายการ
+type Shipment struct {
+	ID             int             `json:"shipment_id"`
+	OrderID        int             `json:"order_id"`
+	SellerID       int             `json:"seller_id"`
+	SellerName     string          `json:"seller_name"`
+	CustomerID     string          `json:"customer_id"`
+	Status         string          `json:"status"`
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"tracking_number"`
+	ProcessingAt   *time.Time      `json:"processing_at"`
+	PackedAt       *time.Time      `json:"packed_at"`
+	ShippingAt     *time.Time      `json:"shipping_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	ReceivedAt     *time.Time      `json:"received_at"`
+	CancelledAt    *time.Time      `json:"cancelled_at"`
+	ReturnedAt     *time.Time      `json:"returned_at"`
+	CancelledBy    *string         `json:"cancelled_by,omitempty"`
+	CancelReason   string          `json:"cancellation_reason,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	History        []ShipmentEvent `json:"history,omitempty"`
+}
+
+// ShipmentEvent การเปลี่ยนสถานะหนึ่งครั้ง FromStatus เป็น nil คือตอนสร้างการจัดส่ง
+type ShipmentEvent struct {
+	ID         int64     `json:"event_id"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Note       string    `json:"note"`
+	CreatedBy  *string   `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShipmentTransition ข้อมูลสำหรับเปลี่ยนสถานะการจัดส่ง Carrier และ TrackingNumber ที่เป็น nil จะไม่ถูกแก้
+type ShipmentTransition struct {
+	Status         string  `json:"status"`
+	Note           string  `json:"note"`
+	Carrier        *string `json:"carrier"`
+	TrackingNumber *string `json:"tracking_number"`
+}
+
+// Validate ตรวจสอบและตัดช่องว่างของข้อมูลการเปลี่ยนสถานะ (ลำดับของสถานะตรวจใน fulfillment.Transition)
+func (t *ShipmentTransition) Validate() error {
+	t.Status = strings.ToLower(strings.TrimSpace(t.Status))
+	if t.Status == "" {
+		return fmt.Errorf("%w: status is required", ErrInvalidShipment)
+	}
+	t.Note = strings.TrimSpace(t.Note)
+	if len([]rune(t.Note)) > 500 {
+		return fmt.Errorf("%w: note must be at most 500 characters", ErrInvalidShipment)
+	}
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{{"carrier", t.Carrier}, {"tracking_number", t.TrackingNumber}} {
+		if field.value == nil {
+			continue
+		}
+		*field.value = strings.TrimSpace(*field.value)
+		if len([]rune(*field.value)) > 100 {
+			return fmt.Errorf("%w: %s must be at most 100 characters", ErrInvalidShipment, field.name)
+		}
+	}
+	return nil
+}
+
+// shipmentSelect คอลัมน์ของการจัดส่งตามลำดับที่ scanShipment อ่าน
+const shipmentSelect = `
+	SELECT sh.shipment_id, sh.order_id, sh.seller_id, COALESCE(s.name, ''), COALESCE(o.user_id::text, ''),
+	       sh.status, COALESCE(sh.carrier, ''), COALESCE(sh.tracking_number, ''),
+	       sh.processing_at, sh.packed_at, sh.shipping_at, sh.delivered_at, sh.received_at, sh.cancelled_at, sh.returned_at,
+	       sh.cancelled_by, COALESCE(sh.cancellation_reason, ''), sh.created_at, sh.updated_at
+	FROM shipments sh
+	JOIN orders o ON o.order_id = sh.order_id
+	LEFT JOIN sellers s ON s.seller_id = sh.seller_id`
+
+func scanShipment(row interface{ Scan(...interface{}) error }) (Shipment, error) {
+	var sh Shipment
+	err := row.Scan(&sh.ID, &sh.OrderID, &sh.SellerID, &sh.SellerName, &sh.CustomerID,
+		&sh.Status, &sh.Carrier, &sh.TrackingNumber,
+		&sh.ProcessingAt, &sh.PackedAt, &sh.ShippingAt, &sh.DeliveredAt, &sh.ReceivedAt, &sh.CancelledAt, &sh.ReturnedAt,
+		&sh.CancelledBy, &sh.CancelReason, &sh.CreatedAt, &sh.UpdatedAt)
+	return sh, err
+}
+
+// createShipments สร้างการจัดส่งสถานะ processing ให้ทุกร้านในคำสั่งซื้อ พร้อมบันทึกประวัติแรก
+// ต้องเรียกหลังเพิ่ม order_items ของคำสั่งซื้อแล้ว
+func createShipments(ctx context.Context, tx *sql.Tx, orderID int, userID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO shipments (order_id, seller_id)
+		SELECT DISTINCT order_id, seller_id FROM order_items WHERE order_id = $1`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to create shipments: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO shipment_events (shipment_id, to_status, created_by)
+		SELECT shipment_id, status, $2 FROM shipments WHERE order_id = $1`, orderID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record shipment history: %v", err)
+	}
+	return nil
+}
+
+// GetShipments แสดงการจัดส่งทุกร้านของคำสั่งซื้อ เรียงตามร้าน
+func (pdb *PostgresDatabase) GetShipments(ctx context.Context, orderID int) ([]Shipment, error) {
+	rows, err := pdb.db.QueryContext(ctx, shipmentSelect+`
+		WHERE sh.order_id = $1
+		ORDER BY sh.seller_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipments: %v", err)
+	}
+	defer rows.Close()
+
+	shipments := []Shipment{}
+	for rows.Next() {
+		sh, err := scanShipment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shipment: %v", err)
+		}
+		shipments = append(shipments, sh)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shipments: %v", err)
+	}
+
+	return shipments, nil
+}
+
+// GetShipment แสดงการจัดส่งหนึ่งรายการพร้อมประวัติการเปลี่ยนสถานะทั้งหมด
+func (pdb *PostgresDatabase) GetShipment(ctx context.Context, shipmentID int) (Shipment, error) {
+	sh, err := scanShipment(pdb.db.QueryRowContext(ctx, shipmentSelect+`
+		WHERE sh.shipment_id = $1`, shipmentID))
+	if err == sql.ErrNoRows {
+		return Shipment{}, ErrShipmentNotFound
+	} else if err != nil {
+		return Shipment{}, fmt.Errorf("failed to get shipment: %v", err)
+	}
+
+	sh.History, err = pdb.queryShipmentEvents(ctx, shipmentID)
+	if err != nil {
+		return Shipment{}, err
+	}
+	return sh, nil
+}
+
+// GetSellerShipment แสดงการจัดส่งของร้าน sellerID ในคำสั่งซื้อ (ไม่รวมประวัติ)
+func (pdb *PostgresDatabase) GetSellerShipment(ctx context.Context, orderID, sellerID int) (Shipment, error) {
+	sh, err := scanShipment(pdb.db.QueryRowContext(ctx, shipmentSelect+`
+		WHERE sh.order_id = $1 AND sh.seller_id = $2`, orderID, sellerID))
+	if err == sql.ErrNoRows {
+		return Shipment{}, ErrShipmentNotFound
+	} else if err != nil {
+		return Shipment{}, fmt.Errorf("failed to get shipment: %v", err)
+	}
+	return sh, nil
+}
+
+func (pdb *PostgresDatabase) queryShipmentEvents(ctx context.Context, shipmentID int) ([]ShipmentEvent, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT event_id, from_status, to_status, COALESCE(note, ''), created_by, created_at
+		FROM shipment_events
+		WHERE shipment_id = $1
+		ORDER BY created_at, event_id`, shipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipment history: %v", err)
+	}
+	defer rows.Close()
+
+	events := []ShipmentEvent{}
+	for rows.Next() {
+		var e ShipmentEvent
+		if err := rows.Scan(&e.ID, &e.FromStatus, &e.ToStatus, &e.Note, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment event: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shipment history: %v", err)
+	}
+
+	return events, nil
+}
+
+// TransitionShipment เปลี่ยนสถานะการจัดส่งตามลำดับใน fulfillment โดย actor (customer, seller หรือ admin)
+// บันทึกเวลาของสถานะใหม่และประวัติ แล้วอัปเดต cart_items.status ของทุกรายการในการจัดส่งให้ตรงกัน
+// หากเปลี่ยนไม่ได้จะคืน fulfillment.ErrInvalidTransition การยกเลิกต้องใช้ CancelOrder เพื่อคืนสต็อกด้วย
+// ส่วน returned ต้องผ่าน ApproveReturn ซึ่งรับสินค้าคืนเข้าคลังหรือตัดทิ้งและสร้างรายการคืนเงิน
+func (pdb *PostgresDatabase) TransitionShipment(ctx context.Context, shipmentID int, t ShipmentTransition, actor, userID string) (Shipment, error) {
+	if err := t.Validate(); err != nil {
+		return Shipment{}, err
+	}
+	if t.Status == fulfillment.Cancelled {
+		return Shipment{}, fmt.Errorf("%w: use order cancellation to cancel a shipment", fulfillment.ErrInvalidTransition)
+	}
+	if t.Status == fulfillment.Returned {
+		return Shipment{}, fmt.Errorf("%w: use a return request to return a shipment", fulfillment.ErrInvalidTransition)
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var locked lockedShipment
+	err = tx.QueryRowContext(ctx, `
+		SELECT shipment_id, order_id, seller_id, status FROM shipments
+		WHERE shipment_id = $1
+		FOR UPDATE`, shipmentID).Scan(&locked.id, &locked.orderID, &locked.sellerID, &locked.status)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return Shipment{}, ErrShipmentNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return Shipment{}, fmt.Errorf("failed to get shipment: %v", err)
+	}
+
+	if err := applyShipmentTransition(ctx, tx, locked, t, actor, userID); err != nil {
+		tx.Rollback()
+		return Shipment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Shipment{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.GetShipment(ctx, shipmentID)
+}
+
+// lockedShipment การจัดส่งที่ถูกล็อกด้วย SELECT ... FOR UPDATE ภายใน transaction
+type lockedShipment struct {
+	id       int
+	orderID  int
+	sellerID int
+	status   string
+}
+
+// applyShipmentTransition ตรวจลำดับสถานะ แล้วบันทึกสถานะใหม่ เวลาของสถานะ ประวัติ และ cart_items.status
+// ของการจัดส่งที่ผู้เรียกล็อกไว้แล้ว ภายใน transaction ของผู้เรียก
+func applyShipmentTransition(ctx context.Context, tx *sql.Tx, sh lockedShipment, t ShipmentTransition, actor, userID string) error {
+	if err := fulfillment.Transition(sh.status, t.Status, actor); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE shipments SET
+			status = $2,
+			`+statusColumn[t.Status]+` = CURRENT_TIMESTAMP,
+			carrier = COALESCE($3, carrier),
+			tracking_number = COALESCE($4, tracking_number)
+		WHERE shipment_id = $1`, sh.id, t.Status, t.Carrier, t.TrackingNumber)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment status: %v", err)
+	}
+
+	var note, createdBy *string
+	if t.Note != "" {
+		note = &t.Note
+	}
+	if userID != "" {
+		createdBy = &userID
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO shipment_events (shipment_id, from_status, to_status, note, created_by)
+		VALUES ($1, $2, $3, $4, $5)`, sh.id, sh.status, t.Status, note, createdBy)
+	if err != nil {
+		return fmt.Errorf("failed to record shipment history: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE cart_items SET status = $1
+		WHERE cart_item_id IN (
+			SELECT cart_item_id FROM order_items WHERE order_id = $2 AND seller_id = $3
+		)`, t.Status, sh.orderID, sh.sellerID)
+	if err != nil {
+		return fmt.Errorf("failed to update cart item status: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,424 @@
+// variant.go
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"productproject/internal/inventory"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidVariant ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลตัวเลือกสินค้าไม่ผ่านการตรวจสอบ
+var ErrInvalidVariant = errors.New("invalid variant")
+
+// ErrVariantNotFound ถูกส่งคืนเมื่อไม่พบตัวเลือก (หรือตัวเลือกไม่ใช่ของสินค้านั้น หรือถูกลบไปแล้ว)
+var ErrVariantNotFound = errors.New("variant not found")
+
+// ErrVariantExists ถูกส่งคืนเมื่อ SKU ซ้ำกับตัวเลือกอื่น
+var ErrVariantExists = errors.New("variant sku already exists")
+
+// จำนวนรูปสูงสุดต่อหนึ่งตัวเลือก
+const maxVariantImages = 10
+
+// Variant ตัวเลือกหนึ่งของสินค้า (สี ขนาด รุ่น) ซึ่งเป็นหนึ่ง SKU ที่มีสต็อกของตัวเอง
+// Price เป็น nil คือใช้ราคาของสินค้า ส่วน UnitPrice คือราคาที่ใช้จริง (ก่อนหักส่วนลดของสินค้า)
+type Variant struct {
+	ID        int       `json:"variant_id"`
+	ProductID int       `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	Size      string    `json:"size,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Price     *float64  `json:"price"`
+	UnitPrice float64   `json:"unit_price"`
+	Images    []string  `json:"image_urls"`
+	IsDefault bool      `json:"is_default"`
+	Inventory Inventory `json:"inventory"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewVariant ข้อมูลสำหรับสร้างตัวเลือกใหม่ของสินค้า
+type NewVariant struct {
+	SKU    string   `json:"sku"`
+	Name   string   `json:"name"`
+	Color  string   `json:"color"`
+	Size   string   `json:"size"`
+	Model  string   `json:"model"`
+	Price  *float64 `json:"price"`
+	Images []string `json:"image_urls"`
+	Stock  int      `json:"stock"` // จำนวนเริ่มต้นใน inventory
+}
+
+// UpdateVariant ข้อมูลสำหรับแก้ตัวเลือก ฟิลด์ที่เป็น nil จะไม่ถูกแก้
+// ClearPrice ให้กลับไปใช้ราคาของสินค้า การแก้ Stock จะบันทึกส่วนต่างลง stock_movements เป็น adjustment
+type UpdateVariant struct {
+	SKU        *string   `json:"sku"`
+	Name       *string   `json:"name"`
+	Color      *string   `json:"color"`
+	Size       *string   `json:"size"`
+	Model      *string   `json:"model"`
+	Price      *float64  `json:"price"`
+	ClearPrice bool      `json:"clear_price"`
+	Images     *[]string `json:"image_urls"`
+	Stock      *int      `json:"stock"`
+}
+
+// Validate ตรวจสอบค่าของตัวเลือกใหม่
+func (v *NewVariant) Validate() error {
+	if v.Images == nil {
+		v.Images = []string{}
+	}
+	return UpdateVariant{
+		SKU:    &v.SKU,
+		Name:   &v.Name,
+		Color:  &v.Color,
+		Size:   &v.Size,
+		Model:  &v.Model,
+		Price:  v.Price,
+		Images: &v.Images,
+		Stock:  &v.Stock,
+	}.Validate()
+}
+
+// Validate ตรวจสอบเฉพาะฟิลด์ที่ระบุมา
+func (u UpdateVariant) Validate() error {
+	if u.SKU != nil {
+		sku := strings.TrimSpace(*u.SKU)
+		if sku == "" || len(sku) > 64 {
+			return fmt.Errorf("%w: sku is required and must be at most 64 characters", ErrInvalidVariant)
+		}
+	}
+	if u.Name != nil && len(*u.Name) > 100 {
+		return fmt.Errorf("%w: name must be at most 100 characters", ErrInvalidVariant)
+	}
+	for _, attr := range []struct {
+		field string
+		value *string
+	}{{"color", u.Color}, {"size", u.Size}, {"model", u.Model}} {
+		if attr.value != nil && len(*attr.value) > 50 {
+			return fmt.Errorf("%w: %s must be at most 50 characters", ErrInvalidVariant, attr.field)
+		}
+	}
+	if u.Price != nil && (*u.Price <= 0 || *u.Price > maxProductPrice) {
+		return fmt.Errorf("%w: price must be greater than 0 and at most %.2f", ErrInvalidVariant, maxProductPrice)
+	}
+	if u.Price != nil && u.ClearPrice {
+		return fmt.Errorf("%w: price and clear_price cannot be used together", ErrInvalidVariant)
+	}
+	if u.Images != nil {
+		if len(*u.Images) > maxVariantImages {
+			return fmt.Errorf("%w: at most %d image_urls are allowed", ErrInvalidVariant, maxVariantImages)
+		}
+		for _, image := range *u.Images {
+			if strings.TrimSpace(image) == "" || len(image) > 255 {
+				return fmt.Errorf("%w: image_urls must be non-empty and at most 255 characters", ErrInvalidVariant)
+			}
+		}
+	}
+	if u.Stock != nil && *u.Stock < 0 {
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidVariant)
+	}
+	return nil
+}
+
+// checkSKU ตรวจสอบว่า sku ยังไม่ถูกใช้โดยตัวเลือกอื่น (รวมตัวเลือกที่ถูกลบแล้ว)
+func checkSKU(ctx context.Context, tx *sql.Tx, sku string, variantID int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM product_variants WHERE sku = $1 AND variant_id <> $2)`, sku, variantID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check sku: %v", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: %q", ErrVariantExists, sku)
+	}
+	return nil
+}
+
+// GetVariants คืนตัวเลือกที่ยังขายอยู่ของสินค้าพร้อมสต็อกของแต่ละตัว (ตัวเลือกหลักก่อน)
+func (pdb *PostgresDatabase) GetVariants(ctx context.Context, productID int) ([]Variant, error) {
+	rows, err := pdb.db.QueryContext(ctx, `
+		SELECT v.variant_id, v.product_id, v.sku, v.name, COALESCE(v.color, ''), COALESCE(v.size, ''),
+		       COALESCE(v.model, ''), v.price, COALESCE(v.price, p.price), v.image_urls, v.is_default,
+		       v.created_at, v.updated_at,
+		       COALESCE(i.quantity, 0), COALESCE(i.updated_at, v.updated_at),
+		       COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r
+		                 WHERE r.variant_id = v.variant_id AND r.expires_at > CURRENT_TIMESTAMP), 0)
+		FROM product_variants v
+		JOIN products p ON p.product_id = v.product_id
+		LEFT JOIN inventory i ON i.variant_id = v.variant_id
+		WHERE v.product_id = $1 AND v.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY v.is_default DESC, v.variant_id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query variants: %v", err)
+	}
+	defer rows.Close()
+
+	variants := []Variant{}
+	for rows.Next() {
+		var v Variant
+		var price sql.NullFloat64
+		if err := rows.Scan(
+			&v.ID, &v.ProductID, &v.SKU, &v.Name, &v.Color, &v.Size,
+			&v.Model, &price, &v.UnitPrice, pq.Array(&v.Images), &v.IsDefault,
+			&v.CreatedAt, &v.UpdatedAt,
+			&v.Inventory.Quantity, &v.Inventory.UpdatedAt, &v.Inventory.Reserved,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan variant: %v", err)
+		}
+		if price.Valid {
+			v.Price = &price.Float64
+		}
+		if v.Images == nil {
+			v.Images = []string{}
+		}
+		v.Inventory.Available = v.Inventory.Quantity - v.Inventory.Reserved
+		if v.Inventory.Available < 0 {
+			v.Inventory.Available = 0
+		}
+		variants = append(variants, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate variants: %v", err)
+	}
+
+	return variants, nil
+}
+
+// getVariant คืนตัวเลือกหนึ่งตัวของสินค้า
+func (pdb *PostgresDatabase) getVariant(ctx context.Context, productID, variantID int) (Variant, error) {
+	variants, err := pdb.GetVariants(ctx, productID)
+	if err != nil {
+		return Variant{}, err
+	}
+	for _, v := range variants {
+		if v.ID == variantID {
+			return v, nil
+		}
+	}
+	return Variant{}, ErrVariantNotFound
+}
+
+// resolveVariant ตรวจสอบว่า variantID เป็นตัวเลือกที่ยังขายอยู่ของสินค้า
+// variantID เป็น 0 คือตัวเลือกหลักของสินค้า
+func resolveVariant(ctx context.Context, tx *sql.Tx, productID, variantID int) (int, error) {
+	var resolved int
+	err := tx.QueryRowContext(ctx, `
+		SELECT variant_id FROM product_variants
+		WHERE product_id = $1 AND deleted_at IS NULL
+		  AND CASE WHEN $2 > 0 THEN variant_id = $2 ELSE is_default END`, productID, variantID).Scan(&resolved)
+	if err == sql.ErrNoRows {
+		return 0, ErrVariantNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get variant: %v", err)
+	}
+	return resolved, nil
+}
+
+// CreateVariant เพิ่มตัวเลือกใหม่ให้สินค้า แถว inventory ถูกสร้างโดย trigger create_inventory_for_variant
+// หากระบุ Stock จะบันทึกเป็น movement แบบ restock ในนามของ userID
+func (pdb *PostgresDatabase) CreateVariant(ctx context.Context, productID int, v NewVariant, userID string) (Variant, error) {
+	if err := v.Validate(); err != nil {
+		return Variant{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Variant{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	sku := strings.TrimSpace(v.SKU)
+	if err := checkSKU(ctx, tx, sku, 0); err != nil {
+		tx.Rollback()
+		return Variant{}, err
+	}
+
+	var variantID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO product_variants (product_id, sku, name, color, size, model, price, image_urls)
+		SELECT product_id, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8
+		FROM products
+		WHERE product_id = $1 AND deleted_at IS NULL
+		RETURNING variant_id`,
+		productID, sku, strings.TrimSpace(v.Name), v.Color, v.Size, v.Model,
+		v.Price, pq.Array(v.Images),
+	).Scan(&variantID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return Variant{}, ErrProductNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return Variant{}, fmt.Errorf("failed to create variant: %v", err)
+	}
+
+	if v.Stock > 0 {
+		_, err = inventory.Apply(ctx, tx, inventory.Movement{
+			VariantID: variantID,
+			Change:    v.Stock,
+			Reason:    inventory.ReasonRestock,
+			Note:      "initial stock",
+			CreatedBy: &userID,
+		})
+		if err != nil {
+			tx.Rollback()
+			return Variant{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Variant{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.getVariant(ctx, productID, variantID)
+}
+
+// UpdateVariant แก้เฉพาะฟิลด์ที่ระบุใน u หากระบุ Stock จะปรับ inventory ของตัวเลือกให้เท่ากับค่าใหม่
+// พร้อมบันทึก movement แบบ adjustment ในนามของ userID
+func (pdb *PostgresDatabase) UpdateVariant(ctx context.Context, productID, variantID int, u UpdateVariant, userID string) (Variant, error) {
+	if err := u.Validate(); err != nil {
+		return Variant{}, err
+	}
+
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Variant{}, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var sku, name *string
+	if u.SKU != nil {
+		trimmed := strings.TrimSpace(*u.SKU)
+		if err := checkSKU(ctx, tx, trimmed, variantID); err != nil {
+			tx.Rollback()
+			return Variant{}, err
+		}
+		sku = &trimmed
+	}
+	if u.Name != nil {
+		trimmed := strings.TrimSpace(*u.Name)
+		name = &trimmed
+	}
+	var images interface{}
+	if u.Images != nil {
+		images = pq.Array(*u.Images)
+	}
+
+	// ฟิลด์ที่เป็น NULL จะคงค่าเดิมไว้ สี ขนาด และรุ่นที่เป็นค่าว่างคือการลบค่า
+	result, err := tx.ExecContext(ctx, `
+		UPDATE product_variants v SET
+			sku = COALESCE($1, sku),
+			name = COALESCE($2, name),
+			color = CASE WHEN $3::text IS NULL THEN color ELSE NULLIF($3, '') END,
+			size = CASE WHEN $4::text IS NULL THEN size ELSE NULLIF($4, '') END,
+			model = CASE WHEN $5::text IS NULL THEN model ELSE NULLIF($5, '') END,
+			price = CASE WHEN $7 THEN NULL ELSE COALESCE($6, price) END,
+			image_urls = COALESCE($8, image_urls)
+		FROM products p
+		WHERE v.variant_id = $9 AND v.product_id = $10 AND v.deleted_at IS NULL
+		  AND p.product_id = v.product_id AND p.deleted_at IS NULL`,
+		sku, name, u.Color, u.Size, u.Model, u.Price, u.ClearPrice, images, variantID, productID)
+	if err != nil {
+		tx.Rollback()
+		return Variant{}, fmt.Errorf("failed to update variant: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return Variant{}, fmt.Errorf("failed to check updated variant: %v", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return Variant{}, ErrVariantNotFound
+	}
+
+	if u.Stock != nil {
+		if err := setVariantStock(ctx, tx, variantID, *u.Stock, "stock set by variant update", userID); err != nil {
+			tx.Rollback()
+			return Variant{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Variant{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return pdb.getVariant(ctx, productID, variantID)
+}
+
+// setVariantStock ปรับ inventory ของตัวเลือกให้เท่ากับ stock โดยบันทึกส่วนต่างเป็น adjustment
+// สต็อกต้องเปลี่ยนผ่าน inventory เสมอ เพื่อให้มีประวัติใน stock_movements
+func setVariantStock(ctx context.Context, tx *sql.Tx, variantID, stock int, note, userID string) error {
+	var current int
+	err := tx.QueryRowContext(ctx, `SELECT quantity FROM inventory WHERE variant_id = $1`, variantID).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	if change := stock - current; change != 0 {
+		_, err = inventory.Apply(ctx, tx, inventory.Movement{
+			VariantID: variantID,
+			Change:    change,
+			Reason:    inventory.ReasonAdjustment,
+			Note:      note,
+			CreatedBy: &userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteVariant ลบตัวเลือกแบบ soft delete (ลบตัวเลือกหลักไม่ได้) สต็อกที่เหลือถูกตัดเป็น 0
+// ด้วย movement แบบ adjustment เพื่อให้ product_stock ตรงกับตัวเลือกที่ยังขายอยู่
+// รายการในตะกร้าที่ยังไม่สั่งซื้อ (และการจอง) ของตัวเลือกนี้จะถูกลบออก
+func (pdb *PostgresDatabase) DeleteVariant(ctx context.Context, productID, variantID int, userID string) error {
+	tx, err := pdb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	var isDefault bool
+	err = tx.QueryRowContext(ctx, `
+		UPDATE product_variants SET deleted_at = CURRENT_TIMESTAMP
+		WHERE variant_id = $1 AND product_id = $2 AND deleted_at IS NULL
+		RETURNING is_default`, variantID, productID).Scan(&isDefault)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ErrVariantNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete variant: %v", err)
+	}
+	if isDefault {
+		tx.Rollback()
+		return fmt.Errorf("%w: the default variant cannot be deleted", ErrInvalidVariant)
+	}
+
+	if err := setVariantStock(ctx, tx, variantID, 0, "variant deleted", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM cart_items WHERE variant_id = $1 AND added_to_cart = FALSE`, variantID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to remove variant from carts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
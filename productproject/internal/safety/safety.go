@@ -0,0 +1,144 @@
+// safety.go
+package safety
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidLabel ถูกส่งคืน (ห่อด้วยรายละเอียด) เมื่อข้อมูลฉลากความปลอดภัยไม่ผ่านการตรวจสอบ
+var ErrInvalidLabel = errors.New("invalid safety label")
+
+// จำนวนมาตรฐานรับรองสูงสุดต่อสินค้า
+const maxCertifications = 10
+
+// Hazard ความเสี่ยงที่ต้องติดฉลากเตือน พร้อมอายุต่ำสุด (เดือน) ที่ใช้ได้อย่างปลอดภัย
+type Hazard struct {
+	Code         string `json:"code"`
+	MinAgeMonths int    `json:"min_age_months"`
+	Warning      string `json:"warning"`
+}
+
+// hazards ความเสี่ยงที่รองรับ เรียงตามลำดับที่แสดงบนฉลาก
+var hazards = []Hazard{
+	{Code: "small_parts", MinAgeMonths: 36, Warning: "คำเตือน: มีชิ้นส่วนขนาดเล็ก อาจทำให้ติดคอได้ ไม่เหมาะสำหรับเด็กอายุต่ำกว่า 3 ปี"},
+	{Code: "small_balls", MinAgeMonths: 36, Warning: "คำเตือน: มีลูกบอลขนาดเล็ก อาจทำให้ติดคอได้ ไม่เหมาะสำหรับเด็กอายุต่ำกว่า 3 ปี"},
+	{Code: "magnets", MinAgeMonths: 36, Warning: "คำเตือน: มีแม่เหล็กขนาดเล็ก หากกลืนอาจเป็นอันตรายร้ายแรงต่อลำไส้ ควรพบแพทย์ทันที"},
+	{Code: "button_battery", MinAgeMonths: 36, Warning: "คำเตือน: มีถ่านกระดุม หากกลืนอาจทำให้บาดเจ็บภายในอย่างรุนแรง เก็บให้พ้นมือเด็ก"},
+	{Code: "cords", MinAgeMonths: 36, Warning: "คำเตือน: มีสายยาว อาจพันคอเด็กได้ ห้ามผูกไว้กับเตียงหรือคอกเด็ก"},
+	{Code: "balloons", MinAgeMonths: 96, Warning: "คำเตือน: ลูกโป่งที่ยังไม่เป่าหรือแตกแล้วอาจทำให้เด็กอายุต่ำกว่า 8 ปีสำลักหรือหายใจไม่ออก ต้องมีผู้ใหญ่ดูแล"},
+}
+
+// Lookup คืนข้อมูลของความเสี่ยงตามรหัส
+func Lookup(code string) (Hazard, bool) {
+	for _, hazard := range hazards {
+		if hazard.Code == code {
+			return hazard, true
+		}
+	}
+	return Hazard{}, false
+}
+
+// Hazards คืนรายการความเสี่ยงทั้งหมดที่รองรับ
+func Hazards() []Hazard {
+	return append([]Hazard(nil), hazards...)
+}
+
+// Label ฉลากความปลอดภัยของสินค้า: ความเสี่ยงที่ต้องเตือน และข้อมูลการรับรองมาตรฐาน
+// Warnings สร้างจาก Hazards ด้วย Resolve (ค่าที่ส่งมาในฟิลด์นี้จะถูกแทนที่)
+type Label struct {
+	Hazards        []string `json:"hazards"`
+	TISNumber      string   `json:"tis_number"`     // เลขที่ มอก. เช่น "มอก. 685-2540"
+	Certifications []string `json:"certifications"` // มาตรฐานอื่น เช่น EN 71, ASTM F963
+	Warnings       []Hazard `json:"warnings"`
+}
+
+// Validate ตรวจสอบฉลาก ปรับรหัสความเสี่ยงเป็นตัวพิมพ์เล็ก ตัดรายการซ้ำ และเรียงตามลำดับบนฉลาก
+func (l *Label) Validate() error {
+	flagged := make(map[string]bool)
+	for _, code := range l.Hazards {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if _, ok := Lookup(code); !ok {
+			return fmt.Errorf("%w: unknown hazard %q", ErrInvalidLabel, code)
+		}
+		flagged[code] = true
+	}
+	l.Hazards = []string{}
+	for _, hazard := range hazards {
+		if flagged[hazard.Code] {
+			l.Hazards = append(l.Hazards, hazard.Code)
+		}
+	}
+
+	l.TISNumber = strings.TrimSpace(l.TISNumber)
+	if len([]rune(l.TISNumber)) > 100 {
+		return fmt.Errorf("%w: tis_number must be at most 100 characters", ErrInvalidLabel)
+	}
+
+	if len(l.Certifications) > maxCertifications {
+		return fmt.Errorf("%w: at most %d certifications are allowed", ErrInvalidLabel, maxCertifications)
+	}
+	seen := make(map[string]bool)
+	certifications := []string{}
+	for _, certification := range l.Certifications {
+		certification = strings.TrimSpace(certification)
+		if certification == "" || len([]rune(certification)) > 50 {
+			return fmt.Errorf("%w: certifications must be non-empty and at most 50 characters", ErrInvalidLabel)
+		}
+		if key := strings.ToUpper(certification); !seen[key] {
+			seen[key] = true
+			certifications = append(certifications, certification)
+		}
+	}
+	l.Certifications = certifications
+
+	l.Resolve()
+	return nil
+}
+
+// Resolve เติมคำเตือนของทุกความเสี่ยงใน Hazards (รหัสที่ไม่รู้จักถูกข้ามไป)
+func (l *Label) Resolve() {
+	if l.Hazards == nil {
+		l.Hazards = []string{}
+	}
+	if l.Certifications == nil {
+		l.Certifications = []string{}
+	}
+	l.Warnings = []Hazard{}
+	for _, code := range l.Hazards {
+		if hazard, ok := Lookup(code); ok {
+			l.Warnings = append(l.Warnings, hazard)
+		}
+	}
+}
+
+// MinAgeMonths อายุต่ำสุด (เดือน) ที่ความเสี่ยงทุกข้อบนฉลากยอมรับได้ (0 คือไม่มีความเสี่ยง)
+func (l Label) MinAgeMonths() int {
+	minAge := 0
+	for _, code := range l.Hazards {
+		if hazard, ok := Lookup(code); ok && hazard.MinAgeMonths > minAge {
+			minAge = hazard.MinAgeMonths
+		}
+	}
+	return minAge
+}
+
+// AcknowledgementAge อายุ (เดือน) ที่เด็กซึ่งอายุต่ำกว่านี้ต้องให้ผู้ซื้อยืนยันคำเตือนก่อนเพิ่มลงตะกร้า
+// ใช้อายุที่มากกว่าระหว่างความเสี่ยงบนฉลากกับอายุขั้นต่ำของสินค้า (productMinAge เป็น nil คือไม่ได้ระบุ)
+// คืน 0 เมื่อฉลากไม่มีความเสี่ยง เพราะสินค้าที่ระบุแค่ช่วงอายุไม่ต้องยืนยัน
+func (l Label) AcknowledgementAge(productMinAge *int) int {
+	if len(l.Hazards) == 0 {
+		return 0
+	}
+	minAge := l.MinAgeMonths()
+	if productMinAge != nil && *productMinAge > minAge {
+		minAge = *productMinAge
+	}
+	return minAge
+}
+
+// RequiresAcknowledgement บอกว่าผู้ซื้อที่มีเด็กอายุ ageMonths เดือนต้องยืนยันคำเตือนของสินค้านี้หรือไม่
+func (l Label) RequiresAcknowledgement(ageMonths int, productMinAge *int) bool {
+	return ageMonths < l.AcknowledgementAge(productMinAge)
+}
@@ -0,0 +1,160 @@
+package safety
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestLabelValidateInvalid ฉลากที่ไม่ผ่านการตรวจสอบต้องได้ ErrInvalidLabel
+func TestLabelValidateInvalid(t *testing.T) {
+	tooMany := make([]string, maxCertifications+1)
+	for i := range tooMany {
+		tooMany[i] = "EN 71-" + string(rune('A'+i))
+	}
+
+	tests := []struct {
+		name  string
+		label Label
+	}{
+		{"unknown hazard", Label{Hazards: []string{"sharp_edges"}}},
+		{"empty hazard", Label{Hazards: []string{" "}}},
+		{"unknown among known", Label{Hazards: []string{"magnets", "fire"}}},
+		{"tis number too long", Label{TISNumber: strings.Repeat("ม", 101)}},
+		{"too many certifications", Label{Certifications: tooMany}},
+		{"empty certification", Label{Certifications: []string{"EN 71", "  "}}},
+		{"certification too long", Label{Certifications: []string{strings.Repeat("A", 51)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label := tt.label
+			if err := label.Validate(); !errors.Is(err, ErrInvalidLabel) {
+				t.Errorf("Validate() = %v, want ErrInvalidLabel", err)
+			}
+		})
+	}
+}
+
+// TestLabelValidateNormalizes รหัสความเสี่ยงถูกปรับเป็นตัวพิมพ์เล็ก ตัดซ้ำ และเรียงตามลำดับบนฉลาก
+// มาตรฐานรับรองถูกตัดช่องว่างและตัดซ้ำแบบไม่สนตัวพิมพ์ ส่วน Warnings สร้างใหม่จาก Hazards
+func TestLabelValidateNormalizes(t *testing.T) {
+	label := Label{
+		Hazards:        []string{" Balloons", "small_parts", "SMALL_PARTS", "magnets"},
+		TISNumber:      "  มอก. 685-2540 ",
+		Certifications: []string{" EN 71 ", "en 71", "ASTM F963"},
+		Warnings:       []Hazard{{Code: "forged"}},
+	}
+	if err := label.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	if want := []string{"small_parts", "magnets", "balloons"}; !slices.Equal(label.Hazards, want) {
+		t.Errorf("Hazards = %q, want %q", label.Hazards, want)
+	}
+	if want := "มอก. 685-2540"; label.TISNumber != want {
+		t.Errorf("TISNumber = %q, want %q", label.TISNumber, want)
+	}
+	if want := []string{"EN 71", "ASTM F963"}; !slices.Equal(label.Certifications, want) {
+		t.Errorf("Certifications = %q, want %q", label.Certifications, want)
+	}
+	codes := make([]string, len(label.Warnings))
+	for i, warning := range label.Warnings {
+		codes[i] = warning.Code
+		if warning.Warning == "" {
+			t.Errorf("warning for %s has no text", warning.Code)
+		}
+	}
+	if !slices.Equal(codes, label.Hazards) {
+		t.Errorf("Warnings = %q, want one per hazard %q", codes, label.Hazards)
+	}
+}
+
+// TestLabelValidateEmpty ฉลากว่างผ่านการตรวจสอบ และได้ slice ว่างแทน nil (แสดงเป็น [] ใน JSON)
+func TestLabelValidateEmpty(t *testing.T) {
+	var label Label
+	if err := label.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if label.Hazards == nil || label.Certifications == nil || label.Warnings == nil {
+		t.Errorf("empty label has nil slices: %+v", label)
+	}
+}
+
+// TestMinAgeMonths อายุต่ำสุดคืออายุที่มากที่สุดของความเสี่ยงบนฉลาก
+func TestMinAgeMonths(t *testing.T) {
+	tests := []struct {
+		hazards []string
+		want    int
+	}{
+		{nil, 0},
+		{[]string{"small_parts"}, 36},
+		{[]string{"small_parts", "balloons"}, 96},
+		{[]string{"unknown"}, 0},
+	}
+	for _, tt := range tests {
+		if got := (Label{Hazards: tt.hazards}).MinAgeMonths(); got != tt.want {
+			t.Errorf("MinAgeMonths(%q) = %d, want %d", tt.hazards, got, tt.want)
+		}
+	}
+}
+
+// TestAcknowledgementAge ตรวจอายุที่ต้องยืนยันคำเตือน ซึ่งคืออายุที่มากกว่าระหว่างความเสี่ยงบนฉลากกับอายุของสินค้า
+func TestAcknowledgementAge(t *testing.T) {
+	months := func(v int) *int { return &v }
+
+	tests := []struct {
+		name          string
+		hazards       []string
+		productMinAge *int
+		want          int
+	}{
+		{"no hazards", nil, nil, 0},
+		{"product age alone needs no acknowledgement", nil, months(72), 0},
+		{"hazard age", []string{"small_parts"}, nil, 36},
+		{"product age below hazard age", []string{"magnets"}, months(12), 36},
+		{"product age above hazard age", []string{"cords"}, months(60), 60},
+		{"strictest hazard wins", []string{"small_parts", "balloons"}, months(48), 96},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := (Label{Hazards: tt.hazards}).AcknowledgementAge(tt.productMinAge)
+			if got != tt.want {
+				t.Errorf("AcknowledgementAge = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRequiresAcknowledgement เด็กที่อายุต่ำกว่าอายุขั้นต่ำต้องยืนยัน เด็กที่อายุถึงพอดีไม่ต้อง
+func TestRequiresAcknowledgement(t *testing.T) {
+	months := func(v int) *int { return &v }
+	smallParts := Label{Hazards: []string{"small_parts"}}
+
+	tests := []struct {
+		name          string
+		label         Label
+		ageMonths     int
+		productMinAge *int
+		want          bool
+	}{
+		{"newborn with small parts", smallParts, 0, nil, true},
+		{"one month under", smallParts, 35, nil, true},
+		{"exactly the minimum age", smallParts, 36, nil, false},
+		{"older child", smallParts, 120, nil, false},
+		{"product age raises the threshold", smallParts, 40, months(48), true},
+		{"product age reached", smallParts, 48, months(48), false},
+		{"balloons up to 8 years", Label{Hazards: []string{"balloons"}}, 95, nil, true},
+		{"no hazards never needs acknowledgement", Label{}, 0, months(36), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.label.RequiresAcknowledgement(tt.ageMonths, tt.productMinAge); got != tt.want {
+				t.Errorf("RequiresAcknowledgement(%d) = %v, want %v", tt.ageMonths, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// index.go
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// น้ำหนักของแต่ละฟิลด์ คำที่พบในชื่อสินค้ามีความสำคัญมากกว่าคำในคำอธิบาย
+const (
+	weightName        = 3.0
+	weightBrand       = 2.0
+	weightCategory    = 1.5
+	weightSeller      = 1.0
+	weightDescription = 1.0
+)
+
+// ค่าคงที่ของ BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Document ข้อมูลสินค้าหนึ่งชิ้นที่ใช้ทำ index
+// Category ควรรวมชื่อหมวดหมู่แม่ทุกระดับ เพื่อให้ค้นด้วยชื่อหมวดหมู่แม่แล้วพบสินค้าในหมวดหมู่ย่อย
+// ส่วน CategoryID และ CategoryName คือหมวดหมู่ของสินค้าเอง ใช้กับคำแนะนำในช่องค้นหา
+type Document struct {
+	ProductID    int
+	Name         string
+	Description  string
+	Brand        string
+	Category     string
+	CategoryID   int
+	CategoryName string
+	Seller       string
+	Popularity   int // จำนวนชิ้นที่ขายได้ช่วงหลัง ใช้เรียงคำแนะนำ
+}
+
+// Hit ผลการค้นหาหนึ่งรายการ เรียงตาม Score จากมากไปน้อย
+type Hit struct {
+	ProductID int
+	Score     float64
+}
+
+// Index ดัชนีค้นหาสินค้าในหน่วยความจำ จัดอันดับด้วย BM25 แบบถ่วงน้ำหนักตามฟิลด์
+// ปลอดภัยสำหรับการใช้งานพร้อมกันหลาย goroutine
+type Index struct {
+	mu       sync.RWMutex
+	ready    bool
+	postings map[string]map[int]float64 // คำ -> product_id -> ความถี่แบบถ่วงน้ำหนัก
+	docTerms map[int][]string           // product_id -> คำทั้งหมดของสินค้า (ใช้ตอนลบออกจาก index)
+	docLen   map[int]float64
+	totalLen float64
+
+	docs    map[int]Document // ข้อมูลต้นฉบับ ใช้สร้าง suggester ใหม่เมื่อสินค้าเปลี่ยน
+	suggest *suggester
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[int]float64{},
+		docTerms: map[int][]string{},
+		docLen:   map[int]float64{},
+		docs:     map[int]Document{},
+		suggest:  newSuggester(nil),
+	}
+}
+
+// Ready คืนค่า true เมื่อ index ถูกสร้างจากข้อมูลทั้งหมดแล้วอย่างน้อยหนึ่งครั้ง
+func (ix *Index) Ready() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.ready
+}
+
+// Replace สร้าง index ใหม่ทั้งหมดจาก docs
+func (ix *Index) Replace(docs []Document) {
+	fresh := NewIndex()
+	for _, doc := range docs {
+		fresh.put(doc)
+	}
+	fresh.suggest = newSuggester(fresh.docs)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.postings, ix.docTerms, ix.docLen, ix.totalLen = fresh.postings, fresh.docTerms, fresh.docLen, fresh.totalLen
+	ix.docs, ix.suggest = fresh.docs, fresh.suggest
+	ix.ready = true
+}
+
+// Put เพิ่มหรือแทนที่สินค้าหนึ่งชิ้นใน index
+func (ix *Index) Put(doc Document) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(doc.ProductID)
+	ix.put(doc)
+	ix.suggest = newSuggester(ix.docs)
+}
+
+// Remove นำสินค้าออกจาก index
+func (ix *Index) Remove(productID int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(productID)
+	ix.suggest = newSuggester(ix.docs)
+}
+
+func (ix *Index) put(doc Document) {
+	ix.docs[doc.ProductID] = doc
+
+	tf := map[string]float64{}
+	length := 0.0
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{doc.Name, weightName},
+		{doc.Brand, weightBrand},
+		{doc.Category, weightCategory},
+		{doc.Seller, weightSeller},
+		{doc.Description, weightDescription},
+	} {
+		for _, token := range Tokenize(field.text) {
+			tf[token] += field.weight
+			length += field.weight
+		}
+	}
+	if len(tf) == 0 {
+		return
+	}
+
+	terms := make([]string, 0, len(tf))
+	for term, freq := range tf {
+		if ix.postings[term] == nil {
+			ix.postings[term] = map[int]float64{}
+		}
+		ix.postings[term][doc.ProductID] = freq
+		terms = append(terms, term)
+	}
+	ix.docTerms[doc.ProductID] = terms
+	ix.docLen[doc.ProductID] = length
+	ix.totalLen += length
+}
+
+func (ix *Index) remove(productID int) {
+	delete(ix.docs, productID)
+	terms, ok := ix.docTerms[productID]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		delete(ix.postings[term], productID)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.totalLen -= ix.docLen[productID]
+	delete(ix.docTerms, productID)
+	delete(ix.docLen, productID)
+}
+
+// maxEdits จำนวนตัวอักษรที่พิมพ์ผิดได้ตามความยาวคำ คำสั้นต้องตรงทุกตัว
+func maxEdits(length int) int {
+	switch {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// น้ำหนักของคำที่สะกดต่างจากคำค้นตามจำนวนตัวอักษรที่ต่างกัน (ต่างแค่วรรณยุกต์คือ 0)
+var fuzzyWeights = []float64{0.9, 0.6, 0.4}
+
+// foldThai ตัดวรรณยุกต์และการันต์ออก ใช้ตอนเทียบคำที่พิมพ์ผิด
+// เพราะผู้ใช้มักพิมพ์ตกหรือพิมพ์วรรณยุกต์ผิด (เช่น "ออแกน" กับ "ออร์แกน")
+func foldThai(s string) []rune {
+	runes := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r >= 0x0E48 && r <= 0x0E4C {
+			continue
+		}
+		runes = append(runes, r)
+	}
+	return runes
+}
+
+// expand คืนคำใน index ที่ใช้แทน token ได้พร้อมน้ำหนัก
+// ถ้ามีคำที่ตรงกันจะใช้คำนั้นอย่างเดียว มิฉะนั้นใช้คำที่ขึ้นต้นด้วย token
+// และคำที่สะกดต่างกันไม่เกิน maxEdits ตัวอักษร (ทนต่อการพิมพ์ผิด)
+func (ix *Index) expand(token string) map[string]float64 {
+	if _, ok := ix.postings[token]; ok {
+		return map[string]float64{token: 1}
+	}
+
+	terms := map[string]float64{}
+	folded := foldThai(token)
+	edits := maxEdits(len(folded))
+	for term := range ix.postings {
+		if len(folded) >= 2 && strings.HasPrefix(term, token) {
+			terms[term] = 0.8
+			continue
+		}
+		if d := editDistance(folded, foldThai(term), edits); d <= edits {
+			terms[term] = fuzzyWeights[d]
+		}
+	}
+	return terms
+}
+
+// Search ค้นหาสินค้าที่ตรงกับ query คืนไม่เกิน limit รายการ
+// คะแนนของแต่ละคำคำนวณด้วย BM25 และคูณด้วยสัดส่วนคำค้นที่พบ เพื่อให้สินค้าที่ตรงทุกคำขึ้นก่อน
+func (ix *Index) Search(query string, limit int) []Hit {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var tokens []string
+	for _, token := range Tokenize(query) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 || len(ix.docLen) == 0 {
+		return nil
+	}
+
+	n := float64(len(ix.docLen))
+	avgLen := ix.totalLen / n
+	scores := map[int]float64{}
+	matched := map[int]int{}
+
+	for _, token := range tokens {
+		best := map[int]float64{}
+		for term, weight := range ix.expand(token) {
+			docs := ix.postings[term]
+			df := float64(len(docs))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for productID, tf := range docs {
+				norm := tf + bm25K1*(1-bm25B+bm25B*ix.docLen[productID]/avgLen)
+				score := weight * idf * tf * (bm25K1 + 1) / norm
+				if score > best[productID] {
+					best[productID] = score
+				}
+			}
+		}
+		for productID, score := range best {
+			scores[productID] += score
+			matched[productID]++
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for productID, score := range scores {
+		coverage := float64(matched[productID]) / float64(len(tokens))
+		hits = append(hits, Hit{ProductID: productID, Score: score * coverage})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ProductID < hits[j].ProductID
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
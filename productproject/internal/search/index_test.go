@@ -0,0 +1,166 @@
+package search
+
+import (
+	"math"
+	"testing"
+)
+
+func testIndex() *Index {
+	ix := NewIndex()
+	ix.Replace([]Document{
+		{ProductID: 1, Name: "ออร์แกนเสียงสัตว์", Description: "ของเล่นดนตรีสำหรับเด็ก", Brand: "Melody"},
+		{ProductID: 2, Name: "ตุ๊กตาหมี", Description: "ตุ๊กตามีเสียงเพลง", Brand: "Teddy"},
+		{ProductID: 3, Name: "รถไฟของเล่น", Description: "ออร์แกนเล็กติดหลังรถ", Brand: "Melody"},
+		{ProductID: 4, Name: "Dinosaur robot", Description: "walking robot with sound", Brand: "RoboKid"},
+	})
+	return ix
+}
+
+func hitIDs(hits []Hit) []int {
+	ids := make([]int, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ProductID
+	}
+	return ids
+}
+
+// TestSearchThaiQuery คำค้นภาษาไทยที่ไม่มีช่องว่างต้องถูกตัดคำแล้วพบสินค้าที่ตรงทุกคำก่อน
+func TestSearchThaiQuery(t *testing.T) {
+	ix := testIndex()
+
+	hits := ix.Search("ออร์แกนเสียงสัตว์", 10)
+	if len(hits) == 0 || hits[0].ProductID != 1 {
+		t.Fatalf("Search(ออร์แกนเสียงสัตว์) = %v, want product 1 first", hitIDs(hits))
+	}
+	for _, hit := range hits[1:] {
+		if hit.Score >= hits[0].Score {
+			t.Errorf("product %d scored %.3f, want less than the full match %.3f", hit.ProductID, hit.Score, hits[0].Score)
+		}
+	}
+}
+
+// TestSearchFieldWeight คำที่พบในชื่อสินค้าต้องได้คะแนนมากกว่าคำที่พบในคำอธิบาย
+func TestSearchFieldWeight(t *testing.T) {
+	ix := testIndex()
+
+	hits := ix.Search("ออร์แกน", 10)
+	if got := hitIDs(hits); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("Search(ออร์แกน) = %v, want [1 3]", got)
+	}
+}
+
+// TestSearchCoverage สินค้าที่ตรงทุกคำค้นต้องขึ้นก่อนสินค้าที่ตรงบางคำ
+func TestSearchCoverage(t *testing.T) {
+	ix := testIndex()
+
+	hits := ix.Search("ของเล่น รถไฟ", 10)
+	if len(hits) == 0 || hits[0].ProductID != 3 {
+		t.Fatalf("Search(ของเล่น รถไฟ) = %v, want product 3 first", hitIDs(hits))
+	}
+}
+
+// TestSearchLimit ผลลัพธ์ต้องไม่เกิน limit และ limit 0 คือไม่จำกัด
+func TestSearchLimit(t *testing.T) {
+	ix := testIndex()
+
+	if hits := ix.Search("melody", 1); len(hits) != 1 {
+		t.Errorf("Search(melody, 1) returned %d hits, want 1", len(hits))
+	}
+	if hits := ix.Search("melody", 0); len(hits) != 2 {
+		t.Errorf("Search(melody, 0) returned %d hits, want 2", len(hits))
+	}
+	if hits := ix.Search("", 10); hits != nil {
+		t.Errorf("Search(\"\") = %v, want nil", hitIDs(hits))
+	}
+}
+
+// TestExpand ตรวจการขยายคำค้น: คำตรงใช้คำเดียว คำขึ้นต้น และคำที่พิมพ์ผิดได้น้ำหนักลดลง
+func TestExpand(t *testing.T) {
+	ix := testIndex()
+
+	tests := []struct {
+		name  string
+		token string
+		want  map[string]float64
+	}{
+		{"exact match only", "robot", map[string]float64{"robot": 1}},
+		{"prefix", "dino", map[string]float64{"dinosaur": 0.8}},
+		{"missing karan", "ออรแกน", map[string]float64{"ออร์แกน": fuzzyWeights[0]}},
+		{"dropped letter", "ออแกน", map[string]float64{"ออร์แกน": fuzzyWeights[1]}},
+		{"one typo", "robat", map[string]float64{"robot": fuzzyWeights[1]}},
+		{"short token expands by prefix only", "rob", map[string]float64{"robot": 0.8, "robokid": 0.8}},
+		{"too many typos", "rabat", map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ix.expand(tt.token)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expand(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+			for term, weight := range tt.want {
+				if got[term] != weight {
+					t.Errorf("expand(%q)[%q] = %v, want %v", tt.token, term, got[term], weight)
+				}
+			}
+		})
+	}
+}
+
+// TestSearchTypo คำค้นที่พิมพ์ผิดเล็กน้อยยังต้องพบสินค้า
+func TestSearchTypo(t *testing.T) {
+	ix := testIndex()
+
+	hits := ix.Search("ออแกน", 10)
+	if len(hits) == 0 || hits[0].ProductID != 1 {
+		t.Fatalf("Search(ออแกน) = %v, want product 1 first", hitIDs(hits))
+	}
+}
+
+// TestPutRemove ตรวจว่า Put แทนที่สินค้าเดิมโดยไม่นับซ้ำ และ Remove ล้างคำกับความยาวรวมของสินค้า
+func TestPutRemove(t *testing.T) {
+	ix := testIndex()
+	before := ix.totalLen
+
+	doc := Document{ProductID: 5, Name: "บล็อกไม้", Brand: "Wood"}
+	ix.Put(doc)
+	added := ix.docLen[5]
+	if added == 0 {
+		t.Fatal("Put did not index product 5")
+	}
+	if math.Abs(ix.totalLen-(before+added)) > 1e-9 {
+		t.Errorf("totalLen after Put = %v, want %v", ix.totalLen, before+added)
+	}
+
+	// Put ซ้ำต้องแทนที่ ไม่บวกความยาวเพิ่ม
+	ix.Put(doc)
+	if math.Abs(ix.totalLen-(before+added)) > 1e-9 {
+		t.Errorf("totalLen after second Put = %v, want %v", ix.totalLen, before+added)
+	}
+
+	// เปลี่ยนชื่อแล้วคำเดิมต้องหายไปจาก index
+	ix.Put(Document{ProductID: 5, Name: "ตัวต่อ", Brand: "Wood"})
+	if hits := ix.Search("บล็อก", 10); len(hits) != 0 {
+		t.Errorf("Search(บล็อก) after rename = %v, want no hits", hitIDs(hits))
+	}
+
+	ix.Remove(5)
+	if math.Abs(ix.totalLen-before) > 1e-9 {
+		t.Errorf("totalLen after Remove = %v, want %v", ix.totalLen, before)
+	}
+	if _, ok := ix.postings["wood"]; ok {
+		t.Error("postings still contain wood after Remove")
+	}
+	if _, ok := ix.docs[5]; ok {
+		t.Error("docs still contain product 5 after Remove")
+	}
+	if hits := ix.Search("wood", 10); len(hits) != 0 {
+		t.Errorf("Search(wood) after Remove = %v, want no hits", hitIDs(hits))
+	}
+
+	// ลบสินค้าที่ไม่มีอยู่ต้องไม่เปลี่ยนอะไร
+	ix.Remove(99)
+	if math.Abs(ix.totalLen-before) > 1e-9 {
+		t.Errorf("totalLen after removing unknown product = %v, want %v", ix.totalLen, before)
+	}
+}
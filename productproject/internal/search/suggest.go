@@ -0,0 +1,133 @@
+// suggest.go
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// ชนิดของคำแนะนำ
+const (
+	SuggestProduct  = "product"
+	SuggestBrand    = "brand"
+	SuggestCategory = "category"
+)
+
+// Suggestion คำแนะนำหนึ่งรายการสำหรับช่องค้นหา
+type Suggestion struct {
+	Kind       string `json:"kind"` // product, brand หรือ category
+	Text       string `json:"text"`
+	ProductID  int    `json:"product_id,omitempty"`
+	CategoryID int    `json:"category_id,omitempty"`
+}
+
+type suggestEntry struct {
+	Suggestion
+	popularity int
+}
+
+// suggestKey คำที่ใช้จับคู่ prefix ของแต่ละคำแนะนำ คือข้อความทั้งหมดและทุกคำที่ตัดได้จากข้อความ
+type suggestKey struct {
+	key   string
+	entry int
+	full  bool // key คือข้อความทั้งหมด (ตรงตั้งแต่ต้นข้อความ)
+}
+
+// suggester ข้อมูลสำหรับแนะนำคำค้น สร้างใหม่ทุกครั้งที่สินค้าเปลี่ยนและไม่ถูกแก้หลังสร้าง
+// keys เรียงตามตัวอักษรเพื่อหา prefix ด้วย binary search
+type suggester struct {
+	entries []suggestEntry
+	keys    []suggestKey
+}
+
+func newSuggester(docs map[int]Document) *suggester {
+	s := &suggester{}
+	brands := map[string]int{}
+	categories := map[int]int{}
+
+	add := func(e suggestEntry) int {
+		s.entries = append(s.entries, e)
+		return len(s.entries) - 1
+	}
+
+	for _, doc := range docs {
+		add(suggestEntry{Suggestion{Kind: SuggestProduct, Text: doc.Name, ProductID: doc.ProductID}, doc.Popularity})
+
+		if brand := strings.TrimSpace(doc.Brand); brand != "" {
+			key := strings.ToLower(brand)
+			if i, ok := brands[key]; ok {
+				s.entries[i].popularity += doc.Popularity
+			} else {
+				brands[key] = add(suggestEntry{Suggestion{Kind: SuggestBrand, Text: brand}, doc.Popularity})
+			}
+		}
+
+		if doc.CategoryID > 0 && doc.CategoryName != "" {
+			if i, ok := categories[doc.CategoryID]; ok {
+				s.entries[i].popularity += doc.Popularity
+			} else {
+				categories[doc.CategoryID] = add(suggestEntry{Suggestion{Kind: SuggestCategory, Text: doc.CategoryName, CategoryID: doc.CategoryID}, doc.Popularity})
+			}
+		}
+	}
+
+	for i, e := range s.entries {
+		full := strings.ToLower(e.Text)
+		s.keys = append(s.keys, suggestKey{key: full, entry: i, full: true})
+		for _, token := range Tokenize(e.Text) {
+			if token != full {
+				s.keys = append(s.keys, suggestKey{key: token, entry: i})
+			}
+		}
+	}
+	sort.Slice(s.keys, func(i, j int) bool { return s.keys[i].key < s.keys[j].key })
+
+	return s
+}
+
+// Suggest คืนคำแนะนำที่ข้อความหรือคำใดคำหนึ่งในข้อความขึ้นต้นด้วย prefix ไม่เกิน limit รายการ
+// เรียงตามความนิยม (ยอดขาย) แล้วให้ข้อความที่ขึ้นต้นด้วย prefix มาก่อน และข้อความที่สั้นกว่ามาก่อน
+func (ix *Index) Suggest(prefix string, limit int) []Suggestion {
+	ix.mu.RLock()
+	s := ix.suggest
+	ix.mu.RUnlock()
+
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return []Suggestion{}
+	}
+
+	best := map[int]bool{} // entry -> ตรงตั้งแต่ต้นข้อความหรือไม่
+	start := sort.Search(len(s.keys), func(i int) bool { return s.keys[i].key >= prefix })
+	for i := start; i < len(s.keys) && strings.HasPrefix(s.keys[i].key, prefix); i++ {
+		k := s.keys[i]
+		best[k.entry] = best[k.entry] || k.full
+	}
+
+	matches := make([]int, 0, len(best))
+	for entry := range best {
+		matches = append(matches, entry)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := s.entries[matches[i]], s.entries[matches[j]]
+		if a.popularity != b.popularity {
+			return a.popularity > b.popularity
+		}
+		if best[matches[i]] != best[matches[j]] {
+			return best[matches[i]]
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	suggestions := make([]Suggestion, len(matches))
+	for i, entry := range matches {
+		suggestions[i] = s.entries[entry].Suggestion
+	}
+	return suggestions
+}
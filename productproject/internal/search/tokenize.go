@@ -0,0 +1,268 @@
+// tokenize.go
+package search
+
+import (
+	_ "embed"
+	"strings"
+	"unicode"
+)
+
+// thai_words.txt รายการคำภาษาไทยที่ใช้ตัดคำ (หนึ่งคำต่อบรรทัด บรรทัดที่ขึ้นต้นด้วย # คือคอมเมนต์)
+// เพิ่มคำที่พบบ่อยในชื่อและคำอธิบายสินค้าได้ที่ไฟล์นี้
+//
+//go:embed thai_words.txt
+var thaiWordList string
+
+// trieNode โหนดของ trie พจนานุกรมภาษาไทย
+type trieNode struct {
+	next map[rune]*trieNode
+	word bool
+}
+
+func (n *trieNode) insert(word string) {
+	node := n
+	for _, r := range word {
+		child, ok := node.next[r]
+		if !ok {
+			child = &trieNode{next: map[rune]*trieNode{}}
+			node.next[r] = child
+		}
+		node = child
+	}
+	node.word = true
+}
+
+var thaiDict = loadThaiDict(thaiWordList)
+
+func loadThaiDict(list string) *trieNode {
+	root := &trieNode{next: map[rune]*trieNode{}}
+	for _, line := range strings.Split(list, "\n") {
+		word := strings.TrimSpace(line)
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		root.insert(word)
+	}
+	return root
+}
+
+func isThai(r rune) bool {
+	return r >= 0x0E01 && r <= 0x0E4E && r != 'ฯ' && r != 'ๆ' && r != '฿'
+}
+
+// สระหน้า (เ แ โ ใ ไ) ต้องอยู่กลุ่มเดียวกับพยัญชนะที่ตามมา
+func isLeadingVowel(r rune) bool {
+	return r >= 0x0E40 && r <= 0x0E44
+}
+
+// สระบน สระล่าง วรรณยุกต์ การันต์ และสระหลัง (ะ า ำ ๅ) ต้องอยู่กลุ่มเดียวกับพยัญชนะข้างหน้า
+func isThaiTrailing(r rune) bool {
+	switch {
+	case r == 0x0E30 || r == 0x0E31 || r == 0x0E32 || r == 0x0E33:
+		return true
+	case r >= 0x0E34 && r <= 0x0E3A:
+		return true
+	case r == 0x0E45:
+		return true
+	case r >= 0x0E47 && r <= 0x0E4E:
+		return true
+	}
+	return false
+}
+
+// Tokenize แยกข้อความเป็นคำสำหรับทำ index และค้นหา
+// ตัวอักษรละตินและตัวเลขแยกตามช่องว่างและเครื่องหมาย (แปลงเป็นตัวพิมพ์เล็ก)
+// ข้อความภาษาไทยซึ่งไม่มีช่องว่างระหว่างคำจะถูกตัดคำด้วยพจนานุกรม
+// เลขไทยถูกแปลงเป็นเลขอารบิก และไม้ยมก (ๆ) ถูกตัดทิ้ง
+func Tokenize(text string) []string {
+	var tokens []string
+	var run []rune
+	runThai := false
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if runThai {
+			tokens = append(tokens, segmentThai(run)...)
+		} else {
+			tokens = append(tokens, string(run))
+		}
+		run = run[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		if r >= '๐' && r <= '๙' {
+			r = '0' + (r - '๐')
+		}
+		switch {
+		case r == 'ๆ' || r == 'ฯ':
+			flush()
+		case isThai(r):
+			if !runThai {
+				flush()
+			}
+			runThai = true
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if runThai {
+				flush()
+			}
+			runThai = false
+			run = append(run, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// clusterStarts คืนตำแหน่งที่เป็นจุดเริ่มกลุ่มอักษร (พยัญชนะพร้อมสระและวรรณยุกต์ที่เกาะอยู่)
+// คำในพจนานุกรมต้องเริ่มและจบตรงจุดเหล่านี้ กันการตัดกลางพยางค์
+func clusterStarts(run []rune) []bool {
+	starts := make([]bool, len(run)+1)
+	starts[len(run)] = true
+	for i := 0; i < len(run); {
+		starts[i] = true
+		j := i
+		if isLeadingVowel(run[j]) {
+			j++
+		}
+		if j < len(run) {
+			j++
+		}
+		for j < len(run) && isThaiTrailing(run[j]) {
+			j++
+		}
+		i = j
+	}
+	return starts
+}
+
+// segmentThai ตัดคำภาษาไทยแบบ maximal matching เลือกการตัดที่มีตัวอักษรนอกพจนานุกรมน้อยที่สุด
+// และมีจำนวนคำน้อยที่สุด ส่วนที่ไม่อยู่ในพจนานุกรมซึ่งติดกันจะถูกรวมเป็นคำเดียว
+func segmentThai(run []rune) []string {
+	n := len(run)
+	starts := clusterStarts(run)
+
+	type step struct {
+		unknown, words int
+		prev           int
+		known, set     bool
+	}
+	dp := make([]step, n+1)
+	dp[0].set = true
+
+	relax := func(from, to int, known bool) {
+		cand := step{unknown: dp[from].unknown, words: dp[from].words + 1, prev: from, known: known, set: true}
+		if !known {
+			cand.unknown += to - from
+		}
+		cur := dp[to]
+		if !cur.set || cand.unknown < cur.unknown || (cand.unknown == cur.unknown && cand.words < cur.words) {
+			dp[to] = cand
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if !dp[i].set || !starts[i] {
+			continue
+		}
+		node := thaiDict
+		for j := i; j < n; j++ {
+			node = node.next[run[j]]
+			if node == nil {
+				break
+			}
+			if node.word && starts[j+1] {
+				relax(i, j+1, true)
+			}
+		}
+		next := i + 1
+		for !starts[next] {
+			next++
+		}
+		relax(i, next, false)
+	}
+
+	// ย้อนเส้นทางจากท้ายข้อความ
+	var segments []step
+	var ends []int
+	for pos := n; pos > 0; pos = dp[pos].prev {
+		segments = append(segments, dp[pos])
+		ends = append(ends, pos)
+	}
+
+	var tokens []string
+	unknownStart := -1
+	for k := len(segments) - 1; k >= 0; k-- {
+		seg, end := segments[k], ends[k]
+		if seg.known {
+			if unknownStart >= 0 {
+				tokens = append(tokens, string(run[unknownStart:seg.prev]))
+				unknownStart = -1
+			}
+			tokens = append(tokens, string(run[seg.prev:end]))
+			continue
+		}
+		if unknownStart < 0 {
+			unknownStart = seg.prev
+		}
+	}
+	if unknownStart >= 0 {
+		tokens = append(tokens, string(run[unknownStart:]))
+	}
+
+	return tokens
+}
+
+// editDistance ระยะห่างแบบ Damerau-Levenshtein (optimal string alignment) ระหว่าง a และ b
+// หยุดคำนวณและคืนค่า max+1 ทันทีเมื่อเกิน max
+func editDistance(a, b []rune, max int) int {
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
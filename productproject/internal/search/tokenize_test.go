@@ -0,0 +1,91 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestTokenize ตรวจการตัดคำข้อความภาษาไทย ละติน และตัวเลขปนกัน
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"thai words without spaces", "ออร์แกนเสียงสัตว์", []string{"ออร์แกน", "เสียง", "สัตว์"}},
+		{"longest word wins", "รถไฟ", []string{"รถไฟ"}},
+		{"shorter word before longer", "รถรถไฟ", []string{"รถ", "รถไฟ"}},
+		{"mai yamok is dropped", "ตุ๊กตาหมีเด็กๆ", []string{"ตุ๊กตา", "หมี", "เด็ก"}},
+		{"mai yamok splits words", "เด็กๆเล่น", []string{"เด็ก", "เล่น"}},
+		{"thai digits become arabic", "ราคา๑๐๐บาท", []string{"ราคา", "100", "บาท"}},
+		{"thai digit between words", "รถไฟ๒ขบวน", []string{"รถไฟ", "2", "ขบวน"}},
+		{"unknown run is kept as one token", "ฆฌฎรถไฟ", []string{"ฆฌฎ", "รถไฟ"}},
+		{"unknown run at the end", "รถไฟฆฌฎ", []string{"รถไฟ", "ฆฌฎ"}},
+		{"latin is lowercased and split on spaces", "Lego รถบังคับ 4WD", []string{"lego", "รถ", "บังคับ", "4wd"}},
+		{"punctuation separates tokens", "Car,Toy-Set", []string{"car", "toy", "set"}},
+		{"empty text", "  ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClusterStarts ตรวจว่าสระหน้า สระบน และวรรณยุกต์อยู่กลุ่มเดียวกับพยัญชนะ
+func TestClusterStarts(t *testing.T) {
+	tests := []struct {
+		text string
+		want []bool
+	}{
+		// เสี | ย | ง
+		{"เสียง", []bool{true, false, false, true, true, true}},
+		// ตุ๊ | ก | ตา
+		{"ตุ๊กตา", []bool{true, false, false, true, true, false, true}},
+		// ส | ั | ต | ว์ : ไม้หันอากาศและการันต์เกาะพยัญชนะข้างหน้า
+		{"สัตว์", []bool{true, false, true, true, false, true}},
+	}
+
+	for _, tt := range tests {
+		if got := clusterStarts([]rune(tt.text)); !slices.Equal(got, tt.want) {
+			t.Errorf("clusterStarts(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestEditDistance ตรวจระยะห่างรวมการสลับตัวอักษรที่อยู่ติดกัน และการหยุดเมื่อเกิน max
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"robot", "robot", 2, 0},
+		{"robot", "robat", 2, 1},
+		{"robot", "rboot", 2, 1}, // สลับตัวติดกันนับเป็นหนึ่ง
+		{"robot", "robots", 2, 1},
+		{"kitten", "sitting", 2, 3}, // เกิน max คืน max+1
+		{"abc", "abcdef", 1, 2},     // ความยาวต่างเกิน max
+		{"ออแกน", "ออรแกน", 1, 1},
+		{"", "ab", 2, 2},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+// TestFoldThai ตรวจว่าการตัดวรรณยุกต์และการันต์ทำให้คำที่พิมพ์ตกเทียบกันได้
+func TestFoldThai(t *testing.T) {
+	if got, want := string(foldThai("ออร์แกน")), "ออรแกน"; got != want {
+		t.Errorf("foldThai(ออร์แกน) = %q, want %q", got, want)
+	}
+	if got, want := string(foldThai("ตุ๊กตา")), "ตุกตา"; got != want {
+		t.Errorf("foldThai(ตุ๊กตา) = %q, want %q", got, want)
+	}
+}